@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/authzed/spicedb-operator/pkg/cmd/graph"
 	"github.com/authzed/spicedb-operator/pkg/cmd/run"
 	"github.com/authzed/spicedb-operator/pkg/version"
 )
@@ -18,6 +19,7 @@ func main() {
 	}
 
 	root.AddCommand(run.NewCmdRun(run.RecommendedOptions()))
+	root.AddCommand(graph.NewCmdGraph(graph.RecommendedOptions()))
 
 	var includeDeps bool
 	versionCmd := &cobra.Command{
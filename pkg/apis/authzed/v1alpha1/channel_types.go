@@ -0,0 +1,153 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	SpiceDBChannelResourceName = "spicedbchannels"
+	SpiceDBChannelKind         = "SpiceDBChannel"
+)
+
+// SpiceDBChannel publishes an update channel that is merged into the update
+// graph from the operator config. Clusters subscribe to it by setting
+// spec.channel to the channel name.
+//
+// +crd
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster,categories=authzed
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Channel",type=string,JSONPath=".spec.channel"
+// +kubebuilder:printcolumn:name="Datastore",type=string,JSONPath=".spec.datastore"
+type SpiceDBChannel struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ChannelSpec `json:"spec"`
+}
+
+// ChannelSpec holds the nodes and edges of a channel. It mirrors the
+// channels in the operator config's update graph.
+//
+// +kubebuilder:validation:XValidation:rule="self.nodes.all(n, self.nodes.exists_one(m, m.id == n.id))",message="node ids must be unique"
+// +kubebuilder:validation:XValidation:rule="!has(self.edges) || self.edges.all(from, self.nodes.exists(n, n.id == from) && self.edges[from].all(to, self.nodes.exists(n, n.id == to)))",message="edges must be between nodes in the channel"
+// +kubebuilder:validation:XValidation:rule="!has(self.rollbacks) || self.rollbacks.all(from, self.nodes.exists(n, n.id == from) && self.rollbacks[from].all(r, self.nodes.exists(n, n.id == r.to)))",message="rollbacks must be between nodes in the channel"
+// +kubebuilder:validation:XValidation:rule="!has(self.incompatibleDispatch) || self.incompatibleDispatch.all(from, self.nodes.exists(n, n.id == from) && self.incompatibleDispatch[from].all(to, self.nodes.exists(n, n.id == to)))",message="incompatibleDispatch must be between nodes in the channel"
+type ChannelSpec struct {
+	// Channel is the name that clusters use in spec.channel to subscribe to
+	// this channel. Defaults to the name of the SpiceDBChannel.
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	Channel string `json:"channel,omitempty"`
+
+	// Datastore is the datastore engine that the channel is for.
+	// +kubebuilder:validation:Enum=cockroachdb;postgres;mysql;spanner;memory
+	Datastore string `json:"datastore"`
+
+	// Nodes are the releases in the channel. The first node is the head of
+	// the channel, and every other node must have a path to it.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=50
+	Nodes []ChannelNode `json:"nodes"`
+
+	// Edges maps a node id to the ids of the nodes it can update to.
+	// +optional
+	// +kubebuilder:validation:MaxProperties=50
+	Edges map[string]ChannelEdges `json:"edges,omitempty"`
+
+	// Rollbacks maps a node id to the older nodes it can roll back to. The
+	// nodes must share a migration and phase, since there are no
+	// down-migrations.
+	// +optional
+	// +kubebuilder:validation:MaxProperties=50
+	Rollbacks map[string]ChannelRollbacks `json:"rollbacks,omitempty"`
+
+	// IncompatibleDispatch maps a node id to the ids of nodes that it can't
+	// dispatch to during a rollout.
+	// +optional
+	// +kubebuilder:validation:MaxProperties=50
+	IncompatibleDispatch map[string]ChannelEdges `json:"incompatibleDispatch,omitempty"`
+}
+
+// ChannelEdges is a list of node ids.
+// +kubebuilder:validation:MaxItems=50
+// +kubebuilder:validation:items:MaxLength=128
+type ChannelEdges []string
+
+// ChannelRollbacks is a list of rollbacks from a node.
+// +kubebuilder:validation:MaxItems=50
+type ChannelRollbacks []ChannelRollback
+
+// ChannelNode is a release in a channel.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.tag) || has(self.digest)",message="a node needs a tag or a digest"
+type ChannelNode struct {
+	// ID identifies the node in edges and in the status of clusters.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=128
+	ID string `json:"id"`
+
+	// Tag is the image tag of the release.
+	// +optional
+	// +kubebuilder:validation:MaxLength=128
+	Tag string `json:"tag,omitempty"`
+
+	// Digest is the image digest of the release.
+	// +optional
+	// +kubebuilder:validation:MaxLength=128
+	Digest string `json:"digest,omitempty"`
+
+	// Migration is the datastore migration that the release runs at.
+	// +optional
+	// +kubebuilder:validation:MaxLength=128
+	Migration string `json:"migration,omitempty"`
+
+	// Phase is the migration phase that the release runs at.
+	// +optional
+	// +kubebuilder:validation:MaxLength=128
+	Phase string `json:"phase,omitempty"`
+
+	// Deprecated releases can be updated from, but not to.
+	// +optional
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// DeprecatedSince is the date (YYYY-MM-DD) the release is deprecated
+	// from.
+	// +optional
+	// +kubebuilder:validation:Format=date
+	DeprecatedSince string `json:"deprecatedSince,omitempty"`
+
+	// EndOfLife is the date (YYYY-MM-DD) the release stops being supported.
+	// +optional
+	// +kubebuilder:validation:Format=date
+	EndOfLife string `json:"endOfLife,omitempty"`
+}
+
+// ChannelRollback is an edge from a node back to an older node.
+type ChannelRollback struct {
+	// To is the id of the older node.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=128
+	To string `json:"to"`
+}
+
+// ChannelName returns the name that clusters subscribe to.
+func (c *SpiceDBChannel) ChannelName() string {
+	if len(c.Spec.Channel) > 0 {
+		return c.Spec.Channel
+	}
+	return c.Name
+}
+
+// SpiceDBChannelList is a list of SpiceDBChannel resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SpiceDBChannelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []SpiceDBChannel `json:"items"`
+}
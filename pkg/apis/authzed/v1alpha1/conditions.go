@@ -2,6 +2,7 @@ package v1alpha1
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,15 +17,29 @@ func (c *SpiceDBCluster) NamespacedName() types.NamespacedName {
 }
 
 const (
-	ConditionTypeValidating          = "Validating"
-	ConditionValidatingFailed        = "ValidatingFailed"
-	ConditionTypeMigrating           = "Migrating"
-	ConditionTypeConfigWarnings      = "ConfigurationWarning"
-	ConditionTypePreconditionsFailed = "PreconditionsFailed"
-	ConditionTypeRolling             = "RollingDeployment"
-	ConditionTypeRolloutError        = "RolloutError"
-
-	ConditionReasonMissingSecret = "MissingSecret"
+	ConditionTypeValidating           = "Validating"
+	ConditionValidatingFailed         = "ValidatingFailed"
+	ConditionTypeMigrating            = "Migrating"
+	ConditionTypeConfigWarnings       = "ConfigurationWarning"
+	ConditionTypePreconditionsFailed  = "PreconditionsFailed"
+	ConditionTypeRolling              = "RollingDeployment"
+	ConditionTypeRolloutError         = "RolloutError"
+	ConditionTypeVerified             = "Verified"
+	ConditionTypeSchemaApplied        = "SchemaApplied"
+	ConditionTypeDatastoreProvisioned = "DatastoreProvisioned"
+	ConditionTypeEndOfLife            = "EndOfLife"
+
+	ConditionReasonMissingSecret    = "MissingSecret"
+	ConditionReasonMissingConfigMap = "MissingConfigMap"
+
+	ConditionReasonClusterNotReady   = "ClusterNotReady"
+	ConditionReasonDestructiveChange = "DestructiveChange"
+
+	ConditionReasonProvisioning       = "Provisioning"
+	ConditionReasonProvisioned        = "Provisioned"
+	ConditionReasonProvisioningFailed = "ProvisioningFailed"
+
+	ConditionReasonMigrationTimedOut = "MigrationTimedOut"
 )
 
 func NewValidatingConfigCondition(secretHash string) metav1.Condition {
@@ -77,6 +92,20 @@ func NewMigrationFailedCondition(engine, headRevision string, err error) metav1.
 	}
 }
 
+func NewMigrationTimedOutCondition(engine, headRevision string, timeout time.Duration, logs string) metav1.Condition {
+	message := fmt.Sprintf("Migrating %s datastore to %s did not finish within %s", engine, headRevision, timeout)
+	if len(logs) > 0 {
+		message += fmt.Sprintf("; last logs from the migration job:\n%s", logs)
+	}
+	return metav1.Condition{
+		Type:               ConditionTypeMigrating,
+		Status:             metav1.ConditionFalse,
+		Reason:             ConditionReasonMigrationTimedOut,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	}
+}
+
 func NewMissingSecretCondition(nn types.NamespacedName) metav1.Condition {
 	return metav1.Condition{
 		Type:               ConditionTypePreconditionsFailed,
@@ -87,6 +116,16 @@ func NewMissingSecretCondition(nn types.NamespacedName) metav1.Condition {
 	}
 }
 
+func NewMissingConfigMapCondition(nn types.NamespacedName) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypePreconditionsFailed,
+		Status:             metav1.ConditionTrue,
+		Reason:             ConditionReasonMissingConfigMap,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("ConfigMap %s not found", nn.String()),
+	}
+}
+
 func NewRollingCondition(message string) metav1.Condition {
 	return metav1.Condition{
 		Type:               ConditionTypeRolling,
@@ -106,3 +145,114 @@ func NewPodErrorCondition(message string) metav1.Condition {
 		Message:            message,
 	}
 }
+
+func NewVerifiedCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeVerified,
+		Status:             metav1.ConditionTrue,
+		Reason:             "VerificationSucceeded",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	}
+}
+
+func NewVerificationFailedCondition(err error) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeVerified,
+		Status:             metav1.ConditionFalse,
+		Reason:             "VerificationFailed",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Post-rollout verification failed: %s", err),
+	}
+}
+
+func NewSchemaAppliedCondition(schemaHash string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeSchemaApplied,
+		Status:             metav1.ConditionTrue,
+		Reason:             "SchemaWritten",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Wrote schema and relationships with hash %q", schemaHash),
+	}
+}
+
+func NewSchemaApplyFailedCondition(err error) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeSchemaApplied,
+		Status:             metav1.ConditionFalse,
+		Reason:             "SchemaWriteFailed",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Error writing schema: %s", err),
+	}
+}
+
+func NewSchemaWrittenCondition(zedToken string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeSchemaApplied,
+		Status:             metav1.ConditionTrue,
+		Reason:             "SchemaWritten",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Wrote schema at revision %s", zedToken),
+	}
+}
+
+func NewSchemaClusterNotReadyCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeSchemaApplied,
+		Status:             metav1.ConditionFalse,
+		Reason:             ConditionReasonClusterNotReady,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            message,
+	}
+}
+
+func NewDestructiveSchemaChangeCondition(relations []string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeSchemaApplied,
+		Status:             metav1.ConditionFalse,
+		Reason:             ConditionReasonDestructiveChange,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message: fmt.Sprintf("Schema removes relations that still have relationships: %s; annotate with %s=true to delete them",
+			strings.Join(relations, ", "), AllowDestructiveSchemaChangesAnnotation),
+	}
+}
+
+func NewDatastoreProvisioningCondition(engine string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeDatastoreProvisioned,
+		Status:             metav1.ConditionFalse,
+		Reason:             ConditionReasonProvisioning,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Provisioning %s database and user", engine),
+	}
+}
+
+func NewDatastoreProvisionedCondition(engine string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeDatastoreProvisioned,
+		Status:             metav1.ConditionTrue,
+		Reason:             ConditionReasonProvisioned,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Provisioned %s database and user", engine),
+	}
+}
+
+func NewDatastoreProvisioningFailedCondition(engine string, err error) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeDatastoreProvisioned,
+		Status:             metav1.ConditionFalse,
+		Reason:             ConditionReasonProvisioningFailed,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("Error provisioning %s database: %s", engine, err),
+	}
+}
+
+func NewEndOfLifeCondition(version, endOfLife string) metav1.Condition {
+	return metav1.Condition{
+		Type:               ConditionTypeEndOfLife,
+		Status:             metav1.ConditionTrue,
+		Reason:             "VersionEndOfLife",
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Message:            fmt.Sprintf("SpiceDB %s reached end of life on %s and is no longer supported; update to a supported version", version, endOfLife),
+	}
+}
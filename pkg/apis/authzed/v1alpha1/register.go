@@ -31,6 +31,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&SpiceDBCluster{},
 		&SpiceDBClusterList{},
+		&SpiceDBSchema{},
+		&SpiceDBSchemaList{},
+		&SpiceDBChannel{},
+		&SpiceDBChannelList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,110 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	SpiceDBSchemaResourceName = "spicedbschemas"
+	SpiceDBSchemaKind         = "SpiceDBSchema"
+
+	// AllowDestructiveSchemaChangesAnnotation can be set to "true" on a
+	// SpiceDBSchema to allow the operator to delete the relationships for
+	// relations that are removed from the schema.
+	AllowDestructiveSchemaChangesAnnotation = "authzed.com/allow-destructive-schema-changes"
+)
+
+// SpiceDBSchema is an authorization schema that is written to a
+// SpiceDBCluster in the same namespace.
+//
+// +crd
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=authzed
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="Applied",type=string,JSONPath=".status.conditions[?(@.type=='SchemaApplied')].status"
+// +kubebuilder:printcolumn:name="ZedToken",type=string,JSONPath=".status.zedToken",priority=1
+type SpiceDBSchema struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SchemaSpec `json:"spec"`
+
+	// +optional
+	Status SchemaStatus `json:"status,omitempty"`
+}
+
+// SchemaSpec holds the desired schema for a cluster.
+type SchemaSpec struct {
+	// ClusterName is the name of the SpiceDBCluster (in the same namespace)
+	// that the schema is written to.
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+
+	// Schema is the text of the SpiceDB schema.
+	// +kubebuilder:validation:MinLength=1
+	Schema string `json:"schema"`
+}
+
+// SchemaStatus communicates the observed state of the schema.
+type SchemaStatus struct {
+	// ObservedGeneration represents the .metadata.generation that has been
+	// seen by the controller.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// SchemaHash is a digest of the last schema written to the cluster.
+	SchemaHash string `json:"schemaHash,omitempty"`
+
+	// ZedToken is the revision at which the last schema was written.
+	ZedToken string `json:"zedToken,omitempty"`
+
+	// Conditions for the current state of the schema.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// ClusterNN returns the namespaced name of the referenced cluster.
+func (s *SpiceDBSchema) ClusterNN() types.NamespacedName {
+	return types.NamespacedName{
+		Name:      s.Spec.ClusterName,
+		Namespace: s.Namespace,
+	}
+}
+
+// AllowsDestructiveChanges returns true if the schema has been annotated to
+// allow removing relations that still have data.
+func (s *SpiceDBSchema) AllowsDestructiveChanges() bool {
+	return s.GetAnnotations()[AllowDestructiveSchemaChangesAnnotation] == "true"
+}
+
+// FindStatusCondition finds the conditionType in conditions.
+func (s *SpiceDBSchema) FindStatusCondition(conditionType string) *metav1.Condition {
+	return meta.FindStatusCondition(s.Status.Conditions, conditionType)
+}
+
+// SetStatusCondition sets the corresponding condition in conditions to newCondition.
+func (s *SpiceDBSchema) SetStatusCondition(condition metav1.Condition) {
+	meta.SetStatusCondition(&s.Status.Conditions, condition)
+}
+
+// IsStatusConditionTrue returns true when the conditionType is present and set to `metav1.ConditionTrue`
+func (s *SpiceDBSchema) IsStatusConditionTrue(conditionType string) bool {
+	return meta.IsStatusConditionTrue(s.Status.Conditions, conditionType)
+}
+
+// SpiceDBSchemaList is a list of SpiceDBSchema resources
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SpiceDBSchemaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []SpiceDBSchema `json:"items"`
+}
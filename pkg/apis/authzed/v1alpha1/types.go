@@ -2,6 +2,7 @@ package v1alpha1
 
 import (
 	"encoding/json"
+	"strings"
 
 	"golang.org/x/exp/slices"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -60,6 +61,15 @@ func (c *SpiceDBCluster) RolloutInProgress() bool {
 		c.Status.CurrentMigrationHash != c.Status.TargetMigrationHash
 }
 
+// IsSubscribed returns true if the cluster follows the named channel, or is
+// running a version from it.
+func (c *SpiceDBCluster) IsSubscribed(channel string) bool {
+	if strings.EqualFold(c.Spec.Channel, channel) {
+		return true
+	}
+	return c.Status.CurrentVersion != nil && strings.EqualFold(c.Status.CurrentVersion.Channel, channel)
+}
+
 // ClusterSpec holds the desired state of the cluster.
 type ClusterSpec struct {
 	// Version is the name of the version of SpiceDB that will be run.
@@ -67,10 +77,19 @@ type ClusterSpec struct {
 	// operator is configured with a data source that tells it what versions
 	// are allowed, and they may have other names.
 	// If omitted, the newest version in the head of the channel will be used.
+	// A version older than the running version can only be set if the
+	// channel has a rollback edge to it.
 	// Note that the `config.image` field will take precedence over
 	// version/channel, if it is specified
 	Version string `json:"version,omitempty"`
 
+	// VersionConstraint is a semver constraint like `~1.33` or
+	// `>=1.30 <1.35`. The operator keeps SpiceDB up-to-date with the newest
+	// version in the channel that satisfies it. It can't be combined with
+	// `version`.
+	// +optional
+	VersionConstraint string `json:"versionConstraint,omitempty"`
+
 	// Channel is a defined series of updates that operator should follow.
 	// The operator is configured with a datasource that configures available
 	// channels and update paths.
@@ -98,21 +117,187 @@ type ClusterSpec struct {
 	// in the list take precedence over earlier ones.
 	// +optional
 	Patches []Patch `json:"patches,omitempty"`
+
+	// Schema is a SpiceDB schema (and optional set of relationships) that
+	// the operator writes to the cluster once it is available, and re-applies
+	// whenever it changes.
+	// +optional
+	Schema *SchemaSource `json:"schema,omitempty"`
+}
+
+// SchemaSource is where the operator finds the schema and relationships to
+// bootstrap a cluster with. The schema can either be specified inline or
+// read from a ConfigMap in the same namespace, but not both.
+type SchemaSource struct {
+	// Inline is the text of a SpiceDB schema.
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
+	// ConfigMapName is the name of a ConfigMap (in the same namespace) that
+	// holds the schema and, optionally, relationships.
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// SchemaKey is the key in the ConfigMap that holds the schema.
+	// Defaults to `schema`.
+	// +optional
+	SchemaKey string `json:"schemaKey,omitempty"`
+
+	// RelationshipsKey is the key in the ConfigMap that holds relationships
+	// to seed the cluster with. Defaults to `relationships`; the key may be
+	// absent.
+	// +optional
+	RelationshipsKey string `json:"relationshipsKey,omitempty"`
+
+	// Relationships is an inline list of relationships to seed the cluster
+	// with, one per line, in the form
+	// `resource:id#relation@subject:id[#relation]`. Relationships are
+	// touched, so they are created if missing and never removed.
+	// +optional
+	Relationships string `json:"relationships,omitempty"`
 }
 
 // Patch represents a single change to apply to generated manifests
 type Patch struct {
-	// Kind targets an object by its kubernetes Kind name.
+	// Kind targets an object by its kubernetes Kind name. If omitted, a patch
+	// with a name, labelSelector or component applies to objects of any kind.
 	// +optional
 	Kind string `json:"kind,omitempty"`
 
+	// Name targets a single object by its name.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// LabelSelector targets objects by their labels.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// Component targets objects by the cluster component that the operator
+	// labels them with, for example "spicedb", "spicedb-dispatch" or
+	// "migration-job".
+	// +optional
+	Component string `json:"component,omitempty"`
+
 	// Patch is an inlined representation of a structured merge patch (one that
-	// just specifies the structure and fields to be modified) or a an explicit
-	// JSON6902 patch operation.
+	// just specifies the structure and fields to be modified), an explicit
+	// JSON6902 patch operation, or a list of JSON6902 patch operations.
+	// If template is set, the patch may use Go templates to refer to the
+	// cluster, e.g. `{{ .Cluster.Name }}`, `{{ .Cluster.Namespace }}`,
+	// `{{ .TargetVersion }}`, `{{ .TargetImage }}`, `{{ .TargetMigration }}`,
+	// `{{ .TargetPhase }}`, `{{ .Channel }}` or `{{ .Engine }}`.
 	// +kubebuilder:validation:Schemaless
 	// +kubebuilder:pruning:PreserveUnknownFields
-	// +kubebuilder:validation:Type=object
 	Patch json.RawMessage `json:"patch"`
+
+	// Template renders the patch as a Go template before it is applied.
+	// Patches are applied as written by default, so values that contain
+	// `{{`, like annotations for other templating tools, are left alone.
+	// +optional
+	Template bool `json:"template,omitempty"`
+}
+
+// PatchResult is the outcome of applying a patch to the generated objects.
+type PatchResult string
+
+const (
+	// PatchResultApplied means the patch changed at least one object.
+	PatchResultApplied PatchResult = "Applied"
+	// PatchResultNoOp means the patch matched objects but didn't change them.
+	PatchResultNoOp PatchResult = "NoOp"
+	// PatchResultUnmatched means the patch didn't match any objects.
+	PatchResultUnmatched PatchResult = "Unmatched"
+	// PatchResultError means the patch couldn't be applied.
+	PatchResultError PatchResult = "Error"
+)
+
+// PatchSource identifies where a patch was defined.
+type PatchSource string
+
+const (
+	// PatchSourceOperatorDefaults are patches from the operator config that
+	// are applied before spec.patches.
+	PatchSourceOperatorDefaults PatchSource = "OperatorDefaults"
+	// PatchSourceOperatorOverrides are patches from the operator config that
+	// are applied after spec.patches.
+	PatchSourceOperatorOverrides PatchSource = "OperatorOverrides"
+)
+
+// PatchStatus reports how one of spec.patches, or one of the patches from
+// the operator config, applied to the generated objects.
+type PatchStatus struct {
+	// Index is the position of the patch in spec.patches, or in the list of
+	// operator patches named by Source.
+	Index int `json:"index"`
+
+	// Source is empty for spec.patches, otherwise it's OperatorDefaults or
+	// OperatorOverrides.
+	// +optional
+	Source PatchSource `json:"source,omitempty"`
+
+	// Result is one of Applied, NoOp, Unmatched or Error.
+	Result PatchResult `json:"result"`
+
+	// Kinds are the kinds of the objects that the patch changed.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Message describes why the patch didn't apply.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+func (p PatchStatus) Equals(other PatchStatus) bool {
+	return p.Index == other.Index &&
+		p.Source == other.Source &&
+		p.Result == other.Result &&
+		slices.Equal(p.Kinds, other.Kinds) &&
+		p.Message == other.Message
+}
+
+// OperatorConfigStatus lists the config keys that came from the operator
+// config rather than from spec.config.
+type OperatorConfigStatus struct {
+	// DefaultedKeys are keys that spec.config didn't set, so the operator
+	// default was used.
+	// +optional
+	DefaultedKeys []string `json:"defaultedKeys,omitempty"`
+
+	// OverriddenKeys are keys that the operator enforces, regardless of
+	// spec.config.
+	// +optional
+	OverriddenKeys []string `json:"overriddenKeys,omitempty"`
+}
+
+func (o *OperatorConfigStatus) Equals(other *OperatorConfigStatus) bool {
+	if o == other {
+		return true
+	}
+	return o != nil && other != nil &&
+		slices.Equal(o.DefaultedKeys, other.DefaultedKeys) &&
+		slices.Equal(o.OverriddenKeys, other.OverriddenKeys)
+}
+
+// BlueGreenStatus tracks which Deployment of a blue/green cluster is
+// serving traffic.
+type BlueGreenStatus struct {
+	// ActiveDeployment is the name of the Deployment that the Service
+	// selects.
+	ActiveDeployment string `json:"activeDeployment"`
+
+	// SwitchedAt is when the Service was seen selecting the active
+	// Deployment. The other Deployments are removed once they've been
+	// drained.
+	// +optional
+	SwitchedAt *metav1.Time `json:"switchedAt,omitempty"`
+}
+
+func (b *BlueGreenStatus) Equals(other *BlueGreenStatus) bool {
+	if b == other {
+		return true
+	}
+	return b != nil && other != nil &&
+		b.ActiveDeployment == other.ActiveDeployment &&
+		b.SwitchedAt.Equal(other.SwitchedAt)
 }
 
 // ClusterStatus communicates the observed state of the cluster.
@@ -151,6 +336,32 @@ type ClusterStatus struct {
 	// version can be updated to. Only applies if using an update channel.
 	AvailableVersions []SpiceDBVersion `json:"availableVersions,omitempty"`
 
+	// PlannedPath is the sequence of updates that remain to reach the desired
+	// version (or the head of the channel) from the current version. Steps
+	// that run a migration have the migration attribute.
+	// +optional
+	PlannedPath []SpiceDBVersion `json:"plannedPath,omitempty"`
+
+	// SchemaHash is a digest of the last schema and relationships written
+	// to the cluster from `spec.schema`.
+	SchemaHash string `json:"schemaHash,omitempty"`
+
+	// Patches reports how each of spec.patches applied to the generated
+	// objects.
+	// +optional
+	Patches []PatchStatus `json:"patches,omitempty"`
+
+	// OperatorConfig reports which config keys were set by the operator
+	// config. Per-cluster config takes precedence over operator defaults,
+	// and operator overrides take precedence over per-cluster config.
+	// +optional
+	OperatorConfig *OperatorConfigStatus `json:"operatorConfig,omitempty"`
+
+	// BlueGreen is the state of the blue/green rollout, if the cluster uses
+	// the blueGreen rollout strategy.
+	// +optional
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+
 	// Conditions for the current state of the Stack.
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
@@ -165,10 +376,17 @@ func (s ClusterStatus) Equals(other ClusterStatus) bool {
 		s.Image == other.Image &&
 		s.Migration == other.Migration &&
 		s.Phase == other.Phase &&
+		s.SchemaHash == other.SchemaHash &&
 		s.CurrentVersion.Equals(other.CurrentVersion) &&
 		slices.EqualFunc(s.AvailableVersions, other.AvailableVersions, func(a, b SpiceDBVersion) bool {
 			return a.Equals(&b)
 		}) &&
+		slices.EqualFunc(s.Patches, other.Patches, PatchStatus.Equals) &&
+		s.OperatorConfig.Equals(other.OperatorConfig) &&
+		s.BlueGreen.Equals(other.BlueGreen) &&
+		slices.EqualFunc(s.PlannedPath, other.PlannedPath, func(a, b SpiceDBVersion) bool {
+			return a.Equals(&b)
+		}) &&
 		slices.Equal(s.Conditions, other.Conditions):
 		return true
 	default:
@@ -184,6 +402,11 @@ var (
 	SpiceDBVersionAttributesIncompatibleDispatch SpiceDBVersionAttributes = "incompatibleDispatch"
 	SpiceDBVersionAttributesLatest               SpiceDBVersionAttributes = "latest"
 	SpiceDBVersionAttributesNotInChannel         SpiceDBVersionAttributes = "notInDesiredChannel"
+	SpiceDBVersionAttributesSatisfiesConstraint  SpiceDBVersionAttributes = "satisfiesConstraint"
+	SpiceDBVersionAttributesOutsideConstraint    SpiceDBVersionAttributes = "outsideConstraint"
+	SpiceDBVersionAttributesRollback             SpiceDBVersionAttributes = "rollback"
+	SpiceDBVersionAttributesDeprecated           SpiceDBVersionAttributes = "deprecated"
+	SpiceDBVersionAttributesEndOfLife            SpiceDBVersionAttributes = "endOfLife"
 )
 
 type SpiceDBVersion struct {
@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/authzed/spicedb-operator/pkg/config"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+// Options contains the input to the graph command.
+type Options struct {
+	GraphPath     string
+	ValidatedPath string
+	Datastore     string
+	Channel       string
+	Format        string
+}
+
+// RecommendedOptions builds a new options config with default values
+func RecommendedOptions() *Options {
+	return &Options{
+		GraphPath: "proposed-update-graph.yaml",
+		Format:    string(updates.GraphFormatDOT),
+	}
+}
+
+// NewCmdGraph creates a command object for "graph"
+func NewCmdGraph(o *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "graph [flags]",
+		DisableFlagsInUseLine: true,
+		Short:                 "render an update graph channel as Graphviz DOT or Mermaid",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run(cmd.OutOrStdout()))
+		},
+	}
+
+	cmd.Flags().StringVar(&o.GraphPath, "graph", o.GraphPath, "path to the operator config file that holds the update graph")
+	cmd.Flags().StringVar(&o.ValidatedPath, "diff", o.ValidatedPath, "if set, highlight the edges that aren't in this update graph (usually validated-update-graph.yaml)")
+	cmd.Flags().StringVar(&o.Datastore, "datastore", o.Datastore, "datastore engine of the channel to render")
+	cmd.Flags().StringVar(&o.Channel, "channel", o.Channel, "name of the channel to render, defaults to the datastore's default channel")
+	cmd.Flags().StringVar(&o.Format, "format", o.Format, "output format, one of: dot, mermaid")
+
+	return cmd
+}
+
+// Validate checks the set of flags provided by the user.
+func (o *Options) Validate() error {
+	if o.Datastore == "" {
+		return fmt.Errorf("--datastore is required")
+	}
+	switch updates.GraphFormat(o.Format) {
+	case updates.GraphFormatDOT, updates.GraphFormatMermaid:
+	default:
+		return fmt.Errorf("unknown format %q, must be one of: dot, mermaid", o.Format)
+	}
+	return nil
+}
+
+// Run renders the requested channel to out.
+func (o *Options) Run(out io.Writer) error {
+	graph, err := loadUpdateGraph(o.GraphPath)
+	if err != nil {
+		return err
+	}
+
+	name := o.Channel
+	if name == "" {
+		name, err = graph.DefaultChannelForDatastore(o.Datastore)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := updates.RenderOptions{
+		Format: updates.GraphFormat(o.Format),
+		Now:    time.Now(),
+	}
+	if o.ValidatedPath != "" {
+		validated, err := loadUpdateGraph(o.ValidatedPath)
+		if err != nil {
+			return err
+		}
+		opts.Highlight = graph.AddedEdges(o.Datastore, name, validated)
+	}
+
+	return graph.RenderChannel(out, o.Datastore, name, opts)
+}
+
+// loadUpdateGraph reads the update graph out of an operator config file.
+func loadUpdateGraph(path string) (*updates.UpdateGraph, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config.OperatorConfig
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(contents), 100).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("error reading update graph from %s: %w", path, err)
+	}
+	return &cfg.UpdateGraph, nil
+}
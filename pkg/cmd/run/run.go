@@ -154,6 +154,12 @@ func (o *Options) Run(ctx context.Context, f cmdutil.Factory) error {
 	}
 	controllers = append(controllers, ctrl)
 
+	schemaCtrl, err := controller.NewSchemaController(ctx, registry, dclient, broadcaster)
+	if err != nil {
+		return err
+	}
+	controllers = append(controllers, schemaCtrl)
+
 	// register with metrics collector
 	spiceDBClusterMetrics := ctrlmetrics.NewConditionStatusCollector[*v1alpha1.SpiceDBCluster](o.MetricNamespace, "clusters", v1alpha1.SpiceDBClusterResourceName)
 	lister := typed.ListerFor[*v1alpha1.SpiceDBCluster](registry, typed.NewRegistryKey(controller.OwnedFactoryKey, v1alpha1ClusterGVR))
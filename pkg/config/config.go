@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/authzed/controller-idioms/hash"
 	jsonpatch "github.com/evanphx/json-patch"
@@ -27,6 +30,8 @@ import (
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
 	"github.com/authzed/spicedb-operator/pkg/metadata"
+	"github.com/authzed/spicedb-operator/pkg/spicedb"
+	"github.com/authzed/spicedb-operator/pkg/updates"
 )
 
 const (
@@ -49,8 +54,11 @@ const (
 	spannerCredsFileName = "credentials.json"
 
 	ContainerNameSpiceDB = "spicedb"
+	ContainerNameMigrate = "migrate"
 )
 
+var replicaKeyRegex = regexp.MustCompile(`^datastore_replica_uri_(0|[1-9][0-9]*)$`)
+
 type key[V comparable] struct {
 	key          string
 	defaultValue V
@@ -89,6 +97,27 @@ var (
 	httpTLSCertPathKey                = newKey("httpTLSCertPath", DefaultTLSCrtFile)
 	dashboardTLSKeyPathKey            = newKey("dashboardTLSKeyPath", DefaultTLSKeyFile)
 	dashboardTLSCertPathKey           = newKey("dashboardTLSCertPath", DefaultTLSCrtFile)
+	verifyRolloutKey                  = newBoolOrStringKey("verifyRollout", false)
+	verifyRolloutCheckKey             = newStringKey("verifyRolloutCheck")
+	verifyRolloutPauseOnFailureKey    = newBoolOrStringKey("verifyRolloutPauseOnFailure", false)
+	provisioningSecretNameKey         = newStringKey("datastoreProvisioningSecretName")
+	provisioningImageKey              = newStringKey("datastoreProvisioningImage")
+	provisioningDatabaseKey           = newStringKey("datastoreProvisioningDatabase")
+	provisioningUserKey               = newStringKey("datastoreProvisioningUser")
+	dispatchTierReplicasKey           = newIntOrStringKey[int32]("dispatchTierReplicas", 0)
+	dispatchTierResourcesKey          = jsonKey[corev1.ResourceRequirements]("dispatchTierResources")
+	resourcesKey                      = jsonKey[corev1.ResourceRequirements]("resources")
+	nodeSelectorKey                   = jsonKey[map[string]string]("nodeSelector")
+	tolerationsKey                    = jsonKey[[]corev1.Toleration]("tolerations")
+	affinityKey                       = jsonKey[*corev1.Affinity]("affinity")
+	topologySpreadConstraintsKey      = jsonKey[[]corev1.TopologySpreadConstraint]("topologySpreadConstraints")
+	skipPodSecurityDefaultsKey        = newBoolOrStringKey("skipPodSecurityDefaults", false)
+	migrationTimeoutKey               = durationKey("migrationTimeout")
+	migrationBackoffLimitKey          = newIntOrStringKey[int32]("migrationBackoffLimit", 0)
+	migrationJobTTLKey                = durationKey("migrationJobTTL")
+	migrationResourcesKey             = jsonKey[corev1.ResourceRequirements]("migrationResources")
+	rolloutStrategyKey                = newStringKey("rolloutStrategy")
+	blueGreenDrainDurationKey         = durationKey("blueGreenDrainDuration")
 )
 
 // Warning is an issue with configuration that we will report as undesirable
@@ -118,8 +147,10 @@ func (r RawConfig) Pop(key string) string {
 type Config struct {
 	MigrationConfig
 	SpiceConfig
-	Patches   []v1alpha1.Patch
-	Resources openapi.Resources
+	Patches              []v1alpha1.Patch
+	PatchStatuses        []v1alpha1.PatchStatus
+	OperatorConfigStatus *v1alpha1.OperatorConfigStatus
+	Resources            openapi.Resources
 }
 
 // MigrationConfig stores data that is relevant for running migrations
@@ -163,10 +194,23 @@ type SpiceConfig struct {
 	ProjectLabels                  bool
 	ProjectAnnotations             bool
 	Passthrough                    map[string]string
+	VerifyRollout                  bool
+	VerifyRolloutCheck             string
+	VerifyRolloutPauseOnFailure    bool
+	DatastoreProvisioning          DatastoreProvisioningConfig
+	DatastoreReplicaKeys           []string
+	DispatchTier                   DispatchTierConfig
+	DispatchIsolation              string
+	Rollout                        RolloutConfig
+	Scheduling                     SchedulingConfig
+	SkipPodSecurityDefaults        bool
+	MigrationJobSettings           MigrationJobConfig
+	ImagePullSecrets               []string
 }
 
-// NewConfig checks that the values in the config + the secret are sane
-func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, secret *corev1.Secret, resources openapi.Resources) (*Config, Warning, error) {
+// NewConfig checks that the values in the config + the secret are sane. now
+// is used to decide which versions in the update graph are deprecated.
+func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, secret *corev1.Secret, resources openapi.Resources, now time.Time) (*Config, Warning, error) {
 	if cluster.Spec.Config == nil {
 		return nil, nil, fmt.Errorf("couldn't parse empty config")
 	}
@@ -176,9 +220,13 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 		return nil, nil, fmt.Errorf("couldn't parse config: %w", err)
 	}
 
+	operatorConfigStatus, warnings, err := applyOperatorConfig(config, globalConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	passthroughConfig := make(map[string]string)
 	errs := make([]error, 0)
-	warnings := make([]error, 0)
 
 	spiceConfig := SpiceConfig{
 		Name:                         cluster.Name,
@@ -208,7 +256,6 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 		errs = append(errs, fmt.Errorf("datastoreEngine is a required field"))
 	}
 
-	var err error
 	spiceConfig.ProjectLabels, err = projectLabels.pop(config)
 	if err != nil {
 		warnings = append(warnings, fmt.Errorf("defaulting to false: %w", err))
@@ -222,9 +269,17 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 	// unless the current config is equal to the input.
 	image := imageKey.pop(config)
 
-	baseImage, targetSpiceDBVersion, state, err := globalConfig.ComputeTarget(globalConfig.ImageName, image, cluster.Spec.Version, cluster.Spec.Channel, datastoreEngine, cluster.Status.CurrentVersion, cluster.RolloutInProgress())
+	baseImage, targetSpiceDBVersion, state, err := globalConfig.ComputeTarget(globalConfig.ImageName, image, cluster.Spec.Version, cluster.Spec.VersionConstraint, cluster.Spec.Channel, datastoreEngine, cluster.Status.CurrentVersion, cluster.RolloutInProgress(), now)
 	if err != nil {
 		errs = append(errs, err)
+	} else if current := cluster.Status.CurrentVersion; current != nil && len(current.Name) > 0 && len(cluster.Spec.VersionConstraint) > 0 {
+		if ok, _ := updates.SatisfiesConstraint(cluster.Spec.VersionConstraint, current.Name); !ok {
+			warning := fmt.Sprintf("current version %s doesn't satisfy versionConstraint %q", current.Name, cluster.Spec.VersionConstraint)
+			if targetSpiceDBVersion.Name != current.Name {
+				warning += ", rolling back to " + targetSpiceDBVersion.Name
+			}
+			warnings = append(warnings, fmt.Errorf("%s", warning))
+		}
 	}
 
 	migrationConfig.SpiceDBVersion = targetSpiceDBVersion
@@ -246,6 +301,7 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 	default:
 		errs = append(errs, fmt.Errorf("no update found in channel"))
 	}
+	migrationConfig.TargetSpiceDBImage = globalConfig.RewriteImage(migrationConfig.TargetSpiceDBImage)
 
 	spiceConfig.DispatchEnabled, err = dispatchEnabledKey.pop(config)
 	if err != nil {
@@ -256,11 +312,19 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 	if datastoreEngine == "memory" {
 		spiceConfig.DispatchEnabled = false
 	}
+	spiceConfig.DispatchIsolation = dispatchIsolation(spiceConfig.DispatchEnabled, targetSpiceDBVersion)
 
 	migrationConfig.DatastoreEngine = datastoreEngine
 	passthroughConfig["datastoreEngine"] = datastoreEngine
 	passthroughConfig["dispatchClusterEnabled"] = strconv.FormatBool(spiceConfig.DispatchEnabled)
 
+	spiceConfig.DatastoreProvisioning, err = newDatastoreProvisioningConfig(cluster.Name, datastoreEngine, config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	spiceConfig.DatastoreProvisioning.Image = globalConfig.RewriteImage(spiceConfig.DatastoreProvisioning.Image)
+	spiceConfig.ImagePullSecrets = slices.Clone(globalConfig.ImagePullSecrets)
+
 	if secret == nil {
 		errs = append(errs, fmt.Errorf("secret must be provided"))
 	}
@@ -271,7 +335,8 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 
 		var ok bool
 		datastoreURI, ok = secret.Data["datastore_uri"]
-		if !ok && datastoreEngine != "memory" {
+		// a provisioned datastore_uri is written by the operator
+		if !ok && datastoreEngine != "memory" && !spiceConfig.DatastoreProvisioning.Enabled() {
 			errs = append(errs, fmt.Errorf("secret must contain a datastore_uri field"))
 		}
 		migrationConfig.DatastoreURI = string(datastoreURI)
@@ -280,6 +345,12 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 			errs = append(errs, fmt.Errorf("secret must contain a preshared_key field"))
 		}
 		spiceConfig.PresharedKey = string(psk)
+
+		spiceConfig.DatastoreReplicaKeys = datastoreReplicaKeys(secret)
+		if len(spiceConfig.DatastoreReplicaKeys) > 0 && datastoreEngine != "postgres" && datastoreEngine != "mysql" {
+			warnings = append(warnings, fmt.Errorf("ignoring %d read replica uris: read replicas are only supported for postgres and mysql", len(spiceConfig.DatastoreReplicaKeys)))
+			spiceConfig.DatastoreReplicaKeys = nil
+		}
 	}
 
 	if len(migrationConfig.SpannerCredsSecretRef) > 0 {
@@ -300,11 +371,57 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 	if replicas > 1 && datastoreEngine == "memory" {
 		errs = append(errs, fmt.Errorf("cannot set replicas > 1 for memory engine"))
 	}
+
+	spiceConfig.DispatchTier, err = newDispatchTierConfig(spiceConfig.DispatchEnabled, config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	spiceConfig.Rollout, err = newRolloutConfig(config, cluster.Status, migrationConfig.TargetSpiceDBImage, spiceConfig.DispatchTier)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	// each color dispatches only within itself
+	if spiceConfig.Rollout.BlueGreen() && spiceConfig.DispatchEnabled {
+		spiceConfig.DispatchIsolation = spiceConfig.Rollout.Color
+	}
+
+	var schedulingErrs []error
+	spiceConfig.Scheduling, schedulingErrs = newSchedulingConfig(config)
+	errs = append(errs, schedulingErrs...)
+
+	var migrationJobErrs []error
+	spiceConfig.MigrationJobSettings, migrationJobErrs = newMigrationJobConfig(config)
+	errs = append(errs, migrationJobErrs...)
+
 	spiceConfig.SkipMigrations, err = skipMigrationsKey.pop(config)
 	if err != nil {
 		errs = append(errs, err)
 	}
 
+	spiceConfig.SkipPodSecurityDefaults, err = skipPodSecurityDefaultsKey.pop(config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	spiceConfig.VerifyRollout, err = verifyRolloutKey.pop(config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	spiceConfig.VerifyRolloutPauseOnFailure, err = verifyRolloutPauseOnFailureKey.pop(config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	spiceConfig.VerifyRolloutCheck = verifyRolloutCheckKey.pop(config)
+	if len(spiceConfig.VerifyRolloutCheck) > 0 {
+		if _, err := spicedb.ParseCheck(spiceConfig.VerifyRolloutCheck); err != nil {
+			errs = append(errs, err)
+		}
+		if !spiceConfig.VerifyRollout {
+			warnings = append(warnings, fmt.Errorf("%q has no effect unless %q is enabled", verifyRolloutCheckKey.key, verifyRolloutKey.key))
+		}
+	}
+
 	var labelWarnings []error
 	spiceConfig.ExtraPodLabels, labelWarnings, err = extraPodLabelsKey.pop(config, "pod", "label")
 	if err != nil {
@@ -376,6 +493,7 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 		"presharedKey",
 		"preshared_key",
 		"datastore_uri",
+		"datastoreReadReplicaConnUri",
 	}
 	// strip sensitive values from passthrough config (if they have been
 	// inadvertently set by a user)
@@ -395,36 +513,51 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 	spiceConfig.Passthrough = passthroughConfig
 
 	out := &Config{
-		MigrationConfig: migrationConfig,
-		SpiceConfig:     spiceConfig,
-		Resources:       resources,
+		MigrationConfig:      migrationConfig,
+		SpiceConfig:          spiceConfig,
+		Resources:            resources,
+		OperatorConfigStatus: operatorConfigStatus,
 	}
-	out.Patches = fixDeploymentPatches(out.Name, cluster.Spec.Patches)
+	patches, refs := operatorPatches(globalConfig, cluster.Spec.Patches)
+	out.Patches = fixDeploymentPatches(out.Name, patches)
 
 	// Validate that patches apply cleanly ahead of time
-	totalAppliedPatches := 0
-	for _, obj := range []any{
+	patchable := []any{
 		out.unpatchedServiceAccount(),
 		out.unpatchedRole(),
 		out.unpatchedRoleBinding(),
 		out.unpatchedService(),
 		out.unpatchedMigrationJob(hash.Object("")),
 		out.unpatchedDeployment(hash.Object(""), hash.Object("")),
-	} {
-		applied, diff, err := ApplyPatches(obj, obj, out.Patches, resources)
-		if err != nil {
-			errs = append(errs, err)
-		}
-
-		if applied > 0 && !diff {
-			warnings = append(warnings, fmt.Errorf("patches applied to object, but there were no changes to the object"))
+	}
+	if out.DatastoreProvisioning.Enabled() {
+		patchable = append(patchable, out.unpatchedProvisioningJob())
+	}
+	if out.DispatchTier.Enabled() {
+		patchable = append(patchable,
+			out.unpatchedDispatchService(),
+			out.unpatchedTierDeployment(out.dispatchTier(), hash.Object(""), hash.Object("")),
+		)
+	}
+	if len(out.DispatchIsolation) > 0 {
+		patchable = append(patchable, out.unpatchedIsolatedDispatchService())
+	}
+	patchData := out.patchTemplateData()
+	if templateErrs := validatePatchTemplates(out.Patches, refs, patchData); len(templateErrs) > 0 {
+		errs = append(errs, templateErrs...)
+	} else {
+		var patchErrs []error
+		out.PatchStatuses, patchErrs = reportPatches(patchable, out.Patches, refs, patchData, resources)
+		errs = append(errs, patchErrs...)
+		for _, s := range out.PatchStatuses {
+			if s.Result == v1alpha1.PatchResultNoOp || s.Result == v1alpha1.PatchResultUnmatched {
+				warnings = append(warnings, fmt.Errorf("%s %s", patchRef{source: s.Source, index: s.Index}, s.Message))
+			}
 		}
-
-		totalAppliedPatches += applied
 	}
 
-	if totalAppliedPatches < len(out.Patches) {
-		warnings = append(warnings, fmt.Errorf("only %d/%d patches applied successfully", totalAppliedPatches, len(out.Patches)))
+	if len(errs) == 0 {
+		warnings = append(warnings, out.podSecurityWarnings(hash.Object(""), hash.Object(""))...)
 	}
 
 	warning := Warning(errors.NewAggregate(warnings))
@@ -435,9 +568,38 @@ func NewConfig(cluster *v1alpha1.SpiceDBCluster, globalConfig *OperatorConfig, s
 	return out, warning, nil
 }
 
+// datastoreReplicaKeys returns the keys in the secret that hold read replica
+// uris (datastore_replica_uri_0..N), ordered by index.
+func datastoreReplicaKeys(secret *corev1.Secret) []string {
+	indexes := make([]int, 0)
+	for k := range secret.Data {
+		if m := replicaKeyRegex.FindStringSubmatch(k); m != nil {
+			i, err := strconv.Atoi(m[1])
+			if err != nil {
+				continue
+			}
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	sort.Ints(indexes)
+	keys := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		keys = append(keys, fmt.Sprintf("datastore_replica_uri_%d", i))
+	}
+	return keys
+}
+
 // toEnvVarApplyConfiguration returns a set of env variables to apply to a
-// spicedb container
+// spicedb container in the api tier
 func (c *Config) toEnvVarApplyConfiguration() []*applycorev1.EnvVarApplyConfiguration {
+	return c.tierEnvVars(c.apiTier())
+}
+
+// tierEnvVars returns the env variables for the spicedb container of a tier
+func (c *Config) tierEnvVars(tier deploymentTier) []*applycorev1.EnvVarApplyConfiguration {
 	// Set non-passthrough config that is either generated directly by the
 	// controller (dispatch address), has some direct effect on the cluster
 	// (tls), or lives in an external secret (preshared key).
@@ -454,10 +616,22 @@ func (c *Config) toEnvVarApplyConfiguration() []*applycorev1.EnvVarApplyConfigur
 			applycorev1.EnvVar().WithName(c.SpiceConfig.EnvPrefix+"_DATASTORE_CONN_URI").WithValueFrom(applycorev1.EnvVarSource().WithSecretKeyRef(
 				applycorev1.SecretKeySelector().WithName(c.SecretName).WithKey("datastore_uri"))))
 	}
+	if len(c.DatastoreReplicaKeys) > 0 {
+		// spicedb takes a single comma-separated list of replicas; each one
+		// is read from the secret and then referenced by name
+		refs := make([]string, 0, len(c.DatastoreReplicaKeys))
+		for i, k := range c.DatastoreReplicaKeys {
+			name := fmt.Sprintf("%s_DATASTORE_READ_REPLICA_CONN_URI_%d", c.SpiceConfig.EnvPrefix, i)
+			envVars = append(envVars, applycorev1.EnvVar().WithName(name).WithValueFrom(applycorev1.EnvVarSource().WithSecretKeyRef(
+				applycorev1.SecretKeySelector().WithName(c.SecretName).WithKey(k))))
+			refs = append(refs, "$("+name+")")
+		}
+		envVars = append(envVars, applycorev1.EnvVar().WithName(c.SpiceConfig.EnvPrefix+"_DATASTORE_READ_REPLICA_CONN_URI").WithValue(strings.Join(refs, ",")))
+	}
 	if c.DispatchEnabled {
 		envVars = append(envVars,
 			applycorev1.EnvVar().WithName(c.SpiceConfig.EnvPrefix+"_DISPATCH_UPSTREAM_ADDR").
-				WithValue(fmt.Sprintf("kubernetes:///%s.%s:dispatch", c.Name, c.Namespace)))
+				WithValue(fmt.Sprintf("kubernetes:///%s.%s:dispatch", tier.dispatchUpstream, c.Namespace)))
 	}
 
 	// Passthrough config is user-provided and only affects spicedb runtime.
@@ -468,8 +642,13 @@ func (c *Config) toEnvVarApplyConfiguration() []*applycorev1.EnvVarApplyConfigur
 	sort.Strings(keys)
 
 	for _, k := range keys {
+		value := c.Passthrough[k]
+		// with a separate dispatch tier, only the dispatch pods serve dispatch
+		if k == "dispatchClusterEnabled" && c.DispatchTier.Enabled() {
+			value = strconv.FormatBool(tier.serveDispatch)
+		}
 		envVars = append(envVars, applycorev1.EnvVar().
-			WithName(toEnvVarName(c.SpiceConfig.EnvPrefix, k)).WithValue(c.Passthrough[k]))
+			WithName(toEnvVarName(c.SpiceConfig.EnvPrefix, k)).WithValue(value))
 	}
 
 	return envVars
@@ -491,7 +670,7 @@ func (c *Config) unpatchedServiceAccount() *applycorev1.ServiceAccountApplyConfi
 
 func (c *Config) ServiceAccount() *applycorev1.ServiceAccountApplyConfiguration {
 	sa := applycorev1.ServiceAccount(c.ServiceAccountName, c.Namespace)
-	_, _, _ = ApplyPatches(c.unpatchedServiceAccount(), sa, c.Patches, c.Resources)
+	_, _, _ = ApplyPatches(c.unpatchedServiceAccount(), sa, c.Patches, c.patchTemplateData(), c.Resources)
 
 	// ensure patches don't overwrite anything critical for operator function
 	sa.WithName(c.ServiceAccountName).WithNamespace(c.Namespace).
@@ -513,7 +692,7 @@ func (c *Config) unpatchedRole() *applyrbacv1.RoleApplyConfiguration {
 
 func (c *Config) Role() *applyrbacv1.RoleApplyConfiguration {
 	role := applyrbacv1.Role(c.Name, c.Namespace)
-	_, _, _ = ApplyPatches(c.unpatchedRole(), role, c.Patches, c.Resources)
+	_, _, _ = ApplyPatches(c.unpatchedRole(), role, c.Patches, c.patchTemplateData(), c.Resources)
 
 	// ensure patches don't overwrite anything critical for operator function
 	role.WithName(c.Name).WithNamespace(c.Namespace).
@@ -536,7 +715,7 @@ func (c *Config) unpatchedRoleBinding() *applyrbacv1.RoleBindingApplyConfigurati
 
 func (c *Config) RoleBinding() *applyrbacv1.RoleBindingApplyConfiguration {
 	rb := applyrbacv1.RoleBinding(c.Name, c.Namespace)
-	_, _, _ = ApplyPatches(c.unpatchedRoleBinding(), rb, c.Patches, c.Resources)
+	_, _, _ = ApplyPatches(c.unpatchedRoleBinding(), rb, c.Patches, c.patchTemplateData(), c.Resources)
 
 	// ensure patches don't overwrite anything critical for operator function
 	rb.WithName(c.Name).WithNamespace(c.Namespace).
@@ -549,20 +728,20 @@ func (c *Config) unpatchedService() *applycorev1.ServiceApplyConfiguration {
 	return applycorev1.Service(c.Name, c.Namespace).
 		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentServiceLabel)).
 		WithSpec(applycorev1.ServiceSpec().
-			WithSelector(metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue)).
+			WithSelector(c.podSelector()).
 			WithPorts(c.servicePorts()...),
 		)
 }
 
 func (c *Config) Service() *applycorev1.ServiceApplyConfiguration {
 	s := applycorev1.Service(c.Name, c.Namespace)
-	_, _, _ = ApplyPatches(c.unpatchedService(), s, c.Patches, c.Resources)
+	_, _, _ = ApplyPatches(c.unpatchedService(), s, c.Patches, c.patchTemplateData(), c.Resources)
 
 	// ensure patches don't overwrite anything critical for operator function
 	s.WithName(c.Name).WithNamespace(c.Namespace).
 		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentServiceLabel)).
 		WithOwnerReferences(c.ownerRef())
-	s.Spec.WithSelector(metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue))
+	s.Spec.WithSelector(c.podSelector())
 	return s
 }
 
@@ -573,7 +752,7 @@ func (c *Config) servicePorts() []*applycorev1.ServicePortApplyConfiguration {
 		applycorev1.ServicePort().WithName("metrics").WithPort(9090),
 	}
 	if c.DispatchEnabled {
-		ports = append(ports, applycorev1.ServicePort().WithName("dispatch").WithPort(50053))
+		ports = append(ports, applycorev1.ServicePort().WithName("dispatch").WithPort(50053).WithTargetPort(intstr.FromString(dispatchPortName)))
 	}
 	return ports
 }
@@ -669,29 +848,32 @@ func (c *Config) unpatchedMigrationJob(migrationHash string) *applybatchv1.JobAp
 		WithAnnotations(map[string]string{
 			metadata.SpiceDBMigrationRequirementsKey: migrationHash,
 		}).
-		WithSpec(applybatchv1.JobSpec().WithTemplate(
+		WithSpec(c.applyMigrationJobSettings(applybatchv1.JobSpec()).WithTemplate(
 			applycorev1.PodTemplateSpec().WithLabels(
 				metadata.LabelsForComponent(c.Name, metadata.ComponentMigrationJobLabelValue),
 			).WithLabels(
 				c.ExtraPodLabels,
 			).WithAnnotations(
 				c.ExtraPodAnnotations,
-			).WithSpec(applycorev1.PodSpec().WithServiceAccountName(c.ServiceAccountName).
+			).WithSpec(c.applyPodSecurity(c.Scheduling.applyPlacement(applycorev1.PodSpec()), false).WithServiceAccountName(c.ServiceAccountName).
+				WithImagePullSecrets(c.imagePullSecrets()...).
 				WithContainers(
 					applycorev1.Container().
-						WithName("migrate").
+						WithName(ContainerNameMigrate).
 						WithImage(c.TargetSpiceDBImage).
 						WithCommand(c.MigrationConfig.SpiceDBCmd, "migrate", c.MigrationConfig.TargetMigration).
 						WithEnv(envVars...).
 						WithVolumeMounts(c.jobVolumeMounts()...).
 						WithPorts(c.containerPorts()...).
+						WithResources(resourcesApplyConfiguration(c.migrationJobResources())).
+						WithSecurityContext(c.containerSecurityContext()).
 						WithTerminationMessagePolicy(corev1.TerminationMessageFallbackToLogsOnError),
 				).WithVolumes(c.jobVolumes()...).WithRestartPolicy(corev1.RestartPolicyOnFailure))))
 }
 
 func (c *Config) MigrationJob(migrationHash string) *applybatchv1.JobApplyConfiguration {
 	j := applybatchv1.Job(c.jobName(migrationHash), c.Namespace)
-	_, _, _ = ApplyPatches(c.unpatchedMigrationJob(migrationHash), j, c.Patches, c.Resources)
+	_, _, _ = ApplyPatches(c.unpatchedMigrationJob(migrationHash), j, c.Patches, c.patchTemplateData(), c.Resources)
 
 	// ensure patches don't overwrite anything critical for operator function
 	name := c.jobName(migrationHash)
@@ -705,17 +887,30 @@ func (c *Config) MigrationJob(migrationHash string) *applybatchv1.JobApplyConfig
 }
 
 func (c *Config) containerPorts() []*applycorev1.ContainerPortApplyConfiguration {
+	return c.tierContainerPorts(c.apiTier())
+}
+
+func (c *Config) tierContainerPorts(tier deploymentTier) []*applycorev1.ContainerPortApplyConfiguration {
 	ports := []*applycorev1.ContainerPortApplyConfiguration{
 		applycorev1.ContainerPort().WithContainerPort(50051).WithName("grpc"),
 		applycorev1.ContainerPort().WithContainerPort(8443).WithName("gateway"),
 		applycorev1.ContainerPort().WithContainerPort(9090).WithName("metrics"),
 	}
-	if c.DispatchEnabled {
-		ports = append(ports, applycorev1.ContainerPort().WithContainerPort(50053).WithName("dispatch"))
+	if tier.serveDispatch {
+		ports = append(ports, applycorev1.ContainerPort().WithContainerPort(50053).WithName(c.dispatchPortName()))
 	}
 	return ports
 }
 
+// imagePullSecrets returns references to the operator's image pull secrets
+func (c *Config) imagePullSecrets() []*applycorev1.LocalObjectReferenceApplyConfiguration {
+	refs := make([]*applycorev1.LocalObjectReferenceApplyConfiguration, 0, len(c.ImagePullSecrets))
+	for _, name := range c.ImagePullSecrets {
+		refs = append(refs, applycorev1.LocalObjectReference().WithName(name))
+	}
+	return refs
+}
+
 func (c *Config) deploymentVolumes() []*applycorev1.VolumeApplyConfiguration {
 	volumes := c.jobVolumes()
 	// TODO: validate that the secrets exist before we start applying the Deployment
@@ -755,68 +950,118 @@ func (c *Config) probeCmd() []string {
 	return probeCmd
 }
 
+// deploymentTier describes one of the spicedb Deployments of a cluster. By
+// default a single tier serves the API and dispatches to itself; with a
+// dispatch tier configured, the api tier dispatches to the dispatch tier's
+// Service instead.
+type deploymentTier struct {
+	name             string
+	component        string
+	replicas         int32
+	resources        corev1.ResourceRequirements
+	serveDispatch    bool
+	dispatchUpstream string
+}
+
+func (c *Config) apiTier() deploymentTier {
+	tier := deploymentTier{
+		name:             deploymentName(c.Name),
+		component:        metadata.ComponentSpiceDBLabelValue,
+		replicas:         c.Replicas,
+		resources:        c.Scheduling.Resources,
+		serveDispatch:    c.DispatchEnabled,
+		dispatchUpstream: c.Name,
+	}
+	if c.DispatchTier.Enabled() {
+		tier.serveDispatch = false
+		tier.dispatchUpstream = dispatchServiceName(c.Name)
+	}
+	if len(c.DispatchIsolation) > 0 {
+		tier.dispatchUpstream = isolatedDispatchServiceName(c.Name, c.DispatchIsolation)
+	}
+	if c.Rollout.BlueGreen() {
+		tier.name = fmt.Sprintf("%s-%s", tier.name, c.Rollout.Color)
+	}
+	return tier
+}
+
 func (c *Config) unpatchedDeployment(migrationHash, secretHash string) *applyappsv1.DeploymentApplyConfiguration {
+	return c.unpatchedTierDeployment(c.apiTier(), migrationHash, secretHash)
+}
+
+func (c *Config) unpatchedTierDeployment(tier deploymentTier, migrationHash, secretHash string) *applyappsv1.DeploymentApplyConfiguration {
 	if c.SkipMigrations {
 		migrationHash = "skipped"
 	}
-	name := deploymentName(c.Name)
-	return applyappsv1.Deployment(name, c.Namespace).
-		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue)).
+	container := applycorev1.Container().WithName(ContainerNameSpiceDB).WithImage(c.TargetSpiceDBImage).
+		WithCommand(c.SpiceConfig.SpiceDBCmd, "serve").
+		WithEnv(c.tierEnvVars(tier)...).
+		WithPorts(c.tierContainerPorts(tier)...).
+		WithLivenessProbe(
+			applycorev1.Probe().WithExec(applycorev1.ExecAction().WithCommand(c.probeCmd()...)).
+				WithInitialDelaySeconds(60).WithFailureThreshold(5).WithPeriodSeconds(10).WithTimeoutSeconds(5),
+		).
+		WithReadinessProbe(
+			applycorev1.Probe().WithExec(applycorev1.ExecAction().WithCommand(c.probeCmd()...)).
+				WithFailureThreshold(5).WithPeriodSeconds(10).WithTimeoutSeconds(5),
+		).
+		WithVolumeMounts(c.deploymentVolumeMounts()...).
+		WithResources(resourcesApplyConfiguration(tier.resources)).
+		WithSecurityContext(c.containerSecurityContext()).
+		WithTerminationMessagePolicy(corev1.TerminationMessageFallbackToLogsOnError)
+
+	return applyappsv1.Deployment(tier.name, c.Namespace).
+		WithLabels(metadata.LabelsForComponent(c.Name, tier.component)).
 		WithAnnotations(map[string]string{
 			metadata.SpiceDBMigrationRequirementsKey: migrationHash,
 		}).
 		WithSpec(applyappsv1.DeploymentSpec().
-			WithReplicas(c.Replicas).
+			WithReplicas(tier.replicas).
 			WithStrategy(applyappsv1.DeploymentStrategy().
 				WithType(appsv1.RollingUpdateDeploymentStrategyType).
 				WithRollingUpdate(applyappsv1.RollingUpdateDeployment().WithMaxUnavailable(intstr.FromInt32(0)))).
-			WithSelector(applymetav1.LabelSelector().WithMatchLabels(map[string]string{"app.kubernetes.io/instance": name})).
+			WithSelector(applymetav1.LabelSelector().WithMatchLabels(map[string]string{"app.kubernetes.io/instance": tier.name})).
 			WithTemplate(applycorev1.PodTemplateSpec().
 				WithAnnotations(map[string]string{
 					metadata.SpiceDBSecretRequirementsKey: secretHash,
 					metadata.SpiceDBTargetMigrationKey:    c.MigrationConfig.TargetMigration,
 				}).
-				WithLabels(map[string]string{"app.kubernetes.io/instance": name}).
-				WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue)).
+				WithLabels(map[string]string{"app.kubernetes.io/instance": tier.name}).
+				WithLabels(metadata.LabelsForComponent(c.Name, tier.component)).
+				WithLabels(c.colorLabels()).
 				WithLabels(c.ExtraPodLabels).
 				WithAnnotations(c.ExtraPodAnnotations).
-				WithSpec(applycorev1.PodSpec().WithServiceAccountName(c.ServiceAccountName).WithContainers(
-					applycorev1.Container().WithName(ContainerNameSpiceDB).WithImage(c.TargetSpiceDBImage).
-						WithCommand(c.SpiceConfig.SpiceDBCmd, "serve").
-						WithEnv(c.toEnvVarApplyConfiguration()...).
-						WithPorts(c.containerPorts()...).
-						WithLivenessProbe(
-							applycorev1.Probe().WithExec(applycorev1.ExecAction().WithCommand(c.probeCmd()...)).
-								WithInitialDelaySeconds(60).WithFailureThreshold(5).WithPeriodSeconds(10).WithTimeoutSeconds(5),
-						).
-						WithReadinessProbe(
-							applycorev1.Probe().WithExec(applycorev1.ExecAction().WithCommand(c.probeCmd()...)).
-								WithFailureThreshold(5).WithPeriodSeconds(10).WithTimeoutSeconds(5),
-						).
-						WithVolumeMounts(c.deploymentVolumeMounts()...).
-						WithTerminationMessagePolicy(corev1.TerminationMessageFallbackToLogsOnError),
-				).WithVolumes(c.deploymentVolumes()...))))
+				WithSpec(c.applyPodSecurity(c.Scheduling.applyPlacement(applycorev1.PodSpec()), c.DispatchEnabled).
+					WithServiceAccountName(c.ServiceAccountName).
+					WithImagePullSecrets(c.imagePullSecrets()...).
+					WithContainers(container).
+					WithTopologySpreadConstraints(c.Scheduling.topologySpreadConstraints(tier.name, tier.replicas)...).
+					WithVolumes(c.deploymentVolumes()...))))
 }
 
 func (c *Config) Deployment(migrationHash, secretHash string) *applyappsv1.DeploymentApplyConfiguration {
-	name := deploymentName(c.Name)
-	d := applyappsv1.Deployment(name, c.Namespace)
-	_, _, _ = ApplyPatches(c.unpatchedDeployment(migrationHash, secretHash), d, c.Patches, c.Resources)
+	return c.tierDeployment(c.apiTier(), migrationHash, secretHash)
+}
+
+func (c *Config) tierDeployment(tier deploymentTier, migrationHash, secretHash string) *applyappsv1.DeploymentApplyConfiguration {
+	d := applyappsv1.Deployment(tier.name, c.Namespace)
+	_, _, _ = ApplyPatches(c.unpatchedTierDeployment(tier, migrationHash, secretHash), d, c.Patches, c.patchTemplateData(), c.Resources)
 
 	// ensure patches don't overwrite anything critical for operator function
-	d.WithName(name).WithNamespace(c.Namespace).WithOwnerReferences(c.ownerRef()).
-		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue)).
+	d.WithName(tier.name).WithNamespace(c.Namespace).WithOwnerReferences(c.ownerRef()).
+		WithLabels(metadata.LabelsForComponent(c.Name, tier.component)).
 		WithAnnotations(map[string]string{
 			metadata.SpiceDBMigrationRequirementsKey: migrationHash,
 		})
-	d.Spec.Selector.WithMatchLabels(map[string]string{"app.kubernetes.io/instance": name})
+	d.Spec.Selector.WithMatchLabels(map[string]string{"app.kubernetes.io/instance": tier.name})
 	d.Spec.Template.
 		WithAnnotations(map[string]string{
 			metadata.SpiceDBSecretRequirementsKey: secretHash,
 			metadata.SpiceDBTargetMigrationKey:    c.MigrationConfig.TargetMigration,
 		}).
-		WithLabels(map[string]string{"app.kubernetes.io/instance": name}).
-		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue))
+		WithLabels(map[string]string{"app.kubernetes.io/instance": tier.name}).
+		WithLabels(metadata.LabelsForComponent(c.Name, tier.component)).
+		WithLabels(c.colorLabels())
 	return d
 }
 
@@ -831,7 +1076,7 @@ func fixDeploymentPatches(name string, in []v1alpha1.Patch) []v1alpha1.Patch {
 	patches = append(patches, in...)
 	for i, p := range patches {
 		// not a deployment patch
-		if !(p.Kind == "Deployment" || p.Kind == wildcard) {
+		if kind := patchKind(p); !(kind == "Deployment" || kind == wildcard) {
 			continue
 		}
 
@@ -893,3 +1138,13 @@ func toEnvVarName(prefix string, key string) string {
 func deploymentName(name string) string {
 	return fmt.Sprintf("%s-spicedb", name)
 }
+
+// TLSSecretName returns the TLS secret configured for a cluster without
+// validating the rest of its config.
+func TLSSecretName(cluster *v1alpha1.SpiceDBCluster) string {
+	config := RawConfig(make(map[string]any))
+	if err := json.Unmarshal(cluster.Spec.Config, &config); err != nil {
+		return ""
+	}
+	return tlsSecretNameKey.pop(config)
+}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
@@ -51,7 +52,7 @@ func TestToEnvVarName(t *testing.T) {
 }
 
 func TestNewConfig(t *testing.T) {
-	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.26.3.json"))
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
 	type args struct {
 		cluster      v1alpha1.ClusterSpec
 		status       v1alpha1.ClusterStatus
@@ -66,6 +67,8 @@ func TestNewConfig(t *testing.T) {
 		wantWarnings  []error
 		wantErrs      []error
 		wantPortCount int
+		// defaults to wantPortCount
+		wantContainerPortCount int
 	}{
 		{
 			name: "missing required",
@@ -1463,6 +1466,547 @@ func TestNewConfig(t *testing.T) {
 			},
 			wantPortCount: 3,
 		},
+		{
+			name: "verify rollout with check",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "cockroachdb",
+						"verifyRollout": true,
+						"verifyRolloutCheck": "document:readme#view@user:alice",
+						"verifyRolloutPauseOnFailure": "true"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "cockroachdb",
+								Metadata: map[string]string{"datastore": "cockroachdb", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantWarnings: []error{fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\"")},
+			want: &Config{
+				MigrationConfig: MigrationConfig{
+					MigrationLogLevel:  "debug",
+					DatastoreEngine:    "cockroachdb",
+					DatastoreURI:       "uri",
+					TargetSpiceDBImage: "image:v1",
+					EnvPrefix:          "SPICEDB",
+					SpiceDBCmd:         "spicedb",
+					TargetMigration:    "head",
+					SpiceDBVersion: &v1alpha1.SpiceDBVersion{
+						Name:    "v1",
+						Channel: "cockroachdb",
+						Attributes: []v1alpha1.SpiceDBVersionAttributes{
+							v1alpha1.SpiceDBVersionAttributesMigration,
+						},
+					},
+				},
+				SpiceConfig: SpiceConfig{
+					LogLevel:                     "info",
+					Name:                         "test",
+					Namespace:                    "test",
+					UID:                          "1",
+					Replicas:                     2,
+					PresharedKey:                 "psk",
+					EnvPrefix:                    "SPICEDB",
+					SpiceDBCmd:                   "spicedb",
+					ServiceAccountName:           "test",
+					DispatchEnabled:              true,
+					DispatchUpstreamCASecretPath: "tls.crt",
+					ProjectLabels:                true,
+					ProjectAnnotations:           true,
+					VerifyRollout:                true,
+					VerifyRolloutCheck:           "document:readme#view@user:alice",
+					VerifyRolloutPauseOnFailure:  true,
+					Passthrough: map[string]string{
+						"datastoreEngine":        "cockroachdb",
+						"dispatchClusterEnabled": "true",
+						"terminationLogPath":     "/dev/termination-log",
+					},
+				},
+			},
+			wantEnvs: []string{
+				"SPICEDB_POD_NAME=FIELD_REF=metadata.name",
+				"SPICEDB_LOG_LEVEL=info",
+				"SPICEDB_GRPC_PRESHARED_KEY=preshared_key",
+				"SPICEDB_DATASTORE_CONN_URI=datastore_uri",
+				"SPICEDB_DISPATCH_UPSTREAM_ADDR=kubernetes:///test.test:dispatch",
+				"SPICEDB_DATASTORE_ENGINE=cockroachdb",
+				"SPICEDB_DISPATCH_CLUSTER_ENABLED=true",
+				"SPICEDB_TERMINATION_LOG_PATH=/dev/termination-log",
+			},
+			wantPortCount: 4,
+		},
+		{
+			name: "invalid verify rollout check",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "cockroachdb",
+						"verifyRolloutCheck": "document:readme#view"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "cockroachdb",
+								Metadata: map[string]string{"datastore": "cockroachdb", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantErrs: []error{
+				fmt.Errorf("invalid check \"document:readme#view\": expected resource#permission@subject"),
+			},
+			wantWarnings: []error{
+				fmt.Errorf("\"verifyRolloutCheck\" has no effect unless \"verifyRollout\" is enabled"),
+				fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\""),
+			},
+		},
+		{
+			name: "datastore provisioning without datastore_uri",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "postgres",
+						"datastoreProvisioningSecretName": "pg-admin"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "postgres",
+								Metadata: map[string]string{"datastore": "postgres", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantWarnings: []error{fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\"")},
+			want: &Config{
+				MigrationConfig: MigrationConfig{
+					MigrationLogLevel:  "debug",
+					DatastoreEngine:    "postgres",
+					TargetSpiceDBImage: "image:v1",
+					EnvPrefix:          "SPICEDB",
+					SpiceDBCmd:         "spicedb",
+					TargetMigration:    "head",
+					SpiceDBVersion: &v1alpha1.SpiceDBVersion{
+						Name:    "v1",
+						Channel: "postgres",
+						Attributes: []v1alpha1.SpiceDBVersionAttributes{
+							v1alpha1.SpiceDBVersionAttributesMigration,
+						},
+					},
+				},
+				SpiceConfig: SpiceConfig{
+					LogLevel:                     "info",
+					Name:                         "test",
+					Namespace:                    "test",
+					UID:                          "1",
+					Replicas:                     2,
+					PresharedKey:                 "psk",
+					EnvPrefix:                    "SPICEDB",
+					SpiceDBCmd:                   "spicedb",
+					ServiceAccountName:           "test",
+					DispatchEnabled:              true,
+					DispatchUpstreamCASecretPath: "tls.crt",
+					ProjectLabels:                true,
+					ProjectAnnotations:           true,
+					DatastoreProvisioning: DatastoreProvisioningConfig{
+						AdminSecretName: "pg-admin",
+						Image:           "postgres:16-alpine",
+						Database:        "spicedb_test",
+						User:            "spicedb_test",
+					},
+					Passthrough: map[string]string{
+						"datastoreEngine":        "postgres",
+						"dispatchClusterEnabled": "true",
+						"terminationLogPath":     "/dev/termination-log",
+					},
+				},
+			},
+			wantEnvs: []string{
+				"SPICEDB_POD_NAME=FIELD_REF=metadata.name",
+				"SPICEDB_LOG_LEVEL=info",
+				"SPICEDB_GRPC_PRESHARED_KEY=preshared_key",
+				"SPICEDB_DATASTORE_CONN_URI=datastore_uri",
+				"SPICEDB_DISPATCH_UPSTREAM_ADDR=kubernetes:///test.test:dispatch",
+				"SPICEDB_DATASTORE_ENGINE=postgres",
+				"SPICEDB_DISPATCH_CLUSTER_ENABLED=true",
+				"SPICEDB_TERMINATION_LOG_PATH=/dev/termination-log",
+			},
+			wantPortCount: 4,
+		},
+		{
+			name: "datastore provisioning unsupported engine",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "spanner",
+						"datastoreProvisioningSecretName": "admin"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "spanner",
+								Metadata: map[string]string{"datastore": "spanner", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantErrs: []error{
+				fmt.Errorf("datastore provisioning is not supported for datastoreEngine \"spanner\""),
+			},
+			wantWarnings: []error{
+				fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\""),
+			},
+		},
+		{
+			name: "read replicas from secret",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "postgres",
+						"datastoreReadReplicaConnUri": "postgres://plaintext"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "postgres",
+								Metadata: map[string]string{"datastore": "postgres", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri":            []byte("uri"),
+					"datastore_replica_uri_10": []byte("replica10"),
+					"datastore_replica_uri_2":  []byte("replica2"),
+					"datastore_replica_uri_0":  []byte("replica0"),
+					"datastore_replica_uri_01": []byte("ignored"),
+					"preshared_key":            []byte("psk"),
+				}},
+			},
+			wantWarnings: []error{fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\"")},
+			want: &Config{
+				MigrationConfig: MigrationConfig{
+					MigrationLogLevel:  "debug",
+					DatastoreEngine:    "postgres",
+					DatastoreURI:       "uri",
+					TargetSpiceDBImage: "image:v1",
+					EnvPrefix:          "SPICEDB",
+					SpiceDBCmd:         "spicedb",
+					TargetMigration:    "head",
+					SpiceDBVersion: &v1alpha1.SpiceDBVersion{
+						Name:    "v1",
+						Channel: "postgres",
+						Attributes: []v1alpha1.SpiceDBVersionAttributes{
+							v1alpha1.SpiceDBVersionAttributesMigration,
+						},
+					},
+				},
+				SpiceConfig: SpiceConfig{
+					LogLevel:                     "info",
+					Name:                         "test",
+					Namespace:                    "test",
+					UID:                          "1",
+					Replicas:                     2,
+					PresharedKey:                 "psk",
+					EnvPrefix:                    "SPICEDB",
+					SpiceDBCmd:                   "spicedb",
+					ServiceAccountName:           "test",
+					DispatchEnabled:              true,
+					DispatchUpstreamCASecretPath: "tls.crt",
+					ProjectLabels:                true,
+					ProjectAnnotations:           true,
+					DatastoreReplicaKeys: []string{
+						"datastore_replica_uri_0",
+						"datastore_replica_uri_2",
+						"datastore_replica_uri_10",
+					},
+					Passthrough: map[string]string{
+						"datastoreEngine":        "postgres",
+						"dispatchClusterEnabled": "true",
+						"terminationLogPath":     "/dev/termination-log",
+					},
+				},
+			},
+			wantEnvs: []string{
+				"SPICEDB_POD_NAME=FIELD_REF=metadata.name",
+				"SPICEDB_LOG_LEVEL=info",
+				"SPICEDB_GRPC_PRESHARED_KEY=preshared_key",
+				"SPICEDB_DATASTORE_CONN_URI=datastore_uri",
+				"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_0=datastore_replica_uri_0",
+				"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_1=datastore_replica_uri_2",
+				"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_2=datastore_replica_uri_10",
+				"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI=$(SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_0),$(SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_1),$(SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_2)",
+				"SPICEDB_DISPATCH_UPSTREAM_ADDR=kubernetes:///test.test:dispatch",
+				"SPICEDB_DATASTORE_ENGINE=postgres",
+				"SPICEDB_DISPATCH_CLUSTER_ENABLED=true",
+				"SPICEDB_TERMINATION_LOG_PATH=/dev/termination-log",
+			},
+			wantPortCount: 4,
+		},
+		{
+			name: "read replicas ignored for unsupported engine",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "cockroachdb"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "cockroachdb",
+								Metadata: map[string]string{"datastore": "cockroachdb", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri":           []byte("uri"),
+					"datastore_replica_uri_0": []byte("replica0"),
+					"preshared_key":           []byte("psk"),
+				}},
+			},
+			wantWarnings: []error{
+				fmt.Errorf("ignoring 1 read replica uris: read replicas are only supported for postgres and mysql"),
+				fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\""),
+			},
+			want: &Config{
+				MigrationConfig: MigrationConfig{
+					MigrationLogLevel:  "debug",
+					DatastoreEngine:    "cockroachdb",
+					DatastoreURI:       "uri",
+					TargetSpiceDBImage: "image:v1",
+					EnvPrefix:          "SPICEDB",
+					SpiceDBCmd:         "spicedb",
+					TargetMigration:    "head",
+					SpiceDBVersion: &v1alpha1.SpiceDBVersion{
+						Name:    "v1",
+						Channel: "cockroachdb",
+						Attributes: []v1alpha1.SpiceDBVersionAttributes{
+							v1alpha1.SpiceDBVersionAttributesMigration,
+						},
+					},
+				},
+				SpiceConfig: SpiceConfig{
+					LogLevel:                     "info",
+					Name:                         "test",
+					Namespace:                    "test",
+					UID:                          "1",
+					Replicas:                     2,
+					PresharedKey:                 "psk",
+					EnvPrefix:                    "SPICEDB",
+					SpiceDBCmd:                   "spicedb",
+					ServiceAccountName:           "test",
+					DispatchEnabled:              true,
+					DispatchUpstreamCASecretPath: "tls.crt",
+					ProjectLabels:                true,
+					ProjectAnnotations:           true,
+					Passthrough: map[string]string{
+						"datastoreEngine":        "cockroachdb",
+						"dispatchClusterEnabled": "true",
+						"terminationLogPath":     "/dev/termination-log",
+					},
+				},
+			},
+			wantEnvs: []string{
+				"SPICEDB_POD_NAME=FIELD_REF=metadata.name",
+				"SPICEDB_LOG_LEVEL=info",
+				"SPICEDB_GRPC_PRESHARED_KEY=preshared_key",
+				"SPICEDB_DATASTORE_CONN_URI=datastore_uri",
+				"SPICEDB_DISPATCH_UPSTREAM_ADDR=kubernetes:///test.test:dispatch",
+				"SPICEDB_DATASTORE_ENGINE=cockroachdb",
+				"SPICEDB_DISPATCH_CLUSTER_ENABLED=true",
+				"SPICEDB_TERMINATION_LOG_PATH=/dev/termination-log",
+			},
+			wantPortCount: 4,
+		},
+		{
+			name: "dispatch tier",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "cockroachdb",
+						"dispatchTierReplicas": 3,
+						"dispatchTierResources": {"requests": {"cpu": "2"}}
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "cockroachdb",
+								Metadata: map[string]string{"datastore": "cockroachdb", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantWarnings: []error{fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\"")},
+			want: &Config{
+				MigrationConfig: MigrationConfig{
+					MigrationLogLevel:  "debug",
+					DatastoreEngine:    "cockroachdb",
+					DatastoreURI:       "uri",
+					TargetSpiceDBImage: "image:v1",
+					EnvPrefix:          "SPICEDB",
+					SpiceDBCmd:         "spicedb",
+					TargetMigration:    "head",
+					SpiceDBVersion: &v1alpha1.SpiceDBVersion{
+						Name:    "v1",
+						Channel: "cockroachdb",
+						Attributes: []v1alpha1.SpiceDBVersionAttributes{
+							v1alpha1.SpiceDBVersionAttributesMigration,
+						},
+					},
+				},
+				SpiceConfig: SpiceConfig{
+					LogLevel:                     "info",
+					Name:                         "test",
+					Namespace:                    "test",
+					UID:                          "1",
+					Replicas:                     2,
+					PresharedKey:                 "psk",
+					EnvPrefix:                    "SPICEDB",
+					SpiceDBCmd:                   "spicedb",
+					ServiceAccountName:           "test",
+					DispatchEnabled:              true,
+					DispatchUpstreamCASecretPath: "tls.crt",
+					ProjectLabels:                true,
+					ProjectAnnotations:           true,
+					Passthrough: map[string]string{
+						"datastoreEngine":        "cockroachdb",
+						"dispatchClusterEnabled": "true",
+						"terminationLogPath":     "/dev/termination-log",
+					},
+					DispatchTier: DispatchTierConfig{
+						Replicas: 3,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+						},
+					},
+				},
+			},
+			wantEnvs: []string{
+				"SPICEDB_POD_NAME=FIELD_REF=metadata.name",
+				"SPICEDB_LOG_LEVEL=info",
+				"SPICEDB_GRPC_PRESHARED_KEY=preshared_key",
+				"SPICEDB_DATASTORE_CONN_URI=datastore_uri",
+				"SPICEDB_DISPATCH_UPSTREAM_ADDR=kubernetes:///test-dispatch.test:dispatch",
+				"SPICEDB_DATASTORE_ENGINE=cockroachdb",
+				"SPICEDB_DISPATCH_CLUSTER_ENABLED=false",
+				"SPICEDB_TERMINATION_LOG_PATH=/dev/termination-log",
+			},
+			wantPortCount:          4,
+			wantContainerPortCount: 3,
+		},
+		{
+			name: "dispatch tier requires dispatch",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{Config: json.RawMessage(`
+					{
+						"datastoreEngine": "cockroachdb",
+						"dispatchEnabled": false,
+						"dispatchTierReplicas": "3"
+					}
+				`)},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "cockroachdb",
+								Metadata: map[string]string{"datastore": "cockroachdb", "default": "true"},
+								Nodes: []updates.State{
+									{ID: "v1", Tag: "v1"},
+								},
+								Edges: map[string][]string{"v1": {}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantErrs: []error{
+				fmt.Errorf("dispatchTierReplicas requires dispatch to be enabled"),
+			},
+			wantWarnings: []error{fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\"")},
+		},
 		{
 			name: "update graph pushes the current version forward",
 			args: args{
@@ -1739,6 +2283,100 @@ func TestNewConfig(t *testing.T) {
 			},
 			wantPortCount: 4,
 		},
+		{
+			name: "current version outside the version constraint rolls back",
+			args: args{
+				cluster: v1alpha1.ClusterSpec{
+					Config: json.RawMessage(`
+					{
+						"logLevel": "debug",
+						"migrationLogLevel": "info",
+						"datastoreEngine": "cockroachdb"
+					}
+				`),
+					Channel:           "cockroachdb",
+					VersionConstraint: "~1.30",
+				},
+				status: v1alpha1.ClusterStatus{
+					CurrentVersion: &v1alpha1.SpiceDBVersion{
+						Name:    "v1.33.0",
+						Channel: "cockroachdb",
+					},
+				},
+				globalConfig: OperatorConfig{
+					ImageName: "image",
+					UpdateGraph: updates.UpdateGraph{
+						Channels: []updates.Channel{
+							{
+								Name:     "cockroachdb",
+								Metadata: map[string]string{"datastore": "cockroachdb"},
+								Nodes: []updates.State{
+									{ID: "v1.33.0", Tag: "v1.33.0"},
+									{ID: "v1.30.0", Tag: "v1.30.0"},
+								},
+								Edges:     map[string][]string{"v1.30.0": {"v1.33.0"}},
+								Rollbacks: updates.RollbackSet{"v1.33.0": {{To: "v1.30.0"}}},
+							},
+						},
+					},
+				},
+				secret: &corev1.Secret{Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("psk"),
+				}},
+			},
+			wantWarnings: []error{
+				fmt.Errorf("current version v1.33.0 doesn't satisfy versionConstraint \"~1.30\", rolling back to v1.30.0"),
+				fmt.Errorf("no TLS configured, consider setting \"tlsSecretName\""),
+			},
+			want: &Config{
+				MigrationConfig: MigrationConfig{
+					MigrationLogLevel:  "info",
+					DatastoreEngine:    "cockroachdb",
+					DatastoreURI:       "uri",
+					TargetSpiceDBImage: "image:v1.30.0",
+					EnvPrefix:          "SPICEDB",
+					SpiceDBCmd:         "spicedb",
+					TargetMigration:    "head",
+					SpiceDBVersion: &v1alpha1.SpiceDBVersion{
+						Name:       "v1.30.0",
+						Channel:    "cockroachdb",
+						Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesRollback},
+					},
+				},
+				SpiceConfig: SpiceConfig{
+					LogLevel:                     "debug",
+					Name:                         "test",
+					Namespace:                    "test",
+					UID:                          "1",
+					Replicas:                     2,
+					PresharedKey:                 "psk",
+					EnvPrefix:                    "SPICEDB",
+					SpiceDBCmd:                   "spicedb",
+					ServiceAccountName:           "test",
+					DispatchEnabled:              true,
+					DispatchUpstreamCASecretPath: "tls.crt",
+					ProjectLabels:                true,
+					ProjectAnnotations:           true,
+					Passthrough: map[string]string{
+						"datastoreEngine":        "cockroachdb",
+						"dispatchClusterEnabled": "true",
+						"terminationLogPath":     "/dev/termination-log",
+					},
+				},
+			},
+			wantEnvs: []string{
+				"SPICEDB_POD_NAME=FIELD_REF=metadata.name",
+				"SPICEDB_LOG_LEVEL=debug",
+				"SPICEDB_GRPC_PRESHARED_KEY=preshared_key",
+				"SPICEDB_DATASTORE_CONN_URI=datastore_uri",
+				"SPICEDB_DISPATCH_UPSTREAM_ADDR=kubernetes:///test.test:dispatch",
+				"SPICEDB_DATASTORE_ENGINE=cockroachdb",
+				"SPICEDB_DISPATCH_CLUSTER_ENABLED=true",
+				"SPICEDB_TERMINATION_LOG_PATH=/dev/termination-log",
+			},
+			wantPortCount: 4,
+		},
 		{
 			name: "set spanner credentials",
 			args: args{
@@ -1839,7 +2477,7 @@ func TestNewConfig(t *testing.T) {
 			if tt.want != nil {
 				tt.want.Resources = resources
 			}
-			got, gotWarning, err := NewConfig(cluster, &global, tt.args.secret, resources)
+			got, gotWarning, err := NewConfig(cluster, &global, tt.args.secret, resources, time.Now())
 			require.EqualValues(t, errors.NewAggregate(tt.wantErrs), err)
 			require.EqualValues(t, errors.NewAggregate(tt.wantWarnings), gotWarning)
 			require.Equal(t, tt.want, got)
@@ -1851,8 +2489,12 @@ func TestNewConfig(t *testing.T) {
 
 				require.Equal(t, tt.wantPortCount, len(got.servicePorts()),
 					"expected service to have %d ports but had %d", tt.wantPortCount, len(got.servicePorts()))
-				require.Equal(t, tt.wantPortCount, len(got.containerPorts()),
-					"expected container to have %d ports but had %d", tt.wantPortCount, len(got.containerPorts()))
+				wantContainerPortCount := tt.wantContainerPortCount
+				if wantContainerPortCount == 0 {
+					wantContainerPortCount = tt.wantPortCount
+				}
+				require.Equal(t, wantContainerPortCount, len(got.containerPorts()),
+					"expected container to have %d ports but had %d", wantContainerPortCount, len(got.containerPorts()))
 			}
 		})
 	}
@@ -1860,8 +2502,11 @@ func TestNewConfig(t *testing.T) {
 
 // envs that will be mapped back to ENV vars
 var secrets = map[string]struct{}{
-	"SPICEDB_GRPC_PRESHARED_KEY": {},
-	"SPICEDB_DATASTORE_CONN_URI": {},
+	"SPICEDB_GRPC_PRESHARED_KEY":                {},
+	"SPICEDB_DATASTORE_CONN_URI":                {},
+	"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_0": {},
+	"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_1": {},
+	"SPICEDB_DATASTORE_READ_REPLICA_CONN_URI_2": {},
 }
 
 func envVarFromStrings(envs []string) []*applycorev1.EnvVarApplyConfiguration {
@@ -1964,7 +2609,7 @@ func TestGraphDiffSanity(t *testing.T) {
 }
 
 func TestDeploymentContainerNameBackCompat(t *testing.T) {
-	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.26.3.json"))
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
 	type args struct {
 		cluster      v1alpha1.ClusterSpec
 		status       v1alpha1.ClusterStatus
@@ -2048,7 +2693,7 @@ spec:
 						}).
 						WithLabels(map[string]string{"app.kubernetes.io/instance": "test-spicedb"}).
 						WithLabels(metadata.LabelsForComponent("test", metadata.ComponentSpiceDBLabelValue)).
-						WithSpec(applycorev1.PodSpec().WithServiceAccountName("test").WithContainers(
+						WithSpec(applycorev1.PodSpec().WithSecurityContext(expectedPodSecurityContext()).WithAutomountServiceAccountToken(true).WithServiceAccountName("test").WithTopologySpreadConstraints(expectedDefaultSpread("test-spicedb")...).WithContainers(
 							applycorev1.Container().WithName(ContainerNameSpiceDB).WithImage("image:v1").
 								WithCommand("spicedb", "serve").
 								WithEnv(
@@ -2088,6 +2733,7 @@ spec:
 									applycorev1.VolumeMount().WithName(labelsVolume).WithMountPath("/etc/podlabels"),
 									applycorev1.VolumeMount().WithName(annotationsVolume).WithMountPath("/etc/podannotations"),
 								).
+								WithSecurityContext(expectedContainerSecurityContext()).
 								WithTerminationMessagePolicy(corev1.TerminationMessageFallbackToLogsOnError),
 						).WithVolumes(
 							applycorev1.Volume().WithName(podNameVolume).
@@ -2188,7 +2834,7 @@ spec:
 						}).
 						WithLabels(map[string]string{"app.kubernetes.io/instance": "test-spicedb"}).
 						WithLabels(metadata.LabelsForComponent("test", metadata.ComponentSpiceDBLabelValue)).
-						WithSpec(applycorev1.PodSpec().WithServiceAccountName("test").WithContainers(
+						WithSpec(applycorev1.PodSpec().WithSecurityContext(expectedPodSecurityContext()).WithAutomountServiceAccountToken(true).WithServiceAccountName("test").WithTopologySpreadConstraints(expectedDefaultSpread("test-spicedb")...).WithContainers(
 							applycorev1.Container().WithName(ContainerNameSpiceDB).WithImage("image:v1").
 								WithCommand("spicedb", "serve").
 								WithEnv(
@@ -2228,6 +2874,7 @@ spec:
 									applycorev1.VolumeMount().WithName(labelsVolume).WithMountPath("/etc/podlabels"),
 									applycorev1.VolumeMount().WithName(annotationsVolume).WithMountPath("/etc/podannotations"),
 								).
+								WithSecurityContext(expectedContainerSecurityContext()).
 								WithTerminationMessagePolicy(corev1.TerminationMessageFallbackToLogsOnError),
 						).WithVolumes(
 							applycorev1.Volume().WithName(podNameVolume).
@@ -2269,7 +2916,7 @@ spec:
 				Spec:   tt.args.cluster,
 				Status: tt.args.status,
 			}
-			got, _, err := NewConfig(cluster, &global, tt.args.secret, resources)
+			got, _, err := NewConfig(cluster, &global, tt.args.secret, resources, time.Now())
 			require.NoError(t, err)
 
 			wantDep, err := json.Marshal(tt.wantDeployment)
@@ -2281,3 +2928,26 @@ spec:
 		})
 	}
 }
+
+// expectedDefaultSpread is the default topology spread for deployments with
+// more than one replica
+func expectedDefaultSpread(instance string) []*applycorev1.TopologySpreadConstraintApplyConfiguration {
+	selector := applymetav1.LabelSelector().WithMatchLabels(map[string]string{"app.kubernetes.io/instance": instance})
+	return []*applycorev1.TopologySpreadConstraintApplyConfiguration{
+		applycorev1.TopologySpreadConstraint().WithMaxSkew(1).WithTopologyKey("topology.kubernetes.io/zone").WithWhenUnsatisfiable(corev1.ScheduleAnyway).WithLabelSelector(selector),
+		applycorev1.TopologySpreadConstraint().WithMaxSkew(1).WithTopologyKey("kubernetes.io/hostname").WithWhenUnsatisfiable(corev1.ScheduleAnyway).WithLabelSelector(selector),
+	}
+}
+
+// expectedPodSecurityContext is the default restricted pod security context
+func expectedPodSecurityContext() *applycorev1.PodSecurityContextApplyConfiguration {
+	return applycorev1.PodSecurityContext().WithRunAsNonRoot(true).WithRunAsUser(65532).WithRunAsGroup(65532).
+		WithSeccompProfile(applycorev1.SeccompProfile().WithType(corev1.SeccompProfileTypeRuntimeDefault))
+}
+
+// expectedContainerSecurityContext is the default restricted container
+// security context
+func expectedContainerSecurityContext() *applycorev1.SecurityContextApplyConfiguration {
+	return applycorev1.SecurityContext().WithRunAsNonRoot(true).WithAllowPrivilegeEscalation(false).
+		WithReadOnlyRootFilesystem(true).WithCapabilities(applycorev1.Capabilities().WithDrop("ALL"))
+}
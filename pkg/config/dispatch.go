@@ -0,0 +1,183 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	applyappsv1 "k8s.io/client-go/applyconfigurations/apps/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+)
+
+// dispatchPortName is the name of the container port that serves dispatch
+// when pods of every version share one dispatch ring.
+const dispatchPortName = "dispatch"
+
+// DispatchTierConfig configures an optional set of dispatch-only pods. When
+// enabled, the api pods stop serving dispatch themselves and send all
+// dispatch requests to the dispatch tier, which can be scaled separately.
+type DispatchTierConfig struct {
+	Replicas  int32
+	Resources corev1.ResourceRequirements
+}
+
+// Enabled returns true if the cluster runs a separate dispatch tier.
+func (d DispatchTierConfig) Enabled() bool {
+	return d.Replicas > 0
+}
+
+func newDispatchTierConfig(dispatchEnabled bool, config RawConfig) (DispatchTierConfig, error) {
+	var d DispatchTierConfig
+	var err error
+	d.Replicas, err = dispatchTierReplicasKey.pop(config)
+	if err != nil {
+		return DispatchTierConfig{}, fmt.Errorf("invalid value for %s: %w", dispatchTierReplicasKey.key, err)
+	}
+	if d.Replicas < 0 {
+		return DispatchTierConfig{}, fmt.Errorf("invalid value for %s %d: must not be negative", dispatchTierReplicasKey.key, d.Replicas)
+	}
+	d.Resources, err = dispatchTierResourcesKey.pop(config)
+	if err != nil {
+		return DispatchTierConfig{}, err
+	}
+	if d.Enabled() && !dispatchEnabled {
+		return DispatchTierConfig{}, fmt.Errorf("%s requires dispatch to be enabled", dispatchTierReplicasKey.key)
+	}
+	return d, nil
+}
+
+// dispatchDeploymentName returns the name of the dispatch tier's Deployment
+func dispatchDeploymentName(name string) string {
+	return fmt.Sprintf("%s-spicedb-dispatch", name)
+}
+
+// dispatchServiceName returns the name of the Service in front of the
+// dispatch tier
+func dispatchServiceName(name string) string {
+	return fmt.Sprintf("%s-dispatch", name)
+}
+
+func (c *Config) dispatchTier() deploymentTier {
+	tier := deploymentTier{
+		name:             dispatchDeploymentName(c.Name),
+		component:        metadata.ComponentDispatchLabelValue,
+		replicas:         c.DispatchTier.Replicas,
+		resources:        c.DispatchTier.Resources,
+		serveDispatch:    true,
+		dispatchUpstream: dispatchServiceName(c.Name),
+	}
+	// without tier-specific resources, dispatch pods are sized like api pods
+	if len(tier.resources.Limits) == 0 && len(tier.resources.Requests) == 0 {
+		tier.resources = c.Scheduling.Resources
+	}
+	if len(c.DispatchIsolation) > 0 {
+		tier.dispatchUpstream = isolatedDispatchServiceName(c.Name, c.DispatchIsolation)
+	}
+	return tier
+}
+
+func (c *Config) DispatchDeployment(migrationHash, secretHash string) *applyappsv1.DeploymentApplyConfiguration {
+	return c.tierDeployment(c.dispatchTier(), migrationHash, secretHash)
+}
+
+func (c *Config) unpatchedDispatchService() *applycorev1.ServiceApplyConfiguration {
+	return applycorev1.Service(dispatchServiceName(c.Name), c.Namespace).
+		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentDispatchServiceLabel)).
+		WithSpec(applycorev1.ServiceSpec().
+			WithSelector(metadata.LabelsForComponent(c.Name, metadata.ComponentDispatchLabelValue)).
+			WithPorts(
+				applycorev1.ServicePort().WithName("dispatch").WithPort(50053).WithTargetPort(intstr.FromString(dispatchPortName)),
+				applycorev1.ServicePort().WithName("metrics").WithPort(9090),
+			),
+		)
+}
+
+func (c *Config) DispatchService() *applycorev1.ServiceApplyConfiguration {
+	name := dispatchServiceName(c.Name)
+	s := applycorev1.Service(name, c.Namespace)
+	_, _, _ = ApplyPatches(c.unpatchedDispatchService(), s, c.Patches, c.patchTemplateData(), c.Resources)
+
+	// ensure patches don't overwrite anything critical for operator function
+	s.WithName(name).WithNamespace(c.Namespace).
+		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentDispatchServiceLabel)).
+		WithOwnerReferences(c.ownerRef())
+	s.Spec.WithSelector(metadata.LabelsForComponent(c.Name, metadata.ComponentDispatchLabelValue))
+	return s
+}
+
+// dispatchIsolation names the dispatch ring for pods running the target
+// version, if the update to it is between versions that can't dispatch to
+// each other. It returns "" when pods of every version share one ring.
+//
+// The name is short because it's part of a container port name, which is
+// limited to 15 characters.
+func dispatchIsolation(dispatchEnabled bool, target *v1alpha1.SpiceDBVersion) string {
+	if !dispatchEnabled || target == nil || !slices.Contains(target.Attributes, v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch) {
+		return ""
+	}
+	return shortHash(target.Name)
+}
+
+// shortHash returns six hex characters of the sha256 of s
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:6]
+}
+
+// dispatchPortName returns the name of the container port that serves
+// dispatch. Services find dispatch pods by this name, so isolated pods use
+// a name that the Services in front of other versions don't match.
+func (c *Config) dispatchPortName() string {
+	if len(c.DispatchIsolation) == 0 {
+		return dispatchPortName
+	}
+	return dispatchPortName + "-" + c.DispatchIsolation
+}
+
+// isolatedDispatchServiceName returns the name of the Service in front of an
+// isolated dispatch ring
+func isolatedDispatchServiceName(name, isolation string) string {
+	return fmt.Sprintf("%s-dispatch-%s", name, isolation)
+}
+
+// dispatchComponent returns the component label of the pods that serve
+// dispatch
+func (c *Config) dispatchComponent() string {
+	if c.DispatchTier.Enabled() {
+		return metadata.ComponentDispatchLabelValue
+	}
+	return metadata.ComponentSpiceDBLabelValue
+}
+
+func (c *Config) unpatchedIsolatedDispatchService() *applycorev1.ServiceApplyConfiguration {
+	return applycorev1.Service(isolatedDispatchServiceName(c.Name, c.DispatchIsolation), c.Namespace).
+		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentIsolatedDispatchServiceLabel)).
+		WithSpec(applycorev1.ServiceSpec().
+			WithSelector(metadata.LabelsForComponent(c.Name, c.dispatchComponent())).
+			WithPorts(
+				applycorev1.ServicePort().WithName("dispatch").WithPort(50053).WithTargetPort(intstr.FromString(c.dispatchPortName())),
+			),
+		)
+}
+
+// IsolatedDispatchService returns the Service that pods running the target
+// version dispatch through while they can't dispatch to pods of the version
+// they're replacing. It's only needed when DispatchIsolation is set.
+func (c *Config) IsolatedDispatchService() *applycorev1.ServiceApplyConfiguration {
+	name := isolatedDispatchServiceName(c.Name, c.DispatchIsolation)
+	s := applycorev1.Service(name, c.Namespace)
+	_, _, _ = ApplyPatches(c.unpatchedIsolatedDispatchService(), s, c.Patches, c.patchTemplateData(), c.Resources)
+
+	// ensure patches don't overwrite anything critical for operator function
+	s.WithName(name).WithNamespace(c.Namespace).
+		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentIsolatedDispatchServiceLabel)).
+		WithOwnerReferences(c.ownerRef())
+	s.Spec.WithSelector(metadata.LabelsForComponent(c.Name, c.dispatchComponent()))
+	return s
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+)
+
+func TestDispatchTierDeployments(t *testing.T) {
+	c := &Config{
+		MigrationConfig: MigrationConfig{TargetSpiceDBImage: "image:v1"},
+		SpiceConfig: SpiceConfig{
+			Name:            "test",
+			Namespace:       "test",
+			Replicas:        2,
+			EnvPrefix:       "SPICEDB",
+			DispatchEnabled: true,
+			Passthrough:     map[string]string{"dispatchClusterEnabled": "true"},
+			DispatchTier: DispatchTierConfig{
+				Replicas: 5,
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+				},
+			},
+		},
+	}
+
+	envValue := func(envs []applycorev1.EnvVarApplyConfiguration, name string) string {
+		for _, e := range envs {
+			if *e.Name == name && e.Value != nil {
+				return *e.Value
+			}
+		}
+		return ""
+	}
+
+	api := c.Deployment("migration", "secret")
+	require.Equal(t, "test-spicedb", *api.Name)
+	require.Equal(t, int32(2), *api.Spec.Replicas)
+	require.Equal(t, metadata.ComponentSpiceDBLabelValue, api.Spec.Template.Labels[metadata.ComponentLabelKey])
+	apiContainer := api.Spec.Template.Spec.Containers[0]
+	require.Nil(t, apiContainer.Resources)
+	require.Equal(t, "kubernetes:///test-dispatch.test:dispatch", envValue(apiContainer.Env, "SPICEDB_DISPATCH_UPSTREAM_ADDR"))
+	require.Equal(t, "false", envValue(apiContainer.Env, "SPICEDB_DISPATCH_CLUSTER_ENABLED"))
+
+	dispatch := c.DispatchDeployment("migration", "secret")
+	require.Equal(t, "test-spicedb-dispatch", *dispatch.Name)
+	require.Equal(t, int32(5), *dispatch.Spec.Replicas)
+	require.Equal(t, "migration", dispatch.Annotations[metadata.SpiceDBMigrationRequirementsKey])
+	require.Equal(t, metadata.ComponentDispatchLabelValue, dispatch.Labels[metadata.ComponentLabelKey])
+	require.Equal(t, metadata.ComponentDispatchLabelValue, dispatch.Spec.Template.Labels[metadata.ComponentLabelKey])
+	require.Equal(t, map[string]string{"app.kubernetes.io/instance": "test-spicedb-dispatch"}, dispatch.Spec.Selector.MatchLabels)
+	dispatchContainer := dispatch.Spec.Template.Spec.Containers[0]
+	require.Equal(t, c.DispatchTier.Resources.Limits, *dispatchContainer.Resources.Limits)
+	require.Equal(t, "kubernetes:///test-dispatch.test:dispatch", envValue(dispatchContainer.Env, "SPICEDB_DISPATCH_UPSTREAM_ADDR"))
+	require.Equal(t, "true", envValue(dispatchContainer.Env, "SPICEDB_DISPATCH_CLUSTER_ENABLED"))
+	require.Len(t, dispatchContainer.Ports, 4)
+
+	service := c.DispatchService()
+	require.Equal(t, "test-dispatch", *service.Name)
+	require.Equal(t, metadata.LabelsForComponent("test", metadata.ComponentDispatchLabelValue), service.Spec.Selector)
+}
+
+func TestDispatchIsolation(t *testing.T) {
+	incompatible := &v1alpha1.SpiceDBVersion{
+		Name:       "v1.2.0",
+		Channel:    "stable",
+		Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch},
+	}
+	require.Empty(t, dispatchIsolation(true, nil))
+	require.Empty(t, dispatchIsolation(true, &v1alpha1.SpiceDBVersion{Name: "v1.2.0", Channel: "stable"}))
+	require.Empty(t, dispatchIsolation(false, incompatible))
+	isolation := dispatchIsolation(true, incompatible)
+	require.Len(t, isolation, 6)
+
+	tests := []struct {
+		name              string
+		tier              DispatchTierConfig
+		expectedComponent string
+	}{
+		{
+			name:              "api pods serve dispatch",
+			expectedComponent: metadata.ComponentSpiceDBLabelValue,
+		},
+		{
+			name:              "dispatch tier serves dispatch",
+			tier:              DispatchTierConfig{Replicas: 2},
+			expectedComponent: metadata.ComponentDispatchLabelValue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				MigrationConfig: MigrationConfig{TargetSpiceDBImage: "image:v1.2.0"},
+				SpiceConfig: SpiceConfig{
+					Name:              "test",
+					Namespace:         "test",
+					Replicas:          2,
+					EnvPrefix:         "SPICEDB",
+					DispatchEnabled:   true,
+					DispatchTier:      tt.tier,
+					DispatchIsolation: isolation,
+				},
+			}
+			upstream := "kubernetes:///test-dispatch-" + isolation + ".test:dispatch"
+			portName := "dispatch-" + isolation
+			require.LessOrEqual(t, len(portName), 15)
+
+			deployments := []*applycorev1.PodTemplateSpecApplyConfiguration{c.Deployment("migration", "secret").Spec.Template}
+			if tt.tier.Enabled() {
+				deployments = append(deployments, c.DispatchDeployment("migration", "secret").Spec.Template)
+			}
+			for _, d := range deployments {
+				container := d.Spec.Containers[0]
+				for _, e := range container.Env {
+					if *e.Name == "SPICEDB_DISPATCH_UPSTREAM_ADDR" {
+						require.Equal(t, upstream, *e.Value)
+					}
+				}
+				for _, p := range container.Ports {
+					require.NotEqual(t, "dispatch", *p.Name)
+				}
+			}
+
+			service := c.IsolatedDispatchService()
+			require.Equal(t, "test-dispatch-"+isolation, *service.Name)
+			require.Equal(t, metadata.LabelsForComponent("test", tt.expectedComponent), service.Spec.Selector)
+			require.Equal(t, intstr.FromString(portName), *service.Spec.Ports[0].TargetPort)
+
+			// the shared Services only select pods that serve dispatch on
+			// the shared port name, so they skip the isolated pods
+			for _, p := range c.Service().Spec.Ports {
+				if *p.Name == "dispatch" {
+					require.Equal(t, intstr.FromString("dispatch"), *p.TargetPort)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,90 @@
 package config
 
-import "github.com/authzed/spicedb-operator/pkg/updates"
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
 
 // OperatorConfig holds operator-wide config that is used across all objects
 type OperatorConfig struct {
 	ImageName string `json:"imageName,omitempty"`
+
+	// RegistryRewrites replace the registry or repository of the images that
+	// the operator runs, so that channels can be used unchanged with a
+	// mirror. The first matching rule is used.
+	RegistryRewrites []RegistryRewrite `json:"registryRewrites,omitempty"`
+
+	// ImagePullSecrets are the names of secrets, in each cluster's namespace,
+	// that are used to pull images for the spicedb pods and jobs.
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+
+	// Defaults are used for every cluster unless the cluster sets them
+	// itself. Default patches are applied before the cluster's patches.
+	Defaults *ClusterOverlay `json:"defaults,omitempty"`
+
+	// Overrides are enforced for every cluster, even if the cluster sets
+	// them itself. Override patches are applied after the cluster's patches.
+	Overrides *ClusterOverlay `json:"overrides,omitempty"`
+
 	updates.UpdateGraph
 }
 
+// RegistryRewrite replaces the From prefix of an image with To, e.g.
+// ghcr.io/authzed/spicedb with registry.internal/mirror/spicedb.
+type RegistryRewrite struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// matches returns true if From is the image's repository or one of its
+// parent paths.
+func (r RegistryRewrite) matches(image string) bool {
+	if len(r.From) == 0 || !strings.HasPrefix(image, r.From) {
+		return false
+	}
+	rest := image[len(r.From):]
+	return len(rest) == 0 || strings.ContainsAny(rest[:1], "/:@")
+}
+
+// RewriteImage applies the first matching registry rewrite to an image.
+func (o OperatorConfig) RewriteImage(image string) string {
+	for _, r := range o.RegistryRewrites {
+		if r.matches(image) {
+			return r.To + image[len(r.From):]
+		}
+	}
+	return image
+}
+
+// ClusterOverlay is config and patches that the operator merges into every
+// SpiceDBCluster.
+type ClusterOverlay struct {
+	// Config has the same keys as a SpiceDBCluster's spec.config.
+	Config json.RawMessage `json:"config,omitempty"`
+
+	Patches []v1alpha1.Patch `json:"patches,omitempty"`
+}
+
+// config returns the overlay's config, which is empty if it isn't set.
+func (c *ClusterOverlay) config() json.RawMessage {
+	if c == nil {
+		return nil
+	}
+	return c.Config
+}
+
+// patches returns the overlay's patches, which is empty if it isn't set.
+func (c *ClusterOverlay) patches() []v1alpha1.Patch {
+	if c == nil {
+		return nil
+	}
+	return c.Patches
+}
+
 func NewOperatorConfig() OperatorConfig {
 	return OperatorConfig{
 		UpdateGraph: updates.UpdateGraph{
@@ -18,7 +95,26 @@ func NewOperatorConfig() OperatorConfig {
 
 func (o OperatorConfig) Copy() OperatorConfig {
 	return OperatorConfig{
-		ImageName:   o.ImageName,
-		UpdateGraph: o.UpdateGraph.Copy(),
+		ImageName:        o.ImageName,
+		RegistryRewrites: slices.Clone(o.RegistryRewrites),
+		ImagePullSecrets: slices.Clone(o.ImagePullSecrets),
+		Defaults:         o.Defaults.Copy(),
+		Overrides:        o.Overrides.Copy(),
+		UpdateGraph:      o.UpdateGraph.Copy(),
+	}
+}
+
+// Copy returns a copy of the overlay, or nil if it isn't set.
+func (c *ClusterOverlay) Copy() *ClusterOverlay {
+	if c == nil {
+		return nil
+	}
+	out := &ClusterOverlay{Config: slices.Clone(c.Config)}
+	if c.Patches != nil {
+		out.Patches = make([]v1alpha1.Patch, 0, len(c.Patches))
+		for _, p := range c.Patches {
+			out.Patches = append(out.Patches, *p.DeepCopy())
+		}
 	}
+	return out
 }
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	openapitesting "k8s.io/kubectl/pkg/util/openapi/testing"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+func TestRewriteImage(t *testing.T) {
+	global := OperatorConfig{RegistryRewrites: []RegistryRewrite{
+		{From: "ghcr.io/authzed/spicedb", To: "registry.internal/mirror/spicedb"},
+		{From: "ghcr.io", To: "registry.internal/ghcr"},
+		{From: "postgres", To: "registry.internal/library/postgres"},
+	}}
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "ghcr.io/authzed/spicedb:v1.30.0", want: "registry.internal/mirror/spicedb:v1.30.0"},
+		{image: "ghcr.io/authzed/spicedb@sha256:abc", want: "registry.internal/mirror/spicedb@sha256:abc"},
+		{image: "ghcr.io/authzed/spicedb", want: "registry.internal/mirror/spicedb"},
+		{image: "ghcr.io/authzed/spicedb-operator:v1", want: "registry.internal/ghcr/authzed/spicedb-operator:v1"},
+		{image: "postgres:16", want: "registry.internal/library/postgres:16"},
+		{image: "postgresql:16", want: "postgresql:16"},
+		{image: "quay.io/authzed/spicedb:v1", want: "quay.io/authzed/spicedb:v1"},
+		{image: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			require.Equal(t, tt.want, global.RewriteImage(tt.image))
+		})
+	}
+}
+
+func TestRegistryMirrorAndPullSecrets(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	global := OperatorConfig{
+		ImageName:        "ghcr.io/authzed/spicedb",
+		RegistryRewrites: []RegistryRewrite{{From: "ghcr.io/authzed/spicedb", To: "registry.internal/mirror/spicedb"}},
+		ImagePullSecrets: []string{"mirror-creds"},
+		UpdateGraph: updates.UpdateGraph{
+			Channels: []updates.Channel{{
+				Name:     "memory",
+				Metadata: map[string]string{"datastore": "memory", "default": "true"},
+				Nodes:    []updates.State{{ID: "v1", Tag: "v1"}},
+				Edges:    map[string][]string{"v1": {}},
+			}},
+		},
+	}
+	cluster := &v1alpha1.SpiceDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec:       v1alpha1.ClusterSpec{Config: json.RawMessage(`{"datastoreEngine": "memory"}`)},
+	}
+	c, _, err := NewConfig(cluster, &global, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk")}}, resources, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "registry.internal/mirror/spicedb:v1", c.TargetSpiceDBImage)
+
+	wantSecrets := []applycorev1.LocalObjectReferenceApplyConfiguration{*applycorev1.LocalObjectReference().WithName("mirror-creds")}
+	deployment := c.Deployment("migration", "secret").Spec.Template.Spec
+	require.Equal(t, "registry.internal/mirror/spicedb:v1", *deployment.Containers[0].Image)
+	require.Equal(t, wantSecrets, deployment.ImagePullSecrets)
+	job := c.MigrationJob("migration").Spec.Template.Spec
+	require.Equal(t, "registry.internal/mirror/spicedb:v1", *job.Containers[0].Image)
+	require.Equal(t, wantSecrets, job.ImagePullSecrets)
+}
+
+func TestOperatorConfigOmitsEmptyOverlays(t *testing.T) {
+	out, err := json.Marshal(OperatorConfig{ImageName: "image"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"imageName": "image"}`, string(out))
+
+	cfg := OperatorConfig{Defaults: &ClusterOverlay{Config: json.RawMessage(`{"logLevel":"debug"}`)}}
+	out, err = json.Marshal(cfg.Copy())
+	require.NoError(t, err)
+	require.JSONEq(t, `{"defaults": {"config": {"logLevel": "debug"}}}`, string(out))
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func newKey[V comparable](k string, defaultValue V) *key[V] {
@@ -149,3 +152,59 @@ func (k metadataSetKey) pop(config RawConfig, objectType, metadataType string) (
 	}
 	return
 }
+
+// jsonKey holds a value that is decoded from its json representation, e.g.
+// a kube api type like corev1.ResourceRequirements.
+type jsonKey[V any] string
+
+func (k jsonKey[V]) pop(config RawConfig) (out V, err error) {
+	v, ok := config[string(k)]
+	delete(config, string(k))
+	if !ok {
+		return
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&out); err != nil {
+		var empty V
+		return empty, fmt.Errorf("invalid value for %s: %w", k, err)
+	}
+	return
+}
+
+// durationKey holds a duration given as a go duration string (e.g. "10m")
+// or as a number of seconds.
+type durationKey string
+
+func (k durationKey) pop(config RawConfig) (out time.Duration, err error) {
+	v, ok := config[string(k)]
+	delete(config, string(k))
+	if !ok {
+		return
+	}
+
+	switch value := v.(type) {
+	case string:
+		out, err = time.ParseDuration(value)
+	case float64:
+		out = time.Duration(value * float64(time.Second))
+	case int64:
+		out = time.Duration(value) * time.Second
+	case int:
+		out = time.Duration(value) * time.Second
+	default:
+		err = fmt.Errorf("expected duration string or seconds for key %s", k)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %w", k, err)
+	}
+	if out < 0 {
+		return 0, fmt.Errorf("invalid value for %s %s: must not be negative", k, out)
+	}
+	return
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 var emptyConfig = RawConfig{}
@@ -103,6 +106,40 @@ func TestIntOrStringKey(t *testing.T) {
 	}
 }
 
+func TestDurationKey(t *testing.T) {
+	for _, val := range []struct {
+		description string
+		value       any
+		expected    time.Duration
+		err         bool
+	}{
+		{"returns zero when absent", nil, 0, false},
+		{"returns parsed duration string", "1h30m", 90 * time.Minute, false},
+		{"returns seconds from float", float64(90), 90 * time.Second, false},
+		{"fails when invalid string", "ten minutes", 0, true},
+		{"fails when negative", "-1m", 0, true},
+		{"fails when unexpected type", true, 0, true},
+	} {
+		t.Run(val.description, func(t *testing.T) {
+			sk := durationKey("test")
+			config := emptyConfig
+			if val.value != nil {
+				config = RawConfig{"test": val.value}
+			}
+			result, err := sk.pop(config)
+			if val.value != nil {
+				require.Empty(t, config)
+			}
+			if val.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, val.expected, result)
+			}
+		})
+	}
+}
+
 func TestMetadataSetKey(t *testing.T) {
 	input := map[string]any{"k": "v", "k2": "v2"}
 	invalidInput := map[string]any{"k": 1, "k2": "v2"}
@@ -148,3 +185,41 @@ func TestMetadataSetKey(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONKey(t *testing.T) {
+	for _, val := range []struct {
+		description string
+		value       any
+		expected    corev1.ResourceRequirements
+		err         bool
+	}{
+		{"returns empty when absent", nil, corev1.ResourceRequirements{}, false},
+		{
+			"parses requests and limits",
+			map[string]any{"requests": map[string]any{"cpu": "500m"}, "limits": map[string]any{"memory": "1Gi"}},
+			corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			false,
+		},
+		{"fails on unknown fields", map[string]any{"request": map[string]any{"cpu": "1"}}, corev1.ResourceRequirements{}, true},
+		{"fails on invalid quantities", map[string]any{"limits": map[string]any{"cpu": "lots"}}, corev1.ResourceRequirements{}, true},
+	} {
+		t.Run(val.description, func(t *testing.T) {
+			k := jsonKey[corev1.ResourceRequirements]("test")
+			config := emptyConfig
+			if val.value != nil {
+				config = RawConfig{"test": val.value}
+			}
+			result, err := k.pop(config)
+			require.Empty(t, config)
+			if val.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, val.expected, result)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	applybatchv1 "k8s.io/client-go/applyconfigurations/batch/v1"
+)
+
+// migrationDeadlineGrace is how long after the migration timeout kube stops
+// the job itself. The operator notices the timeout first and captures logs
+// from the job's pods before kube terminates them; the job deadline only
+// matters if the operator isn't running or the cluster has been paused.
+const migrationDeadlineGrace = time.Minute
+
+// MigrationJobConfig holds settings for the migration job that don't affect
+// which migrations run, so changing them doesn't trigger a new migration.
+type MigrationJobConfig struct {
+	// Timeout is how long the migration job may run before the cluster is
+	// paused. Zero means no timeout.
+	Timeout time.Duration
+
+	// BackoffLimit is the number of retries before the job fails. Nil uses
+	// the kube default.
+	BackoffLimit *int32
+
+	// TTL is how long a finished job is kept before kube deletes it. Nil
+	// keeps finished jobs until the operator cleans them up after a rollout.
+	TTL *time.Duration
+
+	// Resources override the spicedb resources for the migration container.
+	Resources corev1.ResourceRequirements
+}
+
+func newMigrationJobConfig(config RawConfig) (MigrationJobConfig, []error) {
+	var m MigrationJobConfig
+	errs := make([]error, 0)
+	var err error
+	if m.Timeout, err = migrationTimeoutKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	if _, ok := config[migrationBackoffLimitKey.key]; ok {
+		backoffLimit, err := migrationBackoffLimitKey.pop(config)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("invalid value for %s: %w", migrationBackoffLimitKey.key, err))
+		case backoffLimit < 0:
+			errs = append(errs, fmt.Errorf("invalid value for %s %d: must not be negative", migrationBackoffLimitKey.key, backoffLimit))
+		default:
+			m.BackoffLimit = &backoffLimit
+		}
+	}
+	if _, ok := config[string(migrationJobTTLKey)]; ok {
+		ttl, err := migrationJobTTLKey.pop(config)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			m.TTL = &ttl
+		}
+	}
+	if m.Resources, err = migrationResourcesKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	return m, errs
+}
+
+// migrationJobResources returns the resources of the migration container,
+// which default to the resources of the spicedb pods.
+func (c *Config) migrationJobResources() corev1.ResourceRequirements {
+	if r := c.MigrationJobSettings.Resources; len(r.Limits) > 0 || len(r.Requests) > 0 {
+		return r
+	}
+	return c.Scheduling.Resources
+}
+
+// applyMigrationJobSettings sets the deadline, backoff and ttl of the
+// migration job.
+func (c *Config) applyMigrationJobSettings(spec *applybatchv1.JobSpecApplyConfiguration) *applybatchv1.JobSpecApplyConfiguration {
+	m := c.MigrationJobSettings
+	if m.Timeout > 0 {
+		spec.WithActiveDeadlineSeconds(int64((m.Timeout + migrationDeadlineGrace).Seconds()))
+	}
+	if m.BackoffLimit != nil {
+		spec.WithBackoffLimit(*m.BackoffLimit)
+	}
+	if m.TTL != nil {
+		spec.WithTTLSecondsAfterFinished(int32(m.TTL.Seconds()))
+	}
+	return spec
+}
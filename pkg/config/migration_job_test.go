@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNewMigrationJobConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   RawConfig
+		want     MigrationJobConfig
+		wantErrs int
+	}{
+		{
+			name:   "empty",
+			config: RawConfig{},
+		},
+		{
+			name: "all keys",
+			config: RawConfig{
+				"migrationTimeout":      "30m",
+				"migrationBackoffLimit": "0",
+				"migrationJobTTL":       float64(3600),
+				"migrationResources":    map[string]any{"limits": map[string]any{"memory": "2Gi"}},
+			},
+			want: MigrationJobConfig{
+				Timeout:      30 * time.Minute,
+				BackoffLimit: ptr(int32(0)),
+				TTL:          ptr(time.Hour),
+				Resources:    corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}},
+			},
+		},
+		{
+			name: "invalid values",
+			config: RawConfig{
+				"migrationTimeout":      "soon",
+				"migrationBackoffLimit": "-1",
+				"migrationJobTTL":       "forever",
+			},
+			wantErrs: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := newMigrationJobConfig(tt.config)
+			require.Len(t, errs, tt.wantErrs)
+			require.Empty(t, tt.config)
+			if tt.wantErrs == 0 {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMigrationJobSettings(t *testing.T) {
+	spiceResources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}}
+	migrationResources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}}
+
+	c := &Config{SpiceConfig: SpiceConfig{Name: "test", Namespace: "test", Scheduling: SchedulingConfig{Resources: spiceResources}}}
+	job := c.MigrationJob("migration")
+	require.Nil(t, job.Spec.ActiveDeadlineSeconds)
+	require.Nil(t, job.Spec.BackoffLimit)
+	require.Nil(t, job.Spec.TTLSecondsAfterFinished)
+	require.Equal(t, spiceResources.Limits, *job.Spec.Template.Spec.Containers[0].Resources.Limits)
+
+	c.MigrationJobSettings = MigrationJobConfig{
+		Timeout:      10 * time.Minute,
+		BackoffLimit: ptr(int32(2)),
+		TTL:          ptr(time.Hour),
+		Resources:    migrationResources,
+	}
+	job = c.MigrationJob("migration")
+	require.Equal(t, int64(11*60), *job.Spec.ActiveDeadlineSeconds)
+	require.Equal(t, int32(2), *job.Spec.BackoffLimit)
+	require.Equal(t, int32(3600), *job.Spec.TTLSecondsAfterFinished)
+	require.Equal(t, migrationResources.Limits, *job.Spec.Template.Spec.Containers[0].Resources.Limits)
+
+	// the deployment keeps the spicedb resources
+	deployment := c.Deployment("migration", "secret")
+	require.Equal(t, spiceResources.Limits, *deployment.Spec.Template.Spec.Containers[0].Resources.Limits)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// applyOperatorConfig merges the operator defaults and overrides into a
+// cluster's config. Keys the cluster doesn't set are taken from the
+// defaults, and overrides replace whatever the cluster set. It returns the
+// keys that came from the operator config, or nil if there were none.
+func applyOperatorConfig(config RawConfig, global *OperatorConfig) (*v1alpha1.OperatorConfigStatus, []error, error) {
+	defaults, err := parseOverlayConfig(global.Defaults.config())
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't parse operator default config: %w", err)
+	}
+	overrides, err := parseOverlayConfig(global.Overrides.config())
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't parse operator override config: %w", err)
+	}
+	if len(defaults) == 0 && len(overrides) == 0 {
+		return nil, nil, nil
+	}
+
+	status := &v1alpha1.OperatorConfigStatus{}
+	warnings := make([]error, 0)
+	for _, k := range sortedKeys(defaults) {
+		if _, ok := overrides[k]; ok {
+			continue
+		}
+		if _, ok := config[k]; !ok {
+			config[k] = defaults[k]
+			status.DefaultedKeys = append(status.DefaultedKeys, k)
+		}
+	}
+	for _, k := range sortedKeys(overrides) {
+		if existing, ok := config[k]; ok && !reflect.DeepEqual(existing, overrides[k]) {
+			warnings = append(warnings, fmt.Errorf("%s is overridden by the operator config", k))
+		}
+		config[k] = overrides[k]
+		status.OverriddenKeys = append(status.OverriddenKeys, k)
+	}
+	if len(status.DefaultedKeys) == 0 && len(status.OverriddenKeys) == 0 {
+		return nil, warnings, nil
+	}
+	return status, warnings, nil
+}
+
+func sortedKeys(config RawConfig) []string {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func parseOverlayConfig(raw json.RawMessage) (RawConfig, error) {
+	config := RawConfig(make(map[string]any))
+	if len(raw) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// operatorPatches surrounds the cluster's patches with the operator default
+// and override patches, and numbers them for errors and status.
+func operatorPatches(global *OperatorConfig, cluster []v1alpha1.Patch) ([]v1alpha1.Patch, []patchRef) {
+	defaults, overrides := global.Defaults.patches(), global.Overrides.patches()
+	if len(defaults) == 0 && len(overrides) == 0 {
+		return cluster, nil
+	}
+	patches := make([]v1alpha1.Patch, 0, len(defaults)+len(cluster)+len(overrides))
+	patches = append(patches, defaults...)
+	patches = append(patches, cluster...)
+	patches = append(patches, overrides...)
+	return patches, patchRefs(len(defaults), len(cluster), len(overrides))
+}
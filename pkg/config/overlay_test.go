@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	openapitesting "k8s.io/kubectl/pkg/util/openapi/testing"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+func TestApplyOperatorConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       RawConfig
+		defaults     string
+		overrides    string
+		wantConfig   RawConfig
+		wantStatus   *v1alpha1.OperatorConfigStatus
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name:       "no operator config",
+			config:     RawConfig{"logLevel": "debug"},
+			wantConfig: RawConfig{"logLevel": "debug"},
+		},
+		{
+			name:       "defaults fill in unset keys",
+			config:     RawConfig{"logLevel": "debug"},
+			defaults:   `{"logLevel": "info", "replicas": 3}`,
+			wantConfig: RawConfig{"logLevel": "debug", "replicas": float64(3)},
+			wantStatus: &v1alpha1.OperatorConfigStatus{DefaultedKeys: []string{"replicas"}},
+		},
+		{
+			name:       "defaults that the cluster sets aren't reported",
+			config:     RawConfig{"logLevel": "debug"},
+			defaults:   `{"logLevel": "info"}`,
+			wantConfig: RawConfig{"logLevel": "debug"},
+		},
+		{
+			name:         "overrides replace cluster values",
+			config:       RawConfig{"logLevel": "debug", "replicas": float64(3)},
+			defaults:     `{"logLevel": "warn", "extraPodLabels": "team=authz"}`,
+			overrides:    `{"logLevel": "info", "replicas": 3}`,
+			wantConfig:   RawConfig{"logLevel": "info", "replicas": float64(3), "extraPodLabels": "team=authz"},
+			wantStatus:   &v1alpha1.OperatorConfigStatus{DefaultedKeys: []string{"extraPodLabels"}, OverriddenKeys: []string{"logLevel", "replicas"}},
+			wantWarnings: []string{"logLevel is overridden by the operator config"},
+		},
+		{
+			name:     "invalid defaults",
+			config:   RawConfig{},
+			defaults: `["logLevel"]`,
+			wantErr:  "couldn't parse operator default config",
+		},
+		{
+			name:      "invalid overrides",
+			config:    RawConfig{},
+			overrides: `{`,
+			wantErr:   "couldn't parse operator override config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global := &OperatorConfig{}
+			if tt.defaults != "" {
+				global.Defaults = &ClusterOverlay{Config: json.RawMessage(tt.defaults)}
+			}
+			if tt.overrides != "" {
+				global.Overrides = &ClusterOverlay{Config: json.RawMessage(tt.overrides)}
+			}
+			status, warnings, err := applyOperatorConfig(tt.config, global)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantConfig, tt.config)
+			require.Equal(t, tt.wantStatus, status)
+			got := make([]string, 0, len(warnings))
+			for _, w := range warnings {
+				got = append(got, w.Error())
+			}
+			require.ElementsMatch(t, tt.wantWarnings, got)
+		})
+	}
+}
+
+func TestOperatorPatches(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	global := OperatorConfig{
+		ImageName: "image",
+		UpdateGraph: updates.UpdateGraph{
+			Channels: []updates.Channel{{
+				Name:     "memory",
+				Metadata: map[string]string{"datastore": "memory", "default": "true"},
+				Nodes:    []updates.State{{ID: "v1", Tag: "v1"}},
+				Edges:    map[string][]string{"v1": {}},
+			}},
+		},
+	}
+	global.Defaults = &ClusterOverlay{
+		Config: json.RawMessage(`{"extraPodLabels": "team=platform"}`),
+		Patches: []v1alpha1.Patch{
+			{Kind: "Deployment", Patch: json.RawMessage(`{"metadata": {"labels": {"owner": "platform", "tier": "default"}}}`)},
+			{Kind: "Secret", Patch: json.RawMessage(`{"metadata": {"labels": {"owner": "platform"}}}`)},
+		},
+	}
+	global.Overrides = &ClusterOverlay{
+		Patches: []v1alpha1.Patch{
+			{Kind: "Deployment", Patch: json.RawMessage(`{"metadata": {"labels": {"owner": "platform"}}}`)},
+		},
+	}
+	cluster := &v1alpha1.SpiceDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: v1alpha1.ClusterSpec{
+			Config: json.RawMessage(`{"datastoreEngine": "memory"}`),
+			Patches: []v1alpha1.Patch{
+				{Kind: "Deployment", Patch: json.RawMessage(`{"metadata": {"labels": {"owner": "me", "tier": "cluster"}}}`)},
+			},
+		},
+	}
+
+	c, warning, err := NewConfig(cluster, &global, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk")}}, resources, time.Now())
+	require.NoError(t, err)
+
+	// cluster patches win over default patches, override patches win over both
+	labels := c.Deployment("migration", "secret").Labels
+	require.Equal(t, "platform", labels["owner"])
+	require.Equal(t, "cluster", labels["tier"])
+	require.Equal(t, map[string]string{"team": "platform"}, c.ExtraPodLabels)
+	require.Equal(t, &v1alpha1.OperatorConfigStatus{DefaultedKeys: []string{"extraPodLabels"}}, c.OperatorConfigStatus)
+
+	require.Equal(t, []v1alpha1.PatchStatus{
+		{Index: 0, Source: v1alpha1.PatchSourceOperatorDefaults, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment"}},
+		{Index: 1, Source: v1alpha1.PatchSourceOperatorDefaults, Result: v1alpha1.PatchResultUnmatched, Message: "didn't match any objects"},
+		{Index: 0, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment"}},
+		{Index: 0, Source: v1alpha1.PatchSourceOperatorOverrides, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment"}},
+	}, c.PatchStatuses)
+	require.ErrorContains(t, warning, "operator default patch 1 didn't match any objects")
+}
@@ -4,104 +4,312 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	jsonpatch "github.com/evanphx/json-patch"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
-	applymetav1 "k8s.io/client-go/applyconfigurations/meta/v1"
 	"k8s.io/kubectl/pkg/util/openapi"
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
 )
 
 const wildcard = "*"
 
-// ApplyPatches applies a set of patches to an object.
+// ApplyPatches applies a set of patches to an object. Templates in the
+// patches are rendered with data before they're decoded; a nil data applies
+// the patches as-is.
 // It returns the number of patches applied, a bool indicating whether there
 // were matching patches and the input differed from the output, and any errors
 // that occurred.
-func ApplyPatches[K any](object, out K, patches []v1alpha1.Patch, resources openapi.Resources) (int, bool, error) {
+func ApplyPatches[K any](object, out K, patches []v1alpha1.Patch, data *PatchTemplateData, resources openapi.Resources) (int, bool, error) {
+	results, err := applyPatches(object, out, patches, nil, data, resources)
+	if err != nil {
+		return 0, false, err
+	}
+
+	count := 0
+	diff := false
+	errs := make([]error, 0)
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		if r.matched {
+			count++
+		}
+		// return true if there were patches defined and the output differs
+		diff = diff || r.changed
+	}
+	return count, diff, kerrors.NewAggregate(errs)
+}
+
+// patchRef identifies a patch in errors and in the patch status. Patches from
+// the operator config are numbered separately from spec.patches.
+type patchRef struct {
+	source v1alpha1.PatchSource
+	index  int
+}
+
+func (r patchRef) String() string {
+	switch r.source {
+	case v1alpha1.PatchSourceOperatorDefaults:
+		return fmt.Sprintf("operator default patch %d", r.index)
+	case v1alpha1.PatchSourceOperatorOverrides:
+		return fmt.Sprintf("operator override patch %d", r.index)
+	default:
+		return fmt.Sprintf("patch %d", r.index)
+	}
+}
+
+// patchRefs numbers the operator default patches, the cluster's patches
+// and the operator override patches, in the order that they're applied.
+func patchRefs(defaults, cluster, overrides int) []patchRef {
+	refs := make([]patchRef, 0, defaults+cluster+overrides)
+	for i := 0; i < defaults; i++ {
+		refs = append(refs, patchRef{source: v1alpha1.PatchSourceOperatorDefaults, index: i})
+	}
+	for i := 0; i < cluster; i++ {
+		refs = append(refs, patchRef{index: i})
+	}
+	for i := 0; i < overrides; i++ {
+		refs = append(refs, patchRef{source: v1alpha1.PatchSourceOperatorOverrides, index: i})
+	}
+	return refs
+}
+
+// refFor returns the ref of the i-th patch; without refs patches are
+// numbered by their position.
+func refFor(refs []patchRef, i int) patchRef {
+	if i < len(refs) {
+		return refs[i]
+	}
+	return patchRef{index: i}
+}
+
+// patchResult is the outcome of applying a single patch to an object
+type patchResult struct {
+	kind    string
+	matched bool
+	changed bool
+	err     error
+}
+
+// applyPatches applies a set of patches to an object and reports the outcome
+// of each patch. The returned error is only set if the object couldn't be
+// patched at all.
+func applyPatches[K any](object, out K, patches []v1alpha1.Patch, refs []patchRef, data *PatchTemplateData, resources openapi.Resources) ([]patchResult, error) {
 	// marshal object to json for patching
 	encoded, err := json.Marshal(object)
 	if err != nil {
-		return 0, false, fmt.Errorf("error marshalling object to patch: %w", err)
+		return nil, fmt.Errorf("error marshalling object to patch: %w", err)
 	}
 
-	initial := encoded
-
-	// HACK: Unmarshal into TypeMeta to determine `kind` of incoming object.
-	// The ApplyConfiguration objects don't have any getters, so there's no
-	// common interface to use to get their `kind`, even though they all have
-	// a kind field. Golang also doesn't support writing generic functions over
-	// struct members. This hack can be removed if we add getters to the
-	// generated applyconfigurations or golang supports this via generics:
+	// HACK: Unmarshal into PartialObjectMetadata to determine `kind` and
+	// metadata of the incoming object. The ApplyConfiguration objects don't
+	// have any getters, so there's no common interface to use to get their
+	// `kind`, even though they all have a kind field. Golang also doesn't
+	// support writing generic functions over struct members. This hack can be
+	// removed if we add getters to the generated applyconfigurations or golang
+	// supports this via generics:
 	// - https://github.com/golang/go/issues/51259
 	// - https://github.com/golang/go/issues/48522
 	// - https://github.com/kubernetes/kubernetes/issues/113773
-	var typeMeta applymetav1.TypeMetaApplyConfiguration
-	if err := json.Unmarshal(encoded, &typeMeta); err != nil {
-		return 0, false, fmt.Errorf("unable to extract type info from object for patching: %w", err)
+	target, err := patchTarget(encoded)
+	if err != nil {
+		return nil, err
 	}
 
-	if typeMeta.Kind == nil {
-		return 0, false, fmt.Errorf("object doesn't specify kind: %v", object)
+	results := make([]patchResult, len(patches))
+	for i, p := range patches {
+		results[i].kind = target.Kind
+		matches, err := patchMatches(p, target)
+		if err != nil {
+			results[i].err = fmt.Errorf("error matching %s: %w", refFor(refs, i), err)
+			continue
+		}
+		if !matches {
+			continue
+		}
+		results[i].matched = true
+
+		patched, err := applyPatch(refFor(refs, i), p, encoded, target, data, resources)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		results[i].changed = !jsonpatch.Equal(patched, encoded)
+		encoded = patched
 	}
 
-	count := 0
+	if err := json.Unmarshal(encoded, out); err != nil {
+		return results, fmt.Errorf("error converting back to object: %w", err)
+	}
+	return results, nil
+}
+
+// applyPatch applies a strategic merge patch, a single JSON6902 operation or
+// a list of JSON6902 operations to an encoded object.
+func applyPatch(ref patchRef, p v1alpha1.Patch, encoded []byte, target *metav1.PartialObjectMetadata, data *PatchTemplateData, resources openapi.Resources) ([]byte, error) {
+	rendered := []byte(p.Patch)
+	if p.Template && data != nil {
+		var err error
+		if rendered, err = renderPatch(rendered, data); err != nil {
+			return nil, fmt.Errorf("error rendering %s: %w", ref, err)
+		}
+	}
+
+	patchJSON, err := utilyaml.ToJSON(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("error converting %s to json: %w", ref, err)
+	}
+
+	// a list is always a list of JSON6902 operations
+	if bytes.HasPrefix(bytes.TrimSpace(patchJSON), []byte("[")) {
+		json6902patch, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s: %w", ref, err)
+		}
+		patched, err := json6902patch.Apply(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("error applying %s to object: %w", ref, err)
+		}
+		return patched, nil
+	}
+
+	// determine if the patch is a strategic merge or a json6902 patch
+	var json6902op jsonpatch.Operation
+	if err := json.Unmarshal(patchJSON, &json6902op); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", ref, err)
+	}
+
+	// if there's an operation, it's a single json6902 operation
+	if json6902op.Kind() != "unknown" {
+		json6902patch := jsonpatch.Patch([]jsonpatch.Operation{json6902op})
+		patched, err := json6902patch.Apply(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("error applying %s to object: %w", ref, err)
+		}
+		return patched, nil
+	}
+
+	// otherwise, it's a strategic merge patch
+	gv, err := schema.ParseGroupVersion(target.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error applying %s, to object: %w", ref, err)
+	}
+	gvkSchema := resources.LookupResource(gv.WithKind(target.Kind))
+	patched, err := strategicpatch.StrategicMergePatchUsingLookupPatchMeta(encoded, patchJSON, strategicpatch.NewPatchMetaFromOpenAPI(gvkSchema))
+	if err != nil {
+		return nil, fmt.Errorf("error applying %s, to object: %w", ref, err)
+	}
+	return patched, nil
+}
+
+// patchTarget extracts the type and metadata that patches are matched
+// against from an encoded object.
+func patchTarget(encoded []byte) (*metav1.PartialObjectMetadata, error) {
+	var target metav1.PartialObjectMetadata
+	if err := json.Unmarshal(encoded, &target); err != nil {
+		return nil, fmt.Errorf("unable to extract type info from object for patching: %w", err)
+	}
+	if len(target.Kind) == 0 {
+		return nil, fmt.Errorf("object doesn't specify kind: %s", encoded)
+	}
+	return &target, nil
+}
+
+// hasSelector returns true if the patch selects objects by more than kind.
+func hasSelector(p v1alpha1.Patch) bool {
+	return len(p.Name) > 0 || p.LabelSelector != nil || len(p.Component) > 0
+}
+
+// patchKind returns the kind of objects the patch applies to. Patches with
+// a selector but no kind apply to all kinds.
+func patchKind(p v1alpha1.Patch) string {
+	if len(p.Kind) == 0 && hasSelector(p) {
+		return wildcard
+	}
+	return p.Kind
+}
+
+// patchMatches returns true if all of the patch's selectors match the target.
+func patchMatches(p v1alpha1.Patch, target *metav1.PartialObjectMetadata) (bool, error) {
+	if kind := patchKind(p); kind != wildcard && kind != target.Kind {
+		return false, nil
+	}
+	if len(p.Name) > 0 && p.Name != target.Name {
+		return false, nil
+	}
+	if len(p.Component) > 0 && p.Component != target.Labels[metadata.ComponentLabelKey] {
+		return false, nil
+	}
+	if p.LabelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(p.LabelSelector)
+		if err != nil {
+			return false, fmt.Errorf("invalid label selector: %w", err)
+		}
+		if !selector.Matches(labels.Set(target.Labels)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// reportPatches applies the patches to each of the objects and summarizes
+// the outcome of each patch across all of them.
+func reportPatches(objects []any, patches []v1alpha1.Patch, refs []patchRef, data *PatchTemplateData, resources openapi.Resources) ([]v1alpha1.PatchStatus, []error) {
+	if len(patches) == 0 {
+		return nil, nil
+	}
+
+	statuses := make([]v1alpha1.PatchStatus, len(patches))
+	matched := make([]bool, len(patches))
 	errs := make([]error, 0)
-	for i, p := range patches {
-		if p.Kind == *typeMeta.Kind || p.Kind == wildcard {
-			// determine if the patch is a strategic merge or a json6902 patch
-			decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(p.Patch), 100)
-			var json6902op jsonpatch.Operation
-			err = decoder.Decode(&json6902op)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error decoding patch %d: %w", i, err))
+	for _, obj := range objects {
+		results, err := applyPatches(obj, obj, patches, refs, data, resources)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i, r := range results {
+			if r.err != nil {
+				errs = append(errs, r.err)
+				if statuses[i].Result != v1alpha1.PatchResultError {
+					statuses[i].Result = v1alpha1.PatchResultError
+					statuses[i].Message = r.err.Error()
+				}
 				continue
 			}
-
-			// if there's no operation, it's a strategic merge patch, not 6902
-			if json6902op.Kind() == "unknown" {
-				jsonPatch, err := utilyaml.ToJSON(p.Patch)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("error converting patch %d to json: %w", i, err))
-					continue
-				}
-				gv, err := schema.ParseGroupVersion(*typeMeta.APIVersion)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("error applying patch %d, to object: %w", i, err))
-					continue
-				}
-				gvkSchema := resources.LookupResource(gv.WithKind(*typeMeta.Kind))
-				patched, err := strategicpatch.StrategicMergePatchUsingLookupPatchMeta(encoded, jsonPatch, strategicpatch.NewPatchMetaFromOpenAPI(gvkSchema))
-				if err != nil {
-					errs = append(errs, fmt.Errorf("error applying patch %d, to object: %w", i, err))
-					continue
-				}
-				encoded = patched
-			} else {
-				json6902patch := jsonpatch.Patch([]jsonpatch.Operation{json6902op})
-				patched, err := json6902patch.Apply(encoded)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("error applying patch %d to object: %w", i, err))
-					continue
-				}
-				encoded = patched
+			matched[i] = matched[i] || r.matched
+			if r.changed && !slices.Contains(statuses[i].Kinds, r.kind) {
+				statuses[i].Kinds = append(statuses[i].Kinds, r.kind)
 			}
-			count++
 		}
 	}
 
-	if err := json.Unmarshal(encoded, out); err != nil {
-		errs = append(errs, fmt.Errorf("error converting back to object: %w", err))
-	}
-
-	diff := false
-	if count > 0 {
-		// return true if there were patches defined and the output bytes differ
-		diff = !bytes.Equal(encoded, initial)
+	for i := range statuses {
+		ref := refFor(refs, i)
+		statuses[i].Index = ref.index
+		statuses[i].Source = ref.source
+		slices.Sort(statuses[i].Kinds)
+		switch {
+		case statuses[i].Result == v1alpha1.PatchResultError:
+		case len(statuses[i].Kinds) > 0:
+			statuses[i].Result = v1alpha1.PatchResultApplied
+		case matched[i]:
+			statuses[i].Result = v1alpha1.PatchResultNoOp
+			statuses[i].Message = "matched objects but didn't change them"
+		default:
+			statuses[i].Result = v1alpha1.PatchResultUnmatched
+			statuses[i].Message = "didn't match any objects"
+		}
 	}
-	return count, diff, kerrors.NewAggregate(errs)
+	return statuses, errs
 }
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// PatchTemplateData is the data available to templates in patches, e.g.
+// `{{ .Cluster.Name }}` or `{{ .TargetVersion }}`.
+type PatchTemplateData struct {
+	Cluster         PatchTemplateCluster
+	TargetVersion   string
+	TargetImage     string
+	TargetMigration string
+	TargetPhase     string
+	Channel         string
+	Engine          string
+}
+
+// PatchTemplateCluster identifies the SpiceDBCluster being patched.
+type PatchTemplateCluster struct {
+	Name      string
+	Namespace string
+}
+
+// patchTemplateFuncs are the only functions that patch templates may call.
+// Everything else, including the builtins that format values (print, printf)
+// or call functions (call), is rejected when the template is parsed.
+var patchTemplateFuncs = map[string]bool{
+	"and":   true,
+	"or":    true,
+	"not":   true,
+	"eq":    true,
+	"ne":    true,
+	"lt":    true,
+	"le":    true,
+	"gt":    true,
+	"ge":    true,
+	"len":   true,
+	"index": true,
+}
+
+// patchTemplateData returns the values that templated patches are rendered
+// with.
+func (c *Config) patchTemplateData() *PatchTemplateData {
+	data := &PatchTemplateData{
+		Cluster: PatchTemplateCluster{
+			Name:      c.Name,
+			Namespace: c.Namespace,
+		},
+		TargetImage:     c.TargetSpiceDBImage,
+		TargetMigration: c.TargetMigration,
+		TargetPhase:     c.TargetPhase,
+		Engine:          c.DatastoreEngine,
+	}
+	if c.SpiceDBVersion != nil {
+		data.TargetVersion = c.SpiceDBVersion.Name
+		data.Channel = c.SpiceDBVersion.Channel
+	}
+	return data
+}
+
+// renderPatch evaluates the templates in a patch. Templates are restricted to
+// reading fields of the data, comparisons and conditionals so that a patch
+// can't loop, recurse or produce unbounded output.
+func renderPatch(patch []byte, data *PatchTemplateData) ([]byte, error) {
+	if !bytes.Contains(patch, []byte("{{")) {
+		return patch, nil
+	}
+	tmpl, err := template.New("patch").Option("missingkey=error").Parse(string(patch))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, fmt.Errorf("invalid template: defining templates is not allowed")
+	}
+	if err := checkPatchTemplate(tmpl.Root); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("error rendering template: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// checkPatchTemplate walks a parsed template and rejects anything but
+// the allowed actions and functions.
+func checkPatchTemplate(node parse.Node) error {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkPatchTemplate(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkPatchTemplate(n.Pipe)
+	case *parse.IfNode:
+		return checkPatchTemplateBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkPatchTemplateBranch(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkPatchTemplate(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkPatchTemplate(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkPatchTemplate(n.Node)
+	case *parse.IdentifierNode:
+		if !patchTemplateFuncs[n.Ident] {
+			return fmt.Errorf("function %q is not allowed", n.Ident)
+		}
+	case *parse.TextNode, *parse.CommentNode, *parse.FieldNode, *parse.VariableNode,
+		*parse.DotNode, *parse.NilNode, *parse.BoolNode, *parse.NumberNode, *parse.StringNode:
+	default:
+		return fmt.Errorf("%q is not allowed", node.String())
+	}
+	return nil
+}
+
+func checkPatchTemplateBranch(n *parse.BranchNode) error {
+	if err := checkPatchTemplate(n.Pipe); err != nil {
+		return err
+	}
+	if err := checkPatchTemplate(n.List); err != nil {
+		return err
+	}
+	return checkPatchTemplate(n.ElseList)
+}
+
+// validatePatchTemplates renders every templated patch, including ones that
+// don't match any objects, so that template errors are reported up front.
+func validatePatchTemplates(patches []v1alpha1.Patch, refs []patchRef, data *PatchTemplateData) []error {
+	errs := make([]error, 0)
+	for i, p := range patches {
+		if !p.Template {
+			continue
+		}
+		if _, err := renderPatch(p.Patch, data); err != nil {
+			errs = append(errs, fmt.Errorf("error rendering %s: %w", refFor(refs, i), err))
+		}
+	}
+	return errs
+}
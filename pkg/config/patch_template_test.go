@@ -0,0 +1,139 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	openapitesting "k8s.io/kubectl/pkg/util/openapi/testing"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+func TestRenderPatch(t *testing.T) {
+	data := &PatchTemplateData{
+		Cluster:       PatchTemplateCluster{Name: "test", Namespace: "ns"},
+		TargetVersion: "v1.2.3",
+		Engine:        "postgres",
+	}
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "no template",
+			patch: `{"metadata": {"labels": {"a": "b"}}}`,
+			want:  `{"metadata": {"labels": {"a": "b"}}}`,
+		},
+		{
+			name:  "fields",
+			patch: `{"metadata": {"labels": {"cluster": "{{ .Cluster.Namespace }}-{{ .Cluster.Name }}", "version": "{{ .TargetVersion }}"}}}`,
+			want:  `{"metadata": {"labels": {"cluster": "ns-test", "version": "v1.2.3"}}}`,
+		},
+		{
+			name:  "conditionals",
+			patch: "{{ if eq .Engine `postgres` }}{\"metadata\": {\"labels\": {\"pg\": \"true\"}}}{{ else }}{}{{ end }}",
+			want:  `{"metadata": {"labels": {"pg": "true"}}}`,
+		},
+		{
+			name:    "unknown field",
+			patch:   `{"metadata": {"name": "{{ .Missing }}"}}`,
+			wantErr: "error rendering template",
+		},
+		{
+			name:    "parse error",
+			patch:   `{"metadata": {"name": "{{ .Cluster.Name "}}`,
+			wantErr: "invalid template",
+		},
+		{
+			name:    "disallowed function",
+			patch:   `{"metadata": {"name": "{{ printf "%0999999999d" 1 }}"}}`,
+			wantErr: `invalid template: function "printf" is not allowed`,
+		},
+		{
+			name:    "range",
+			patch:   `{{ range 1000000000 }}x{{ end }}`,
+			wantErr: "invalid template: \"{{range",
+		},
+		{
+			name:    "defined templates",
+			patch:   `{{ define "a" }}{{ template "a" }}{{ end }}{{ template "a" }}`,
+			wantErr: "invalid template: defining templates is not allowed",
+		},
+		{
+			name:    "template calls",
+			patch:   `{{ template "patch" }}`,
+			wantErr: "invalid template: \"{{template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderPatch([]byte(tt.patch), data)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestTemplatedPatches(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	global := OperatorConfig{
+		ImageName: "image",
+		UpdateGraph: updates.UpdateGraph{
+			Channels: []updates.Channel{{
+				Name:     "memory",
+				Metadata: map[string]string{"datastore": "memory", "default": "true"},
+				Nodes:    []updates.State{{ID: "v1", Tag: "v1"}},
+				Edges:    map[string][]string{"v1": {}},
+			}},
+		},
+	}
+	cluster := &v1alpha1.SpiceDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"},
+		Spec: v1alpha1.ClusterSpec{
+			Config: json.RawMessage(`{"datastoreEngine": "memory"}`),
+			Patches: []v1alpha1.Patch{{
+				Kind: "Deployment",
+				Patch: json.RawMessage(`{"metadata": {"annotations": {
+					"example.com/cluster": "{{ .Cluster.Namespace }}/{{ .Cluster.Name }}",
+					"example.com/target": "{{ .Engine }}@{{ .TargetVersion }} ({{ .Channel }})"
+				}}}`),
+				Template: true,
+			}, {
+				Kind:  "Deployment",
+				Patch: json.RawMessage(`{"metadata": {"annotations": {"example.com/literal": "{{ .Values.name }}"}}}`),
+			}},
+		},
+	}
+	c, _, err := NewConfig(cluster, &global, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk")}}, resources, time.Now())
+	require.NoError(t, err)
+
+	annotations := c.Deployment("migration", "secret").Annotations
+	require.Equal(t, "ns/test", annotations["example.com/cluster"])
+	require.Equal(t, "memory@v1 (memory)", annotations["example.com/target"])
+	require.Equal(t, "{{ .Values.name }}", annotations["example.com/literal"])
+	require.Equal(t, []v1alpha1.PatchStatus{
+		{Index: 0, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment"}},
+		{Index: 1, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment"}},
+	}, c.PatchStatuses)
+
+	// templates are validated even if the patch doesn't match anything
+	cluster.Spec.Patches = []v1alpha1.Patch{{
+		Kind:     "Secret",
+		Patch:    json.RawMessage(`{"metadata": {"name": "{{ .Cluster.Missing }}"}}`),
+		Template: true,
+	}}
+	_, _, err = NewConfig(cluster, &global, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk")}}, resources, time.Now())
+	require.ErrorContains(t, err, "error rendering patch 0")
+}
@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	applyappsv1 "k8s.io/client-go/applyconfigurations/apps/v1"
 	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
 	openapitesting "k8s.io/kubectl/pkg/util/openapi/testing"
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+	"github.com/authzed/spicedb-operator/pkg/updates"
 )
 
 func TestApplyPatches(t *testing.T) {
@@ -22,6 +26,7 @@ func TestApplyPatches(t *testing.T) {
 	runPatchTests(t, workloadIdentityPatchTests)
 	runPatchTests(t, schedulerPatchTests)
 	runPatchTests(t, fileMountTests)
+	runPatchTests(t, selectorPatchTests)
 }
 
 type patchTestCase[K any] struct {
@@ -40,7 +45,7 @@ func runPatchTests[K any](t *testing.T, cases []patchTestCase[K]) {
 	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			count, patched, err := ApplyPatches(tt.object, tt.out, tt.patches, resources)
+			count, patched, err := ApplyPatches(tt.object, tt.out, tt.patches, nil, resources)
 			if err != nil {
 				fmt.Println(err.Error())
 			}
@@ -56,6 +61,102 @@ func runPatchTests[K any](t *testing.T, cases []patchTestCase[K]) {
 	}
 }
 
+func labeledDeployment(name, component string) *applyappsv1.DeploymentApplyConfiguration {
+	return applyappsv1.Deployment(name, "test").WithLabels(metadata.LabelsForComponent("test", component))
+}
+
+var labelPatch = json.RawMessage(`
+metadata:
+  labels:
+    added: via-patch`)
+
+var selectorPatchTests = []patchTestCase[*applyappsv1.DeploymentApplyConfiguration]{
+	{
+		name:        "applies if name matches",
+		object:      labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue),
+		out:         applyappsv1.Deployment("test-spicedb", "test"),
+		patches:     []v1alpha1.Patch{{Kind: "Deployment", Name: "test-spicedb", Patch: labelPatch}},
+		want:        labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue).WithLabels(map[string]string{"added": "via-patch"}),
+		wantPatched: true,
+		wantCount:   1,
+	},
+	{
+		name:        "does nothing if name doesn't match",
+		object:      labeledDeployment("test-spicedb-dispatch", metadata.ComponentDispatchLabelValue),
+		out:         applyappsv1.Deployment("test-spicedb-dispatch", "test"),
+		patches:     []v1alpha1.Patch{{Kind: "Deployment", Name: "test-spicedb", Patch: labelPatch}},
+		want:        labeledDeployment("test-spicedb-dispatch", metadata.ComponentDispatchLabelValue),
+		wantPatched: false,
+		wantCount:   0,
+	},
+	{
+		name:        "applies to any kind if component matches",
+		object:      labeledDeployment("test-spicedb-dispatch", metadata.ComponentDispatchLabelValue),
+		out:         applyappsv1.Deployment("test-spicedb-dispatch", "test"),
+		patches:     []v1alpha1.Patch{{Component: metadata.ComponentDispatchLabelValue, Patch: labelPatch}},
+		want:        labeledDeployment("test-spicedb-dispatch", metadata.ComponentDispatchLabelValue).WithLabels(map[string]string{"added": "via-patch"}),
+		wantPatched: true,
+		wantCount:   1,
+	},
+	{
+		name:        "does nothing if component doesn't match",
+		object:      labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue),
+		out:         applyappsv1.Deployment("test-spicedb", "test"),
+		patches:     []v1alpha1.Patch{{Component: metadata.ComponentDispatchLabelValue, Patch: labelPatch}},
+		want:        labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue),
+		wantPatched: false,
+		wantCount:   0,
+	},
+	{
+		name:   "applies if label selector matches",
+		object: labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue),
+		out:    applyappsv1.Deployment("test-spicedb", "test"),
+		patches: []v1alpha1.Patch{{
+			Kind: "Deployment",
+			LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      metadata.ComponentLabelKey,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{metadata.ComponentSpiceDBLabelValue, metadata.ComponentDispatchLabelValue},
+			}}},
+			Patch: labelPatch,
+		}},
+		want:        labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue).WithLabels(map[string]string{"added": "via-patch"}),
+		wantPatched: true,
+		wantCount:   1,
+	},
+	{
+		name:   "selectors match the object before earlier patches",
+		object: labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue),
+		out:    applyappsv1.Deployment("test-spicedb", "test"),
+		patches: []v1alpha1.Patch{
+			{Kind: "Deployment", Patch: labelPatch},
+			{
+				Kind:          "Deployment",
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"added": "via-patch"}},
+				Patch:         json.RawMessage(`{"metadata": {"labels": {"second": "patch"}}}`),
+			},
+		},
+		want:        labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue).WithLabels(map[string]string{"added": "via-patch"}),
+		wantPatched: true,
+		wantCount:   1,
+	},
+}
+
+func TestApplyPatchesInvalidSelector(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	out := applyappsv1.Deployment("test", "test")
+	count, _, err := ApplyPatches(applyappsv1.Deployment("test", "test"), out, []v1alpha1.Patch{{
+		Kind: "Deployment",
+		LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "tier",
+			Operator: "Maybe",
+		}}},
+		Patch: labelPatch,
+	}}, nil, resources)
+	require.ErrorContains(t, err, "error matching patch 0: invalid label selector")
+	require.Zero(t, count)
+}
+
 var patchBasicTests = []patchTestCase[*applyappsv1.DeploymentApplyConfiguration]{
 	{
 		name:   "does nothing if kind doesn't match",
@@ -139,6 +240,42 @@ metadata:
 }
 
 var patchFormatTests = []patchTestCase[*applyappsv1.DeploymentApplyConfiguration]{
+	{
+		name:   "add labels to unpatchedDeployment (JSON6902 list, json)",
+		object: applyappsv1.Deployment("test", "test"),
+		out:    applyappsv1.Deployment("test", "test"),
+		patches: []v1alpha1.Patch{{
+			Kind: "Deployment",
+			Patch: json.RawMessage(`[
+				{"op": "add", "path": "/metadata/labels", "value": {}},
+				{"op": "add", "path": "/metadata/labels/added", "value": "via-patch"},
+				{"op": "add", "path": "/metadata/labels/another", "value": "via-patch"}
+			]`),
+		}},
+		want: applyappsv1.Deployment("test", "test").
+			WithLabels(map[string]string{"added": "via-patch", "another": "via-patch"}),
+		wantPatched: true,
+		wantCount:   1,
+	},
+	{
+		name:   "add labels to unpatchedDeployment (JSON6902 list, yaml)",
+		object: applyappsv1.Deployment("test", "test"),
+		out:    applyappsv1.Deployment("test", "test"),
+		patches: []v1alpha1.Patch{{
+			Kind: "Deployment",
+			Patch: json.RawMessage(`
+- op: add
+  path: /metadata/labels
+  value: {}
+- op: add
+  path: /metadata/labels/added
+  value: via-patch`),
+		}},
+		want: applyappsv1.Deployment("test", "test").
+			WithLabels(map[string]string{"added": "via-patch"}),
+		wantPatched: true,
+		wantCount:   1,
+	},
 	{
 		name:   "add labels to unpatchedDeployment (smp, yaml)",
 		object: applyappsv1.Deployment("test", "test"),
@@ -884,3 +1021,76 @@ func baseDeployment() *applyappsv1.DeploymentApplyConfiguration {
 			),
 		)
 }
+
+func TestPatchSelectorWarnings(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	global := OperatorConfig{
+		ImageName: "image",
+		UpdateGraph: updates.UpdateGraph{
+			Channels: []updates.Channel{{
+				Name:     "memory",
+				Metadata: map[string]string{"datastore": "memory", "default": "true"},
+				Nodes:    []updates.State{{ID: "v1", Tag: "v1"}},
+				Edges:    map[string][]string{"v1": {}},
+			}},
+		},
+	}
+	patches := []v1alpha1.Patch{
+		{Kind: "Deployment", Name: "test-spicedb", Patch: labelPatch},
+		{Component: metadata.ComponentMigrationJobLabelValue, Patch: labelPatch},
+		{Kind: "Deployment", Name: "test-spicedb-dispatch", Patch: labelPatch},
+		{Component: metadata.ComponentDispatchServiceLabel, Patch: labelPatch},
+	}
+	cluster := &v1alpha1.SpiceDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: v1alpha1.ClusterSpec{
+			Config:  json.RawMessage(`{"datastoreEngine": "memory"}`),
+			Patches: patches,
+		},
+	}
+	_, warning, err := NewConfig(cluster, &global, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk")}}, resources, time.Now())
+	require.NoError(t, err)
+	require.ErrorContains(t, warning, "patch 2 didn't match any objects")
+	require.ErrorContains(t, warning, "patch 3 didn't match any objects")
+	require.NotContains(t, warning.Error(), "patch 0 didn't match")
+	require.NotContains(t, warning.Error(), "patch 1 didn't match")
+}
+
+func TestApplyPatchesJSON6902ListIsAtomic(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	out := applyappsv1.Deployment("test", "test")
+	count, patched, err := ApplyPatches(applyappsv1.Deployment("test", "test"), out, []v1alpha1.Patch{{
+		Kind: "Deployment",
+		Patch: json.RawMessage(`[
+			{"op": "add", "path": "/metadata/labels", "value": {"added": "via-patch"}},
+			{"op": "remove", "path": "/metadata/annotations/missing"}
+		]`),
+	}}, nil, resources)
+	require.ErrorContains(t, err, "error applying patch 0 to object")
+	require.Zero(t, count)
+	require.False(t, patched)
+	require.Empty(t, out.Labels)
+}
+
+func TestReportPatches(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	objects := []any{
+		labeledDeployment("test-spicedb", metadata.ComponentSpiceDBLabelValue),
+		applycorev1.ServiceAccount("test", "test").WithLabels(map[string]string{"added": "via-patch"}),
+		applycorev1.Service("test", "test"),
+	}
+	patches := []v1alpha1.Patch{
+		{Kind: "*", Patch: labelPatch},
+		{Kind: "ServiceAccount", Patch: labelPatch},
+		{Kind: "Secret", Patch: labelPatch},
+		{Kind: "Deployment", Patch: json.RawMessage(`{"op": "remove", "path": "/spec/replicas"}`)},
+	}
+	statuses, errs := reportPatches(objects, patches, nil, nil, resources)
+	require.Len(t, errs, 1)
+	require.Equal(t, []v1alpha1.PatchStatus{
+		{Index: 0, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment", "Service"}},
+		{Index: 1, Result: v1alpha1.PatchResultNoOp, Message: "matched objects but didn't change them"},
+		{Index: 2, Result: v1alpha1.PatchResultUnmatched, Message: "didn't match any objects"},
+		{Index: 3, Result: v1alpha1.PatchResultError, Message: errs[0].Error()},
+	}, statuses)
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	applybatchv1 "k8s.io/client-go/applyconfigurations/batch/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+)
+
+const (
+	// ProvisioningAdminURIKey is the key in the provisioning secret that
+	// holds a datastore URI with credentials allowed to create databases
+	// and users.
+	ProvisioningAdminURIKey = "datastore_uri"
+
+	// ProvisionedPasswordKey is the key in the cluster's secret that holds
+	// the password generated for the provisioned user.
+	// nolint:gosec // Not a credential.
+	ProvisionedPasswordKey = "datastore_password"
+)
+
+var provisionedIdentifierRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,31}$`)
+
+// defaultProvisioningImages hold the client tools used to provision each
+// datastore engine.
+var defaultProvisioningImages = map[string]string{
+	"postgres":    "postgres:16-alpine",
+	"cockroachdb": "cockroachdb/cockroach:latest-v23.2",
+	"mysql":       "mysql:8.0",
+}
+
+// provisioningScripts create the database and user for each engine. They are
+// written to be safe to re-run: existing databases are left alone and
+// existing users have their password reset. Database and user names are
+// validated identifiers, so they don't need quoting.
+//
+// The user is only granted what SpiceDB needs in its own database. On
+// postgres and cockroachdb that is connecting and creating tables, since the
+// user owns the tables its migrations create. mysql has no table owners, so
+// the user gets the privileges to read, write and migrate the tables.
+var provisioningScripts = map[string]string{
+	"postgres": `
+if psql "$ADMIN_URI" -tAc "SELECT 1 FROM pg_roles WHERE rolname = '$DATABASE_USER'" | grep -q 1; then
+  psql "$ADMIN_URI" -v ON_ERROR_STOP=1 -c "ALTER ROLE $DATABASE_USER WITH LOGIN PASSWORD '$DATABASE_PASSWORD'"
+else
+  psql "$ADMIN_URI" -v ON_ERROR_STOP=1 -c "CREATE ROLE $DATABASE_USER WITH LOGIN PASSWORD '$DATABASE_PASSWORD'"
+fi
+if ! psql "$ADMIN_URI" -tAc "SELECT 1 FROM pg_database WHERE datname = '$DATABASE_NAME'" | grep -q 1; then
+  psql "$ADMIN_URI" -v ON_ERROR_STOP=1 -c "CREATE DATABASE $DATABASE_NAME"
+fi
+psql "$ADMIN_URI" -v ON_ERROR_STOP=1 -c "GRANT CONNECT ON DATABASE $DATABASE_NAME TO $DATABASE_USER"
+psql "$ADMIN_URI" -v ON_ERROR_STOP=1 -c "\\connect $DATABASE_NAME" -c "GRANT USAGE, CREATE ON SCHEMA public TO $DATABASE_USER"
+`,
+	"cockroachdb": `
+cockroach sql --url "$ADMIN_URI" -e "
+CREATE DATABASE IF NOT EXISTS $DATABASE_NAME;
+CREATE USER IF NOT EXISTS $DATABASE_USER;
+ALTER USER $DATABASE_USER WITH PASSWORD '$DATABASE_PASSWORD';
+GRANT CONNECT, CREATE ON DATABASE $DATABASE_NAME TO $DATABASE_USER;
+GRANT USAGE, CREATE ON SCHEMA $DATABASE_NAME.public TO $DATABASE_USER;
+"
+`,
+	"mysql": `
+creds="${ADMIN_URI%@*}"
+addr="${ADMIN_URI##*@}"
+addr="${addr#*(}"
+addr="${addr%%)*}"
+export MYSQL_PWD="${creds#*:}"
+mysql -h "${addr%:*}" -P "${addr##*:}" -u "${creds%%:*}" -e "
+CREATE DATABASE IF NOT EXISTS $DATABASE_NAME;
+CREATE USER IF NOT EXISTS '$DATABASE_USER'@'%' IDENTIFIED BY '$DATABASE_PASSWORD';
+ALTER USER '$DATABASE_USER'@'%' IDENTIFIED BY '$DATABASE_PASSWORD';
+GRANT SELECT, INSERT, UPDATE, DELETE, CREATE, ALTER, INDEX, DROP ON $DATABASE_NAME.* TO '$DATABASE_USER'@'%';
+"
+`,
+}
+
+// DatastoreProvisioningConfig describes the database and user that the
+// operator creates for a cluster, using the admin credentials in
+// AdminSecretName.
+type DatastoreProvisioningConfig struct {
+	AdminSecretName string
+	Image           string
+	Database        string
+	User            string
+}
+
+// Enabled returns true if the operator should provision the datastore.
+func (p DatastoreProvisioningConfig) Enabled() bool {
+	return len(p.AdminSecretName) > 0
+}
+
+func newDatastoreProvisioningConfig(clusterName, engine string, config RawConfig) (DatastoreProvisioningConfig, error) {
+	p := DatastoreProvisioningConfig{
+		AdminSecretName: provisioningSecretNameKey.pop(config),
+		Image:           provisioningImageKey.pop(config),
+		Database:        provisioningDatabaseKey.pop(config),
+		User:            provisioningUserKey.pop(config),
+	}
+	if !p.Enabled() {
+		return DatastoreProvisioningConfig{}, nil
+	}
+
+	defaultImage, ok := defaultProvisioningImages[engine]
+	if !ok {
+		return DatastoreProvisioningConfig{}, fmt.Errorf("datastore provisioning is not supported for datastoreEngine %q", engine)
+	}
+	if len(p.Image) == 0 {
+		p.Image = defaultImage
+	}
+	if len(p.Database) == 0 {
+		p.Database = provisionedIdentifier(clusterName)
+	}
+	if len(p.User) == 0 {
+		p.User = p.Database
+	}
+	for _, k := range []struct {
+		key   *key[string]
+		value string
+	}{
+		{provisioningDatabaseKey, p.Database},
+		{provisioningUserKey, p.User},
+	} {
+		if !provisionedIdentifierRegex.MatchString(k.value) {
+			return DatastoreProvisioningConfig{}, fmt.Errorf("invalid value for %s %q: must be at most 32 lowercase letters, digits or underscores", k.key.key, k.value)
+		}
+	}
+	return p, nil
+}
+
+// provisionedIdentifier derives a database identifier from a cluster name.
+func provisionedIdentifier(name string) string {
+	id := "spicedb_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(name))
+	if len(id) > 32 {
+		id = id[:32]
+	}
+	return id
+}
+
+// ProvisionedDatastoreURI returns the datastore URI for the provisioned
+// database and user, reusing the address and options of the admin URI.
+func ProvisionedDatastoreURI(engine, adminURI string, p DatastoreProvisioningConfig, password string) (string, error) {
+	switch engine {
+	case "postgres", "cockroachdb":
+		u, err := url.Parse(adminURI)
+		if err != nil || len(u.Host) == 0 {
+			return "", fmt.Errorf("provisioning secret %q has an invalid %s", p.AdminSecretName, ProvisioningAdminURIKey)
+		}
+		u.User = url.UserPassword(p.User, password)
+		u.Path = "/" + p.Database
+		return u.String(), nil
+	case "mysql":
+		// user:password@protocol(address)/dbname?param=value
+		slash := strings.LastIndex(adminURI, "/")
+		if slash < 0 {
+			return "", fmt.Errorf("provisioning secret %q has an invalid %s", p.AdminSecretName, ProvisioningAdminURIKey)
+		}
+		at := strings.LastIndex(adminURI[:slash], "@")
+		if at < 0 {
+			return "", fmt.Errorf("provisioning secret %q has an invalid %s", p.AdminSecretName, ProvisioningAdminURIKey)
+		}
+		address := adminURI[at+1 : slash]
+		var params string
+		if _, query, ok := strings.Cut(adminURI[slash+1:], "?"); ok {
+			params = "?" + query
+		}
+		return fmt.Sprintf("%s:%s@%s/%s%s", p.User, password, address, p.Database, params), nil
+	default:
+		return "", fmt.Errorf("datastore provisioning is not supported for datastoreEngine %q", engine)
+	}
+}
+
+// ProvisioningJobName returns the name of the job that provisions the
+// cluster's datastore.
+func ProvisioningJobName(clusterName string) string {
+	return fmt.Sprintf("%s-provision-datastore", clusterName)
+}
+
+func (c *Config) unpatchedProvisioningJob() *applybatchv1.JobApplyConfiguration {
+	p := c.DatastoreProvisioning
+	envVars := []*applycorev1.EnvVarApplyConfiguration{
+		applycorev1.EnvVar().WithName("ADMIN_URI").WithValueFrom(applycorev1.EnvVarSource().WithSecretKeyRef(applycorev1.SecretKeySelector().WithName(p.AdminSecretName).WithKey(ProvisioningAdminURIKey))),
+		applycorev1.EnvVar().WithName("DATABASE_NAME").WithValue(p.Database),
+		applycorev1.EnvVar().WithName("DATABASE_USER").WithValue(p.User),
+		applycorev1.EnvVar().WithName("DATABASE_PASSWORD").WithValueFrom(applycorev1.EnvVarSource().WithSecretKeyRef(applycorev1.SecretKeySelector().WithName(c.SecretName).WithKey(ProvisionedPasswordKey))),
+	}
+
+	volumes := make([]*applycorev1.VolumeApplyConfiguration, 0)
+	volumeMounts := make([]*applycorev1.VolumeMountApplyConfiguration, 0)
+	if len(c.DatastoreTLSSecretName) > 0 {
+		volumes = append(volumes, applycorev1.Volume().WithName(dbTLSVolume).WithSecret(applycorev1.SecretVolumeSource().WithDefaultMode(420).WithSecretName(c.DatastoreTLSSecretName)))
+		volumeMounts = append(volumeMounts, applycorev1.VolumeMount().WithName(dbTLSVolume).WithMountPath("/spicedb-db-tls").WithReadOnly(true))
+	}
+
+	return applybatchv1.Job(ProvisioningJobName(c.Name), c.Namespace).
+		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentProvisioningJobLabel)).
+		WithSpec(applybatchv1.JobSpec().WithTemplate(
+			applycorev1.PodTemplateSpec().WithLabels(
+				metadata.LabelsForComponent(c.Name, metadata.ComponentProvisioningJobLabel),
+			).WithLabels(
+				c.ExtraPodLabels,
+			).WithAnnotations(
+				c.ExtraPodAnnotations,
+			).WithSpec(c.provisioningPodSpec().WithServiceAccountName(c.ServiceAccountName).WithImagePullSecrets(c.imagePullSecrets()...).
+				WithContainers(
+					applycorev1.Container().
+						WithName("provision").
+						WithImage(p.Image).
+						WithCommand("/bin/sh", "-ec", provisioningScripts[c.DatastoreEngine]).
+						WithEnv(envVars...).
+						WithVolumeMounts(volumeMounts...).
+						WithTerminationMessagePolicy(corev1.TerminationMessageFallbackToLogsOnError),
+				).WithVolumes(volumes...).WithRestartPolicy(corev1.RestartPolicyOnFailure))))
+}
+
+// provisioningPodSpec returns the pod spec that the provisioning job starts
+// from. The job runs stock database client images, which aren't built to
+// run as the spicedb nonroot user with a read-only root filesystem, so it's
+// exempt from the restricted security context. It never talks to the kube
+// api though, so it still doesn't get a service account token.
+func (c *Config) provisioningPodSpec() *applycorev1.PodSpecApplyConfiguration {
+	spec := c.Scheduling.applyPlacement(applycorev1.PodSpec())
+	if c.SkipPodSecurityDefaults {
+		return spec
+	}
+	return spec.WithAutomountServiceAccountToken(false)
+}
+
+func (c *Config) ProvisioningJob() *applybatchv1.JobApplyConfiguration {
+	j := applybatchv1.Job(ProvisioningJobName(c.Name), c.Namespace)
+	_, _, _ = ApplyPatches(c.unpatchedProvisioningJob(), j, c.Patches, c.patchTemplateData(), c.Resources)
+
+	// ensure patches don't overwrite anything critical for operator function
+	j.WithName(ProvisioningJobName(c.Name)).WithNamespace(c.Namespace).WithOwnerReferences(c.ownerRef()).
+		WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentProvisioningJobLabel))
+	j.Spec.Template.WithLabels(metadata.LabelsForComponent(c.Name, metadata.ComponentProvisioningJobLabel))
+	return j
+}
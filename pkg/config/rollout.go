@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+)
+
+// RolloutStrategy is how new versions of spicedb replace running pods
+type RolloutStrategy string
+
+const (
+	// RolloutStrategyRolling replaces pods a few at a time, so old and new
+	// pods serve traffic side by side during the rollout. This is the
+	// default.
+	RolloutStrategyRolling RolloutStrategy = "rolling"
+
+	// RolloutStrategyBlueGreen stands up a complete Deployment for the new
+	// version next to the old one, switches the Service over once all of it
+	// is ready, and removes the old Deployment after it has drained.
+	RolloutStrategyBlueGreen RolloutStrategy = "blueGreen"
+)
+
+// defaultBlueGreenDrainDuration is how long the old color keeps running
+// after the Service has switched away from it, if not configured.
+const defaultBlueGreenDrainDuration = 30 * time.Second
+
+// RolloutConfig holds settings for how spicedb Deployments are rolled out.
+// The zero value is a rolling rollout.
+type RolloutConfig struct {
+	Strategy RolloutStrategy
+
+	// DrainDuration is how long the old color is kept after the Service
+	// switches to the new one.
+	DrainDuration time.Duration
+
+	// Color identifies the Deployment running the target image.
+	Color string
+
+	// ActiveDeployment is the name of the Deployment that the Service
+	// selects, once one has been recorded.
+	ActiveDeployment string
+}
+
+// BlueGreen returns true if the cluster uses blue/green rollouts
+func (r RolloutConfig) BlueGreen() bool {
+	return r.Strategy == RolloutStrategyBlueGreen
+}
+
+func newRolloutConfig(config RawConfig, status v1alpha1.ClusterStatus, targetImage string, dispatchTier DispatchTierConfig) (RolloutConfig, error) {
+	var r RolloutConfig
+	strategy := RolloutStrategy(rolloutStrategyKey.pop(config))
+	_, drainSet := config[string(blueGreenDrainDurationKey)]
+	drain, err := blueGreenDrainDurationKey.pop(config)
+	if err != nil {
+		return RolloutConfig{}, err
+	}
+
+	switch strategy {
+	case "", RolloutStrategyRolling:
+		return r, nil
+	case RolloutStrategyBlueGreen:
+	default:
+		return RolloutConfig{}, fmt.Errorf("invalid value for %s %q: must be %q or %q", rolloutStrategyKey.key, strategy, RolloutStrategyRolling, RolloutStrategyBlueGreen)
+	}
+	if dispatchTier.Enabled() {
+		return RolloutConfig{}, fmt.Errorf("%s %q can't be used with %s", rolloutStrategyKey.key, strategy, dispatchTierReplicasKey.key)
+	}
+	if drain < 0 {
+		return RolloutConfig{}, fmt.Errorf("invalid value for %s %s: must not be negative", blueGreenDrainDurationKey, drain)
+	}
+	if !drainSet {
+		drain = defaultBlueGreenDrainDuration
+	}
+
+	r.Strategy = strategy
+	r.DrainDuration = drain
+	r.Color = shortHash(targetImage)
+	if status.BlueGreen != nil {
+		r.ActiveDeployment = status.BlueGreen.ActiveDeployment
+	}
+	return r, nil
+}
+
+// podSelector returns the labels that the Service selects spicedb pods by.
+// Blue/green clusters only select the pods of the active Deployment, which
+// may be a rolling Deployment from before the switch to blue/green.
+func (c *Config) podSelector() map[string]string {
+	selector := metadata.LabelsForComponent(c.Name, metadata.ComponentSpiceDBLabelValue)
+	if c.Rollout.BlueGreen() && len(c.Rollout.ActiveDeployment) > 0 {
+		selector["app.kubernetes.io/instance"] = c.Rollout.ActiveDeployment
+	}
+	return selector
+}
+
+// colorLabels returns the labels that mark pods with their color, if the
+// cluster uses blue/green rollouts.
+func (c *Config) colorLabels() map[string]string {
+	if !c.Rollout.BlueGreen() {
+		return nil
+	}
+	return map[string]string{metadata.ColorLabelKey: c.Rollout.Color}
+}
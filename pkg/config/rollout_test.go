@@ -0,0 +1,143 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	openapitesting "k8s.io/kubectl/pkg/util/openapi/testing"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+)
+
+func TestNewRolloutConfig(t *testing.T) {
+	color := shortHash("image:v2")
+	tests := []struct {
+		name         string
+		config       RawConfig
+		status       v1alpha1.ClusterStatus
+		dispatchTier DispatchTierConfig
+		want         RolloutConfig
+		wantErr      string
+	}{
+		{
+			name:   "defaults to rolling",
+			config: RawConfig{},
+			want:   RolloutConfig{},
+		},
+		{
+			name:   "rolling ignores the drain duration",
+			config: RawConfig{"rolloutStrategy": "rolling", "blueGreenDrainDuration": "1m"},
+			want:   RolloutConfig{},
+		},
+		{
+			name:   "blue/green with the default drain duration",
+			config: RawConfig{"rolloutStrategy": "blueGreen"},
+			want:   RolloutConfig{Strategy: RolloutStrategyBlueGreen, DrainDuration: defaultBlueGreenDrainDuration, Color: color},
+		},
+		{
+			name:   "blue/green with a drain duration",
+			config: RawConfig{"rolloutStrategy": "blueGreen", "blueGreenDrainDuration": "2m"},
+			status: v1alpha1.ClusterStatus{BlueGreen: &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-abc123"}},
+			want:   RolloutConfig{Strategy: RolloutStrategyBlueGreen, DrainDuration: 2 * time.Minute, Color: color, ActiveDeployment: "test-spicedb-abc123"},
+		},
+		{
+			name:   "blue/green without draining",
+			config: RawConfig{"rolloutStrategy": "blueGreen", "blueGreenDrainDuration": "0s"},
+			want:   RolloutConfig{Strategy: RolloutStrategyBlueGreen, Color: color},
+		},
+		{
+			name:    "unknown strategy",
+			config:  RawConfig{"rolloutStrategy": "canary"},
+			wantErr: `invalid value for rolloutStrategy "canary": must be "rolling" or "blueGreen"`,
+		},
+		{
+			name:    "negative drain duration",
+			config:  RawConfig{"rolloutStrategy": "blueGreen", "blueGreenDrainDuration": "-1s"},
+			wantErr: "invalid value for blueGreenDrainDuration -1s: must not be negative",
+		},
+		{
+			name:         "blue/green with a dispatch tier",
+			config:       RawConfig{"rolloutStrategy": "blueGreen"},
+			dispatchTier: DispatchTierConfig{Replicas: 2},
+			wantErr:      `rolloutStrategy "blueGreen" can't be used with dispatchTierReplicas`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRolloutConfig(tt.config, tt.status, "image:v2", tt.dispatchTier)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.Empty(t, tt.config)
+		})
+	}
+}
+
+func TestBlueGreenObjects(t *testing.T) {
+	c := &Config{
+		MigrationConfig: MigrationConfig{TargetSpiceDBImage: "image:v2"},
+		SpiceConfig: SpiceConfig{
+			Name:              "test",
+			Namespace:         "test",
+			Replicas:          2,
+			EnvPrefix:         "SPICEDB",
+			DispatchEnabled:   true,
+			DispatchIsolation: "new123",
+			Rollout: RolloutConfig{
+				Strategy:         RolloutStrategyBlueGreen,
+				Color:            "new123",
+				ActiveDeployment: "test-spicedb-old456",
+			},
+		},
+	}
+
+	d := c.Deployment("migration", "secret")
+	require.Equal(t, "test-spicedb-new123", *d.Name)
+	require.Equal(t, "new123", d.Spec.Template.Labels[metadata.ColorLabelKey])
+	require.Equal(t, map[string]string{"app.kubernetes.io/instance": "test-spicedb-new123"}, d.Spec.Selector.MatchLabels)
+
+	// the service keeps selecting the active deployment until the cutover
+	s := c.Service()
+	expected := metadata.LabelsForComponent("test", metadata.ComponentSpiceDBLabelValue)
+	expected["app.kubernetes.io/instance"] = "test-spicedb-old456"
+	require.Equal(t, expected, s.Spec.Selector)
+
+	// switching from rolling to blue/green with a new image keeps the
+	// service on the rolling deployment, whose pods have no color
+	c.Rollout.ActiveDeployment = "test-spicedb"
+	expected["app.kubernetes.io/instance"] = "test-spicedb"
+	require.Equal(t, expected, c.Service().Spec.Selector)
+
+	// rolling clusters don't have colors
+	c.Rollout = RolloutConfig{}
+	c.DispatchIsolation = ""
+	d = c.Deployment("migration", "secret")
+	require.Equal(t, "test-spicedb", *d.Name)
+	require.NotContains(t, d.Spec.Template.Labels, metadata.ColorLabelKey)
+	require.Equal(t, metadata.LabelsForComponent("test", metadata.ComponentSpiceDBLabelValue), c.Service().Spec.Selector)
+}
+
+func TestNewConfigBlueGreen(t *testing.T) {
+	cluster := &v1alpha1.SpiceDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: v1alpha1.ClusterSpec{Config: []byte(`{
+			"datastoreEngine": "cockroachdb",
+			"image": "image:v2",
+			"rolloutStrategy": "blueGreen"
+		}`)},
+	}
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	c, _, err := NewConfig(cluster, &OperatorConfig{ImageName: "image"}, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk"), "datastore_uri": []byte("uri")}}, resources, time.Now())
+	require.NoError(t, err)
+	require.True(t, c.Rollout.BlueGreen())
+	require.Equal(t, shortHash("image:v2"), c.Rollout.Color)
+	require.Equal(t, c.Rollout.Color, c.DispatchIsolation)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	applymetav1 "k8s.io/client-go/applyconfigurations/meta/v1"
+)
+
+// SchedulingConfig holds the resources and placement of spicedb pods. It
+// applies to the Deployments and the migration job alike, which patches
+// against a single kind can't do.
+type SchedulingConfig struct {
+	Resources    corev1.ResourceRequirements
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+	Affinity     *corev1.Affinity
+
+	// TopologySpreadConstraints replace the default zone and node spread
+	// for Deployments with more than one replica. An explicitly empty list
+	// disables the defaults.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint
+}
+
+func newSchedulingConfig(config RawConfig) (SchedulingConfig, []error) {
+	var s SchedulingConfig
+	errs := make([]error, 0)
+	var err error
+	if s.Resources, err = resourcesKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	if s.NodeSelector, err = nodeSelectorKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Tolerations, err = tolerationsKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Affinity, err = affinityKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	if s.TopologySpreadConstraints, err = topologySpreadConstraintsKey.pop(config); err != nil {
+		errs = append(errs, err)
+	}
+	return s, errs
+}
+
+// applyPlacement sets the node selector, tolerations and affinity on a pod
+// spec.
+func (s SchedulingConfig) applyPlacement(spec *applycorev1.PodSpecApplyConfiguration) *applycorev1.PodSpecApplyConfiguration {
+	if len(s.NodeSelector) > 0 {
+		spec.WithNodeSelector(s.NodeSelector)
+	}
+	for _, t := range s.Tolerations {
+		spec.WithTolerations(toApplyConfiguration[applycorev1.TolerationApplyConfiguration](t))
+	}
+	if s.Affinity != nil {
+		spec.WithAffinity(toApplyConfiguration[applycorev1.AffinityApplyConfiguration](s.Affinity))
+	}
+	return spec
+}
+
+// topologySpreadConstraints returns the spread constraints for the pods of
+// a Deployment. Unless configured otherwise, Deployments with more than one
+// replica are spread across zones and nodes on a best-effort basis.
+func (s SchedulingConfig) topologySpreadConstraints(instance string, replicas int32) []*applycorev1.TopologySpreadConstraintApplyConfiguration {
+	if s.TopologySpreadConstraints != nil {
+		constraints := make([]*applycorev1.TopologySpreadConstraintApplyConfiguration, 0, len(s.TopologySpreadConstraints))
+		for _, c := range s.TopologySpreadConstraints {
+			constraints = append(constraints, toApplyConfiguration[applycorev1.TopologySpreadConstraintApplyConfiguration](c))
+		}
+		return constraints
+	}
+	if replicas <= 1 {
+		return nil
+	}
+	constraints := make([]*applycorev1.TopologySpreadConstraintApplyConfiguration, 0, 2)
+	for _, topologyKey := range []string{"topology.kubernetes.io/zone", "kubernetes.io/hostname"} {
+		constraints = append(constraints, applycorev1.TopologySpreadConstraint().
+			WithMaxSkew(1).
+			WithTopologyKey(topologyKey).
+			WithWhenUnsatisfiable(corev1.ScheduleAnyway).
+			WithLabelSelector(applymetav1.LabelSelector().WithMatchLabels(map[string]string{"app.kubernetes.io/instance": instance})))
+	}
+	return constraints
+}
+
+// resourcesApplyConfiguration returns nil for empty requirements, so that
+// containers without configured resources are left untouched.
+func resourcesApplyConfiguration(r corev1.ResourceRequirements) *applycorev1.ResourceRequirementsApplyConfiguration {
+	if len(r.Limits) == 0 && len(r.Requests) == 0 {
+		return nil
+	}
+	return applycorev1.ResourceRequirements().WithLimits(r.Limits).WithRequests(r.Requests)
+}
+
+// toApplyConfiguration converts an api type into its apply configuration,
+// which has the same json representation.
+func toApplyConfiguration[A any](in any) *A {
+	out := new(A)
+	encoded, err := json.Marshal(in)
+	if err != nil {
+		return out
+	}
+	_ = json.Unmarshal(encoded, out)
+	return out
+}
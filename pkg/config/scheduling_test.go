@@ -0,0 +1,139 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+func TestNewSchedulingConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   RawConfig
+		want     SchedulingConfig
+		wantErrs int
+	}{
+		{
+			name:   "empty",
+			config: RawConfig{},
+		},
+		{
+			name: "all keys",
+			config: RawConfig{
+				"resources":    map[string]any{"requests": map[string]any{"memory": "1Gi"}},
+				"nodeSelector": map[string]any{"pool": "spicedb"},
+				"tolerations":  []any{map[string]any{"key": "dedicated", "operator": "Exists", "effect": "NoSchedule"}},
+				"affinity": map[string]any{"nodeAffinity": map[string]any{"requiredDuringSchedulingIgnoredDuringExecution": map[string]any{
+					"nodeSelectorTerms": []any{map[string]any{"matchExpressions": []any{map[string]any{"key": "arch", "operator": "In", "values": []any{"arm64"}}}}},
+				}}},
+				"topologySpreadConstraints": []any{},
+			},
+			want: SchedulingConfig{
+				Resources:    corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}},
+				NodeSelector: map[string]string{"pool": "spicedb"},
+				Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+				Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"arm64"}}}}},
+				}}},
+				TopologySpreadConstraints: []corev1.TopologySpreadConstraint{},
+			},
+		},
+		{
+			name: "invalid values",
+			config: RawConfig{
+				"nodeSelector": "pool=spicedb",
+				"tolerations":  map[string]any{"key": "dedicated"},
+			},
+			wantErrs: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := newSchedulingConfig(tt.config)
+			require.Len(t, errs, tt.wantErrs)
+			require.Empty(t, tt.config)
+			if tt.wantErrs == 0 {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSchedulingAppliesToDeploymentAndMigrationJob(t *testing.T) {
+	scheduling := SchedulingConfig{
+		Resources:    corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+		NodeSelector: map[string]string{"pool": "spicedb"},
+		Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{TopologyKey: "kubernetes.io/hostname"}},
+		}},
+	}
+
+	tests := []struct {
+		name           string
+		replicas       int32
+		spread         []corev1.TopologySpreadConstraint
+		wantSpreadKeys []string
+	}{
+		{
+			name:     "single replica isn't spread",
+			replicas: 1,
+		},
+		{
+			name:           "multiple replicas are spread across zones and nodes",
+			replicas:       3,
+			wantSpreadKeys: []string{"topology.kubernetes.io/zone", "kubernetes.io/hostname"},
+		},
+		{
+			name:           "configured constraints replace the defaults",
+			replicas:       3,
+			spread:         []corev1.TopologySpreadConstraint{{MaxSkew: 2, TopologyKey: "rack", WhenUnsatisfiable: corev1.DoNotSchedule}},
+			wantSpreadKeys: []string{"rack"},
+		},
+		{
+			name:     "empty constraints disable the defaults",
+			replicas: 3,
+			spread:   []corev1.TopologySpreadConstraint{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := scheduling
+			s.TopologySpreadConstraints = tt.spread
+			c := &Config{MigrationConfig: MigrationConfig{DatastoreEngine: "postgres"}, SpiceConfig: SpiceConfig{
+				Name:                  "test",
+				Namespace:             "test",
+				Replicas:              tt.replicas,
+				Scheduling:            s,
+				DatastoreProvisioning: DatastoreProvisioningConfig{AdminSecretName: "admin", Image: "postgres:16-alpine"},
+			}}
+
+			deploymentSpec := c.Deployment("migration", "secret").Spec.Template.Spec
+			jobSpec := c.MigrationJob("migration").Spec.Template.Spec
+			provisioningSpec := c.ProvisioningJob().Spec.Template.Spec
+			for _, spec := range []*applycorev1.PodSpecApplyConfiguration{deploymentSpec, jobSpec, provisioningSpec} {
+				require.Equal(t, map[string]string{"pool": "spicedb"}, spec.NodeSelector)
+				require.Len(t, spec.Tolerations, 1)
+				require.Equal(t, "dedicated", *spec.Tolerations[0].Key)
+				require.Equal(t, "kubernetes.io/hostname", *spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey)
+			}
+			require.Equal(t, s.Resources.Limits, *deploymentSpec.Containers[0].Resources.Limits)
+			require.Equal(t, s.Resources.Limits, *jobSpec.Containers[0].Resources.Limits)
+			require.Empty(t, jobSpec.TopologySpreadConstraints)
+			require.Empty(t, provisioningSpec.TopologySpreadConstraints)
+
+			spreadKeys := make([]string, 0)
+			for _, c := range deploymentSpec.TopologySpreadConstraints {
+				spreadKeys = append(spreadKeys, *c.TopologyKey)
+			}
+			if tt.wantSpreadKeys == nil {
+				require.Empty(t, spreadKeys)
+			} else {
+				require.Equal(t, tt.wantSpreadKeys, spreadKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// nonRootUID is the uid of the nonroot user in the spicedb images. It's set
+// explicitly so that runAsNonRoot can be verified by the kubelet even for
+// images that name the user instead of giving its uid.
+const nonRootUID = 65532
+
+// podSecurityContext returns the pod-level settings required by the
+// restricted pod security standard, or nil if the defaults are disabled.
+func (c *Config) podSecurityContext() *applycorev1.PodSecurityContextApplyConfiguration {
+	if c.SkipPodSecurityDefaults {
+		return nil
+	}
+	return applycorev1.PodSecurityContext().
+		WithRunAsNonRoot(true).
+		WithRunAsUser(nonRootUID).
+		WithRunAsGroup(nonRootUID).
+		WithSeccompProfile(applycorev1.SeccompProfile().WithType(corev1.SeccompProfileTypeRuntimeDefault))
+}
+
+// containerSecurityContext returns the container-level settings required by
+// the restricted pod security standard, or nil if the defaults are disabled.
+func (c *Config) containerSecurityContext() *applycorev1.SecurityContextApplyConfiguration {
+	if c.SkipPodSecurityDefaults {
+		return nil
+	}
+	return applycorev1.SecurityContext().
+		WithRunAsNonRoot(true).
+		WithAllowPrivilegeEscalation(false).
+		WithReadOnlyRootFilesystem(true).
+		WithCapabilities(applycorev1.Capabilities().WithDrop("ALL"))
+}
+
+// applyPodSecurity sets the restricted pod security context on a pod spec.
+// Only the kubernetes dispatch resolver talks to the kube api, so pods only
+// get a service account token mounted if they need one to dispatch.
+func (c *Config) applyPodSecurity(spec *applycorev1.PodSpecApplyConfiguration, needsToken bool) *applycorev1.PodSpecApplyConfiguration {
+	if c.SkipPodSecurityDefaults {
+		return spec
+	}
+	return spec.WithSecurityContext(c.podSecurityContext()).
+		WithAutomountServiceAccountToken(needsToken)
+}
+
+// weakenedPodSecurity lists the ways a (patched) pod spec falls short of the
+// restricted pod security defaults.
+func weakenedPodSecurity(spec *applycorev1.PodSpecApplyConfiguration) []string {
+	if spec == nil {
+		return nil
+	}
+	weakened := make([]string, 0)
+	podRunAsNonRoot := false
+	podSeccomp := false
+	if sc := spec.SecurityContext; sc != nil {
+		podRunAsNonRoot = sc.RunAsNonRoot != nil && *sc.RunAsNonRoot
+		podSeccomp = sc.SeccompProfile != nil && sc.SeccompProfile.Type != nil && *sc.SeccompProfile.Type != corev1.SeccompProfileTypeUnconfined
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			weakened = append(weakened, "pod runs as root")
+		}
+	}
+	if spec.HostNetwork != nil && *spec.HostNetwork {
+		weakened = append(weakened, "pod uses the host network")
+	}
+	for _, container := range spec.Containers {
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = applycorev1.SecurityContext()
+		}
+		name := "container"
+		if container.Name != nil {
+			name = fmt.Sprintf("container %q", *container.Name)
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			weakened = append(weakened, name+" is privileged")
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			weakened = append(weakened, name+" allows privilege escalation")
+		}
+		if sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+			weakened = append(weakened, name+" has a writable root filesystem")
+		}
+		if (sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot) || (sc.RunAsNonRoot == nil && !podRunAsNonRoot) {
+			weakened = append(weakened, name+" may run as root")
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			weakened = append(weakened, name+" runs as root")
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type != nil {
+			if *sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+				weakened = append(weakened, name+" has no seccomp profile")
+			}
+		} else if !podSeccomp {
+			weakened = append(weakened, name+" has no seccomp profile")
+		}
+		if sc.Capabilities == nil || !slices.Contains(sc.Capabilities.Drop, "ALL") {
+			weakened = append(weakened, name+" doesn't drop all capabilities")
+		} else if added := slices.DeleteFunc(slices.Clone(sc.Capabilities.Add), func(c corev1.Capability) bool {
+			return c == "NET_BIND_SERVICE"
+		}); len(added) > 0 {
+			weakened = append(weakened, name+" adds capabilities")
+		}
+	}
+	return weakened
+}
+
+// podSecurityWarnings reports when patches undo the restricted pod security
+// defaults of the Deployments or the migration job. The provisioning job is
+// exempt from the defaults, so it isn't checked.
+func (c *Config) podSecurityWarnings(migrationHash, secretHash string) []error {
+	if c.SkipPodSecurityDefaults {
+		return nil
+	}
+	type podSpec struct {
+		kind string
+		spec *applycorev1.PodSpecApplyConfiguration
+	}
+	specs := []podSpec{
+		{"deployment", c.Deployment(migrationHash, secretHash).Spec.Template.Spec},
+		{"migration job", c.MigrationJob(migrationHash).Spec.Template.Spec},
+	}
+	if c.DispatchTier.Enabled() {
+		specs = append(specs, podSpec{"dispatch deployment", c.DispatchDeployment(migrationHash, secretHash).Spec.Template.Spec})
+	}
+	warnings := make([]error, 0)
+	for _, s := range specs {
+		if weakened := weakenedPodSecurity(s.spec); len(weakened) > 0 {
+			warnings = append(warnings, fmt.Errorf("patches weaken the restricted pod security defaults of the %s: %s", s.kind, strings.Join(weakened, ", ")))
+		}
+	}
+	return warnings
+}
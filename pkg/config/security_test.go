@@ -0,0 +1,174 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
+	openapitesting "k8s.io/kubectl/pkg/util/openapi/testing"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+func TestPodSecurityDefaults(t *testing.T) {
+	tests := []struct {
+		name             string
+		dispatchEnabled  bool
+		skip             bool
+		wantDeployToken  *bool
+		wantHardenedPods bool
+	}{
+		{
+			name:             "dispatch mounts the service account token",
+			dispatchEnabled:  true,
+			wantDeployToken:  ptr(true),
+			wantHardenedPods: true,
+		},
+		{
+			name:             "no token without dispatch",
+			wantDeployToken:  ptr(false),
+			wantHardenedPods: true,
+		},
+		{
+			name:            "defaults can be skipped",
+			dispatchEnabled: true,
+			skip:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{MigrationConfig: MigrationConfig{DatastoreEngine: "postgres"}, SpiceConfig: SpiceConfig{
+				Name:                    "test",
+				Namespace:               "test",
+				DispatchEnabled:         tt.dispatchEnabled,
+				SkipPodSecurityDefaults: tt.skip,
+				DatastoreProvisioning:   DatastoreProvisioningConfig{AdminSecretName: "admin", Image: "postgres:16-alpine"},
+			}}
+			deploymentSpec := c.Deployment("migration", "secret").Spec.Template.Spec
+			jobSpec := c.MigrationJob("migration").Spec.Template.Spec
+			provisioningSpec := c.ProvisioningJob().Spec.Template.Spec
+
+			require.Equal(t, tt.wantDeployToken, deploymentSpec.AutomountServiceAccountToken)
+			if tt.wantHardenedPods {
+				require.Equal(t, ptr(false), jobSpec.AutomountServiceAccountToken)
+				require.Equal(t, ptr(false), provisioningSpec.AutomountServiceAccountToken)
+				require.Empty(t, weakenedPodSecurity(deploymentSpec))
+				require.Empty(t, weakenedPodSecurity(jobSpec))
+				require.Nil(t, provisioningSpec.SecurityContext)
+				require.Nil(t, provisioningSpec.Containers[0].SecurityContext)
+			} else {
+				require.Nil(t, jobSpec.AutomountServiceAccountToken)
+				require.Nil(t, provisioningSpec.AutomountServiceAccountToken)
+				require.Nil(t, deploymentSpec.SecurityContext)
+				require.Nil(t, deploymentSpec.Containers[0].SecurityContext)
+				require.Nil(t, jobSpec.SecurityContext)
+				require.Nil(t, jobSpec.Containers[0].SecurityContext)
+				require.Nil(t, provisioningSpec.SecurityContext)
+				require.Nil(t, provisioningSpec.Containers[0].SecurityContext)
+			}
+		})
+	}
+}
+
+func TestPodSecurityWarnings(t *testing.T) {
+	resources := openapitesting.NewFakeResources(filepath.Join("testdata", "swagger.1.30.2.json"))
+	global := OperatorConfig{
+		ImageName: "image",
+		UpdateGraph: updates.UpdateGraph{
+			Channels: []updates.Channel{{
+				Name:     "memory",
+				Metadata: map[string]string{"datastore": "memory", "default": "true"},
+				Nodes:    []updates.State{{ID: "v1", Tag: "v1"}},
+				Edges:    map[string][]string{"v1": {}},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		config       string
+		patches      []v1alpha1.Patch
+		wantWarnings []string
+	}{
+		{
+			name:   "unpatched",
+			config: `{"datastoreEngine": "memory"}`,
+		},
+		{
+			name:   "unrelated patch",
+			config: `{"datastoreEngine": "memory"}`,
+			patches: []v1alpha1.Patch{{
+				Kind:  "Deployment",
+				Patch: json.RawMessage(`{"metadata": {"labels": {"team": "authz"}}}`),
+			}},
+		},
+		{
+			name:   "patch weakens the deployment",
+			config: `{"datastoreEngine": "memory"}`,
+			patches: []v1alpha1.Patch{{
+				Kind: "Deployment",
+				Patch: json.RawMessage(`{"spec": {"template": {"spec": {"containers": [
+					{"name": "spicedb", "securityContext": {"readOnlyRootFilesystem": false, "capabilities": {"add": ["SYS_ADMIN"]}}}
+				]}}}}`),
+			}},
+			wantWarnings: []string{
+				`patches weaken the restricted pod security defaults of the deployment: container "spicedb" has a writable root filesystem, container "spicedb" adds capabilities`,
+			},
+		},
+		{
+			name:   "wildcard patch weakens deployment and job",
+			config: `{"datastoreEngine": "memory"}`,
+			patches: []v1alpha1.Patch{{
+				Kind:  "*",
+				Patch: json.RawMessage(`{"spec": {"template": {"spec": {"securityContext": {"runAsUser": 0}}}}}`),
+			}},
+			wantWarnings: []string{
+				"patches weaken the restricted pod security defaults of the deployment: pod runs as root",
+				"patches weaken the restricted pod security defaults of the migration job: pod runs as root",
+			},
+		},
+		{
+			name:   "no warnings when the defaults are skipped",
+			config: `{"datastoreEngine": "memory", "skipPodSecurityDefaults": true}`,
+			patches: []v1alpha1.Patch{{
+				Kind:  "*",
+				Patch: json.RawMessage(`{"spec": {"template": {"spec": {"securityContext": {"runAsUser": 0}}}}}`),
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := global.Copy()
+			cluster := &v1alpha1.SpiceDBCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+				Spec: v1alpha1.ClusterSpec{
+					Config:  json.RawMessage(tt.config),
+					Patches: tt.patches,
+				},
+			}
+			_, warning, err := NewConfig(cluster, &g, &corev1.Secret{Data: map[string][]byte{"preshared_key": []byte("psk")}}, resources, time.Now())
+			require.NoError(t, err)
+
+			got := make([]string, 0)
+			if warning != nil {
+				for _, w := range warning.(errors.Aggregate).Errors() {
+					if strings.Contains(w.Error(), "restricted pod security") {
+						got = append(got, w.Error())
+					}
+				}
+			}
+			require.ElementsMatch(t, tt.wantWarnings, got)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
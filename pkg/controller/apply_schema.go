@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/hash"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/spicedb"
+)
+
+const (
+	EventSchemaDestructiveChange = "DestructiveSchemaChange"
+	EventSchemaRelationsDeleted  = "SchemaRelationsDeleted"
+	EventSchemaWritten           = "SchemaWritten"
+)
+
+// schemaDriftCheckInterval is how often an applied schema is diffed against
+// the live schema again, so that changes written to SpiceDB outside of the
+// operator are reverted.
+const schemaDriftCheckInterval = 5 * time.Minute
+
+// schemaClient is the subset of the SpiceDB API used to reconcile a
+// SpiceDBSchema.
+type schemaClient interface {
+	DiffSchema(ctx context.Context, schema string) (*spicedb.SchemaDiff, error)
+	HasRelationships(ctx context.Context, relation string) (bool, error)
+	DeleteRelationships(ctx context.Context, relation string) error
+	WriteSchema(ctx context.Context, schema string) (string, error)
+	Close() error
+}
+
+// SchemaApplyHandler writes a SpiceDBSchema to the cluster it references.
+// Before writing, the schema is diffed against the live schema; relations
+// that are removed but still have relationships block the write unless the
+// SpiceDBSchema is annotated to allow destructive changes.
+//
+// Applied schemas are diffed against the live schema every
+// schemaDriftCheckInterval and written again if they've drifted. Schemas
+// that are waiting on their cluster aren't requeued: the controller
+// requeues them when the cluster changes.
+type SchemaApplyHandler struct {
+	recorder    record.EventRecorder
+	getCluster  func(ctx context.Context, nn types.NamespacedName) (*v1alpha1.SpiceDBCluster, error)
+	connect     func(ctx context.Context, cluster *v1alpha1.SpiceDBCluster) (schemaClient, error)
+	patchStatus func(ctx context.Context, patch *v1alpha1.SpiceDBSchema) error
+}
+
+func (s *SchemaApplyHandler) Handle(ctx context.Context) {
+	schema := CtxSpiceDBSchema.MustValue(ctx)
+	schemaHash := hash.SecureObject(schema.Spec.Schema)
+	applied := schema.Status.SchemaHash == schemaHash &&
+		schema.Status.ObservedGeneration == schema.Generation &&
+		schema.IsStatusConditionTrue(v1alpha1.ConditionTypeSchemaApplied)
+
+	cluster, err := s.getCluster(ctx, schema.ClusterNN())
+	if apierrors.IsNotFound(err) {
+		s.waitForCluster(ctx, schema, fmt.Sprintf("SpiceDBCluster %s not found", schema.ClusterNN()))
+		return
+	}
+	if err != nil {
+		QueueOps.RequeueErr(ctx, err)
+		return
+	}
+	if cluster.Spec.Schema != nil {
+		s.fail(ctx, schema, fmt.Errorf("SpiceDBCluster %s manages its own schema with spec.schema", schema.ClusterNN()), false)
+		return
+	}
+	if len(cluster.Status.Image) == 0 || cluster.RolloutInProgress() {
+		s.waitForCluster(ctx, schema, fmt.Sprintf("Waiting for SpiceDBCluster %s to finish rolling out", schema.ClusterNN()))
+		return
+	}
+
+	client, err := s.connect(ctx, cluster)
+	if err != nil {
+		s.fail(ctx, schema, err, true)
+		return
+	}
+	defer func() {
+		runtime.HandleError(client.Close())
+	}()
+
+	diff, err := client.DiffSchema(ctx, schema.Spec.Schema)
+	if status.Code(err) == codes.InvalidArgument {
+		// the schema won't compile until the spec changes
+		s.fail(ctx, schema, err, false)
+		return
+	}
+	if err != nil {
+		s.fail(ctx, schema, err, true)
+		return
+	}
+	if applied && diff.Changes == 0 {
+		QueueOps.RequeueAfter(ctx, schemaDriftCheckInterval)
+		return
+	}
+
+	withData := make([]string, 0)
+	for _, relation := range diff.RemovedRelations {
+		hasData, err := client.HasRelationships(ctx, relation)
+		if err != nil {
+			s.fail(ctx, schema, err, true)
+			return
+		}
+		if hasData {
+			withData = append(withData, relation)
+		}
+	}
+
+	if len(withData) > 0 {
+		if !schema.AllowsDestructiveChanges() {
+			schema.Status.ObservedGeneration = schema.Generation
+			schema.SetStatusCondition(v1alpha1.NewDestructiveSchemaChangeCondition(withData))
+			if err := s.patchStatus(ctx, schema); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return
+			}
+			s.recorder.Eventf(schema, corev1.EventTypeWarning, EventSchemaDestructiveChange, "Refusing to remove relations with existing relationships: %v", withData)
+			// the relationships may be removed by other means, so check back
+			QueueOps.RequeueAfter(ctx, time.Minute)
+			return
+		}
+
+		for _, relation := range withData {
+			if err := client.DeleteRelationships(ctx, relation); err != nil {
+				s.fail(ctx, schema, err, true)
+				return
+			}
+		}
+		s.recorder.Eventf(schema, corev1.EventTypeNormal, EventSchemaRelationsDeleted, "Deleted relationships for removed relations: %v", withData)
+	}
+
+	zedToken, err := client.WriteSchema(ctx, schema.Spec.Schema)
+	if err != nil {
+		s.fail(ctx, schema, err, true)
+		return
+	}
+
+	schema.Status.ObservedGeneration = schema.Generation
+	schema.Status.SchemaHash = schemaHash
+	schema.Status.ZedToken = zedToken
+	schema.SetStatusCondition(v1alpha1.NewSchemaWrittenCondition(zedToken))
+	if err := s.patchStatus(ctx, schema); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	s.recorder.Eventf(schema, corev1.EventTypeNormal, EventSchemaWritten, "Wrote schema to %s (%s)", schema.ClusterNN(), diff)
+
+	QueueOps.Done(ctx)
+}
+
+func (s *SchemaApplyHandler) waitForCluster(ctx context.Context, schema *v1alpha1.SpiceDBSchema, message string) {
+	schema.Status.ObservedGeneration = schema.Generation
+	schema.SetStatusCondition(v1alpha1.NewSchemaClusterNotReadyCondition(message))
+	if err := s.patchStatus(ctx, schema); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	QueueOps.Done(ctx)
+}
+
+func (s *SchemaApplyHandler) fail(ctx context.Context, schema *v1alpha1.SpiceDBSchema, err error, retry bool) {
+	runtime.HandleError(err)
+	schema.Status.ObservedGeneration = schema.Generation
+	schema.SetStatusCondition(v1alpha1.NewSchemaApplyFailedCondition(err))
+	if err := s.patchStatus(ctx, schema); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	if !retry {
+		QueueOps.Done(ctx)
+		return
+	}
+	QueueOps.RequeueAfter(ctx, 10*time.Second)
+}
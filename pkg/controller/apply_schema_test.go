@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/hash"
+	"github.com/authzed/controller-idioms/queue/fake"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/spicedb"
+)
+
+type fakeSchemaClient struct {
+	diff     *spicedb.SchemaDiff
+	diffErr  error
+	withData map[string]bool
+	deleted  []string
+	written  string
+	writeErr error
+	closed   bool
+	zedToken string
+}
+
+func (f *fakeSchemaClient) DiffSchema(_ context.Context, _ string) (*spicedb.SchemaDiff, error) {
+	return f.diff, f.diffErr
+}
+
+func (f *fakeSchemaClient) HasRelationships(_ context.Context, relation string) (bool, error) {
+	return f.withData[relation], nil
+}
+
+func (f *fakeSchemaClient) DeleteRelationships(_ context.Context, relation string) error {
+	f.deleted = append(f.deleted, relation)
+	return nil
+}
+
+func (f *fakeSchemaClient) WriteSchema(_ context.Context, schema string) (string, error) {
+	if f.writeErr != nil {
+		return "", f.writeErr
+	}
+	f.written = schema
+	return f.zedToken, nil
+}
+
+func (f *fakeSchemaClient) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestSchemaApplyHandler(t *testing.T) {
+	const desiredSchema = `
+definition user {}
+definition document {
+	relation viewer: user
+}`
+	readyCluster := &v1alpha1.SpiceDBCluster{
+		Status: v1alpha1.ClusterStatus{Image: "spicedb:v1"},
+	}
+
+	tests := []struct {
+		name string
+
+		annotations map[string]string
+		status      v1alpha1.SchemaStatus
+		cluster     *v1alpha1.SpiceDBCluster
+		clusterErr  error
+		diff        *spicedb.SchemaDiff
+		diffErr     error
+		withData    map[string]bool
+		writeErr    error
+
+		expectConnect      bool
+		expectWritten      string
+		expectDeleted      []string
+		expectZedToken     string
+		expectReason       string
+		expectStatus       metav1.ConditionStatus
+		expectDone         bool
+		expectRequeueAfter time.Duration
+		expectEvents       []string
+	}{
+		{
+			name: "applied schema is checked for drift again later",
+			status: v1alpha1.SchemaStatus{
+				SchemaHash: hash.SecureObject(desiredSchema),
+				Conditions: []metav1.Condition{v1alpha1.NewSchemaWrittenCondition("zt")},
+			},
+			cluster:            readyCluster,
+			diff:               &spicedb.SchemaDiff{},
+			expectConnect:      true,
+			expectReason:       "SchemaWritten",
+			expectStatus:       metav1.ConditionTrue,
+			expectRequeueAfter: schemaDriftCheckInterval,
+		},
+		{
+			name: "applied schema that has drifted is written again",
+			status: v1alpha1.SchemaStatus{
+				SchemaHash: hash.SecureObject(desiredSchema),
+				Conditions: []metav1.Condition{v1alpha1.NewSchemaWrittenCondition("zt")},
+			},
+			cluster:        readyCluster,
+			diff:           &spicedb.SchemaDiff{AddedRelations: []string{"document#viewer"}, Changes: 1},
+			expectConnect:  true,
+			expectWritten:  desiredSchema,
+			expectZedToken: "zt",
+			expectReason:   "SchemaWritten",
+			expectStatus:   metav1.ConditionTrue,
+			expectDone:     true,
+			expectEvents:   []string{"Normal SchemaWritten Wrote schema to test/test (added relations: document#viewer)"},
+		},
+		{
+			name:         "cluster not found",
+			clusterErr:   apierrors.NewNotFound(schema.GroupResource{}, "test"),
+			expectReason: v1alpha1.ConditionReasonClusterNotReady,
+			expectStatus: metav1.ConditionFalse,
+			expectDone:   true,
+		},
+		{
+			name: "cluster rolling out",
+			cluster: &v1alpha1.SpiceDBCluster{
+				Status: v1alpha1.ClusterStatus{
+					Image:      "spicedb:v1",
+					Conditions: []metav1.Condition{v1alpha1.NewMigratingCondition("memory", "head")},
+				},
+			},
+			expectReason: v1alpha1.ConditionReasonClusterNotReady,
+			expectStatus: metav1.ConditionFalse,
+			expectDone:   true,
+		},
+		{
+			name: "cluster manages its own schema",
+			cluster: &v1alpha1.SpiceDBCluster{
+				Spec:   v1alpha1.ClusterSpec{Schema: &v1alpha1.SchemaSource{Inline: "definition user {}"}},
+				Status: v1alpha1.ClusterStatus{Image: "spicedb:v1"},
+			},
+			expectReason: "SchemaWriteFailed",
+			expectStatus: metav1.ConditionFalse,
+			expectDone:   true,
+		},
+		{
+			name:          "invalid schema",
+			cluster:       readyCluster,
+			diffErr:       status.Error(codes.InvalidArgument, "parse error in `schema`"),
+			expectConnect: true,
+			expectReason:  "SchemaWriteFailed",
+			expectStatus:  metav1.ConditionFalse,
+			expectDone:    true,
+		},
+		{
+			name:               "diff fails",
+			cluster:            readyCluster,
+			diffErr:            status.Error(codes.Unavailable, "unavailable"),
+			expectConnect:      true,
+			expectReason:       "SchemaWriteFailed",
+			expectStatus:       metav1.ConditionFalse,
+			expectRequeueAfter: 10 * time.Second,
+		},
+		{
+			name:           "removed relation without data is written",
+			cluster:        readyCluster,
+			expectConnect:  true,
+			expectWritten:  desiredSchema,
+			expectZedToken: "zt",
+			expectReason:   "SchemaWritten",
+			expectStatus:   metav1.ConditionTrue,
+			expectDone:     true,
+			expectEvents:   []string{"Normal SchemaWritten Wrote schema to test/test (removed relations: document#editor)"},
+		},
+		{
+			name:               "removed relation with data is refused",
+			cluster:            readyCluster,
+			withData:           map[string]bool{"document#editor": true},
+			expectConnect:      true,
+			expectReason:       v1alpha1.ConditionReasonDestructiveChange,
+			expectStatus:       metav1.ConditionFalse,
+			expectRequeueAfter: time.Minute,
+			expectEvents:       []string{"Warning DestructiveSchemaChange Refusing to remove relations with existing relationships: [document#editor]"},
+		},
+		{
+			name:           "removed relation with data is deleted when allowed",
+			annotations:    map[string]string{v1alpha1.AllowDestructiveSchemaChangesAnnotation: "true"},
+			cluster:        readyCluster,
+			withData:       map[string]bool{"document#editor": true},
+			expectConnect:  true,
+			expectDeleted:  []string{"document#editor"},
+			expectWritten:  desiredSchema,
+			expectZedToken: "zt",
+			expectReason:   "SchemaWritten",
+			expectStatus:   metav1.ConditionTrue,
+			expectDone:     true,
+			expectEvents: []string{
+				"Normal SchemaRelationsDeleted Deleted relationships for removed relations: [document#editor]",
+				"Normal SchemaWritten Wrote schema to test/test (removed relations: document#editor)",
+			},
+		},
+		{
+			name:               "write fails",
+			cluster:            readyCluster,
+			writeErr:           errors.New("unavailable"),
+			expectConnect:      true,
+			expectReason:       "SchemaWriteFailed",
+			expectStatus:       metav1.ConditionFalse,
+			expectRequeueAfter: 10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrls := &fake.FakeInterface{}
+			spicedbSchema := &v1alpha1.SpiceDBSchema{
+				ObjectMeta: metav1.ObjectMeta{Name: "schema", Namespace: "test", Annotations: tt.annotations},
+				Spec:       v1alpha1.SchemaSpec{ClusterName: "test", Schema: desiredSchema},
+				Status:     tt.status,
+			}
+			ctx := QueueOps.WithValue(context.Background(), ctrls)
+			ctx = CtxSpiceDBSchema.WithValue(ctx, spicedbSchema)
+
+			diff := tt.diff
+			if diff == nil {
+				diff = &spicedb.SchemaDiff{RemovedRelations: []string{"document#editor"}, Changes: 1}
+			}
+			client := &fakeSchemaClient{
+				diff:     diff,
+				diffErr:  tt.diffErr,
+				withData: tt.withData,
+				writeErr: tt.writeErr,
+				zedToken: "zt",
+			}
+			recorder := record.NewFakeRecorder(2)
+			connected := false
+			h := &SchemaApplyHandler{
+				recorder: recorder,
+				getCluster: func(_ context.Context, nn types.NamespacedName) (*v1alpha1.SpiceDBCluster, error) {
+					require.Equal(t, types.NamespacedName{Name: "test", Namespace: "test"}, nn)
+					return tt.cluster, tt.clusterErr
+				},
+				connect: func(_ context.Context, _ *v1alpha1.SpiceDBCluster) (schemaClient, error) {
+					connected = true
+					return client, nil
+				},
+				patchStatus: func(_ context.Context, _ *v1alpha1.SpiceDBSchema) error {
+					return nil
+				},
+			}
+			h.Handle(ctx)
+
+			require.Equal(t, tt.expectConnect, connected)
+			require.Equal(t, tt.expectConnect, client.closed)
+			require.Equal(t, tt.expectWritten, client.written)
+			require.Equal(t, tt.expectDeleted, client.deleted)
+			require.Equal(t, tt.expectZedToken, spicedbSchema.Status.ZedToken)
+			ExpectEvents(t, recorder, tt.expectEvents)
+
+			condition := spicedbSchema.FindStatusCondition(v1alpha1.ConditionTypeSchemaApplied)
+			require.NotNil(t, condition)
+			require.Equal(t, tt.expectReason, condition.Reason)
+			require.Equal(t, tt.expectStatus, condition.Status)
+
+			if tt.expectDone {
+				require.Equal(t, 1, ctrls.DoneCallCount())
+			}
+			if tt.expectRequeueAfter != 0 {
+				require.Equal(t, 1, ctrls.RequeueAfterCallCount())
+				require.Equal(t, tt.expectRequeueAfter, ctrls.RequeueAfterArgsForCall(0))
+			} else {
+				require.Zero(t, ctrls.RequeueAfterCallCount())
+			}
+		})
+	}
+}
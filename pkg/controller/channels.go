@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/authzed/controller-idioms/typed"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+const (
+	EventInvalidChannel     = "InvalidChannel"
+	EventConflictingChannel = "ConflictingChannel"
+)
+
+var errChannelConflict = errors.New("channel is already defined")
+
+func (c *Controller) channelLister() *typed.Lister[*v1alpha1.SpiceDBChannel] {
+	return typed.ListerFor[*v1alpha1.SpiceDBChannel](c.Registry, typed.NewRegistryKey(ChannelFactoryKey, v1alpha1ChannelGVR))
+}
+
+// operatorConfig returns a copy of the operator config with the
+// SpiceDBChannels merged into its update graph.
+func (c *Controller) operatorConfig() config.OperatorConfig {
+	c.configLock.RLock()
+	cfg := c.config.Copy()
+	c.configLock.RUnlock()
+
+	channels, err := c.channelLister().List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return cfg
+	}
+	cfg.UpdateGraph, _ = mergeChannels(&cfg.UpdateGraph, channels)
+	return cfg
+}
+
+// syncChannel is called when a SpiceDBChannel is added, updated, or
+// deleted. Unlike a change to the config file, only the clusters that
+// subscribe to the channel (before or after the change) are requeued.
+func (c *Controller) syncChannel(oldObj, newObj any) {
+	var names []string
+	if channel, ok := channelFromObj(oldObj); ok {
+		names = append(names, channel.ChannelName())
+	}
+	if channel, ok := channelFromObj(newObj); ok {
+		names = append(names, channel.ChannelName())
+		c.reportChannel(channel)
+	}
+
+	clusters, err := typed.ListerFor[*v1alpha1.SpiceDBCluster](c.Registry, typed.NewRegistryKey(OwnedFactoryKey, v1alpha1ClusterGVR)).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, cluster := range subscribers(clusters, names) {
+		c.enqueue(v1alpha1ClusterGVR, cluster)
+	}
+}
+
+func channelFromObj(obj any) (*v1alpha1.SpiceDBChannel, bool) {
+	if obj == nil {
+		return nil, false
+	}
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object in channel informer: %T", obj))
+		return nil, false
+	}
+	channel, err := typed.UnstructuredObjToTypedObj[*v1alpha1.SpiceDBChannel](runtimeObj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return nil, false
+	}
+	return channel, true
+}
+
+// reportChannel records an event on a SpiceDBChannel that is left out of
+// the update graph.
+func (c *Controller) reportChannel(channel *v1alpha1.SpiceDBChannel) {
+	c.configLock.RLock()
+	graph := c.config.UpdateGraph.Copy()
+	c.configLock.RUnlock()
+
+	channels, err := c.channelLister().List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	_, rejected := mergeChannels(&graph, channels)
+	switch err := rejected[channel.Name]; {
+	case err == nil:
+	case errors.Is(err, errChannelConflict):
+		c.Recorder.Eventf(channel, corev1.EventTypeWarning, EventConflictingChannel, "Channel %s for %s is already defined and will be ignored", channel.ChannelName(), channel.Spec.Datastore)
+	default:
+		c.Recorder.Eventf(channel, corev1.EventTypeWarning, EventInvalidChannel, "Channel will be ignored: %v", err)
+	}
+}
+
+// mergeChannels adds the SpiceDBChannels to the update graph. Channels from
+// the config file take precedence, and conflicts between SpiceDBChannels
+// are resolved by object name so that the same one always wins. The
+// channels that were left out are returned with the reason, by object name.
+func mergeChannels(graph *updates.UpdateGraph, objs []*v1alpha1.SpiceDBChannel) (updates.UpdateGraph, map[string]error) {
+	objs = slices.Clone(objs)
+	slices.SortFunc(objs, func(a, b *v1alpha1.SpiceDBChannel) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	merged := graph.Copy()
+	rejected := make(map[string]error)
+	for _, obj := range objs {
+		channel, err := updates.ChannelFromAPI(obj)
+		if err != nil {
+			rejected[obj.Name] = err
+			continue
+		}
+		var conflicts []updates.Channel
+		merged, conflicts = merged.WithChannels(channel)
+		if len(conflicts) > 0 {
+			rejected[obj.Name] = errChannelConflict
+		}
+	}
+	return merged, rejected
+}
+
+// subscribers returns the clusters that subscribe to any of the channels.
+func subscribers(clusters []*v1alpha1.SpiceDBCluster, channels []string) []*v1alpha1.SpiceDBCluster {
+	var out []*v1alpha1.SpiceDBCluster
+	for _, cluster := range clusters {
+		if slices.ContainsFunc(channels, cluster.IsSubscribed) {
+			out = append(out, cluster)
+		}
+	}
+	return out
+}
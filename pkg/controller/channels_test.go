@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+func testChannel(name, channel, datastore string, nodes ...string) *v1alpha1.SpiceDBChannel {
+	c := &v1alpha1.SpiceDBChannel{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1alpha1.ChannelSpec{
+			Channel:   channel,
+			Datastore: datastore,
+			Edges:     make(map[string]v1alpha1.ChannelEdges),
+		},
+	}
+	for i, n := range nodes {
+		c.Spec.Nodes = append(c.Spec.Nodes, v1alpha1.ChannelNode{ID: n, Tag: n})
+		if i > 0 {
+			c.Spec.Edges[n] = v1alpha1.ChannelEdges{nodes[0]}
+		}
+	}
+	return c
+}
+
+func TestMergeChannels(t *testing.T) {
+	stable := updates.Channel{
+		Name:     "stable",
+		Metadata: map[string]string{"datastore": "postgres", "default": "true"},
+		Nodes:    []updates.State{{ID: "v1.0.0", Tag: "v1.0.0"}},
+		Edges:    updates.EdgeSet{"v1.0.0": {}},
+	}
+
+	tests := []struct {
+		name             string
+		channels         []*v1alpha1.SpiceDBChannel
+		expectedChannels []string
+		expectedRejected map[string]string
+	}{
+		{
+			name:             "no channels",
+			expectedChannels: []string{"postgres/stable"},
+			expectedRejected: map[string]string{},
+		},
+		{
+			name: "adds channels",
+			channels: []*v1alpha1.SpiceDBChannel{
+				testChannel("hotfix", "", "postgres", "v1.0.1", "v1.0.0"),
+				testChannel("mysql-stable", "stable", "mysql", "v1.0.1", "v1.0.0"),
+			},
+			expectedChannels: []string{"postgres/stable", "postgres/hotfix", "mysql/stable"},
+			expectedRejected: map[string]string{},
+		},
+		{
+			name: "the config file wins over a channel",
+			channels: []*v1alpha1.SpiceDBChannel{
+				testChannel("postgres-stable", "stable", "postgres", "v1.0.1", "v1.0.0"),
+			},
+			expectedChannels: []string{"postgres/stable"},
+			expectedRejected: map[string]string{"postgres-stable": "channel is already defined"},
+		},
+		{
+			name: "conflicts between channels are resolved by object name",
+			channels: []*v1alpha1.SpiceDBChannel{
+				testChannel("team-b", "hotfix", "postgres", "v1.0.2", "v1.0.0"),
+				testChannel("team-a", "hotfix", "postgres", "v1.0.1", "v1.0.0"),
+			},
+			expectedChannels: []string{"postgres/stable", "postgres/hotfix"},
+			expectedRejected: map[string]string{"team-b": "channel is already defined"},
+		},
+		{
+			name: "invalid channels are left out",
+			channels: []*v1alpha1.SpiceDBChannel{
+				testChannel("hotfix", "", "postgres", "v1.0.1"),
+			},
+			expectedChannels: []string{"postgres/stable"},
+			expectedRejected: map[string]string{"hotfix": "invalid channel postgres/hotfix: missing edges"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := updates.UpdateGraph{Channels: []updates.Channel{stable}}
+			merged, rejected := mergeChannels(&graph, tt.channels)
+
+			channels := make([]string, 0, len(merged.Channels))
+			for _, c := range merged.Channels {
+				channels = append(channels, c.Metadata[updates.DatastoreMetadataKey]+"/"+c.Name)
+			}
+			require.Equal(t, tt.expectedChannels, channels)
+
+			rejectedErrs := make(map[string]string, len(rejected))
+			for name, err := range rejected {
+				rejectedErrs[name] = err.Error()
+			}
+			require.Equal(t, tt.expectedRejected, rejectedErrs)
+			require.Equal(t, []updates.Channel{stable}, graph.Channels)
+		})
+	}
+
+	t.Run("the first object by name wins a conflict", func(t *testing.T) {
+		graph := updates.UpdateGraph{}
+		merged, _ := mergeChannels(&graph, []*v1alpha1.SpiceDBChannel{
+			testChannel("team-b", "hotfix", "postgres", "v1.0.2", "v1.0.0"),
+			testChannel("team-a", "hotfix", "postgres", "v1.0.1", "v1.0.0"),
+		})
+		require.Len(t, merged.Channels, 1)
+		require.Equal(t, "v1.0.1", merged.Channels[0].Nodes[0].ID)
+	})
+}
+
+func TestSubscribers(t *testing.T) {
+	cluster := func(name, channel, current string) *v1alpha1.SpiceDBCluster {
+		c := &v1alpha1.SpiceDBCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ClusterSpec{Channel: channel},
+		}
+		if len(current) > 0 {
+			c.Status.CurrentVersion = &v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: current}
+		}
+		return c
+	}
+	clusters := []*v1alpha1.SpiceDBCluster{
+		cluster("follows-hotfix", "hotfix", "hotfix"),
+		cluster("switching-to-hotfix", "Hotfix", "stable"),
+		cluster("leaving-hotfix", "stable", "hotfix"),
+		cluster("default-channel", "", "stable"),
+		cluster("other", "rapid", "rapid"),
+	}
+
+	tests := []struct {
+		name     string
+		channels []string
+		expected []string
+	}{
+		{
+			name:     "spec and status channels",
+			channels: []string{"hotfix"},
+			expected: []string{"follows-hotfix", "switching-to-hotfix", "leaving-hotfix"},
+		},
+		{
+			name:     "renamed channel",
+			channels: []string{"rapid", "stable"},
+			expected: []string{"switching-to-hotfix", "leaving-hotfix", "default-channel", "other"},
+		},
+		{
+			name:     "no subscribers",
+			channels: []string{"nightly"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, c := range subscribers(clusters, tt.channels) {
+				names = append(names, c.Name)
+			}
+			require.Equal(t, tt.expected, names)
+		})
+	}
+}
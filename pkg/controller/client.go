@@ -32,3 +32,16 @@ func (c *Controller) Patch(ctx context.Context, patch *v1alpha1.SpiceDBCluster)
 	_, err = c.client.Resource(v1alpha1ClusterGVR).Namespace(patch.Namespace).Patch(ctx, patch.Name, types.ApplyPatchType, data, metadata.PatchForceOwned)
 	return err
 }
+
+func (c *SchemaController) PatchStatus(ctx context.Context, patch *v1alpha1.SpiceDBSchema) error {
+	for i := range patch.Status.Conditions {
+		patch.Status.Conditions[i].ObservedGeneration = patch.Generation
+	}
+	patch.ManagedFields = nil
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.Resource(v1alpha1SchemaGVR).Namespace(patch.Namespace).Patch(ctx, patch.Name, types.ApplyPatchType, data, metadata.PatchForceOwned, "status")
+	return err
+}
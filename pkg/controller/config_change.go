@@ -35,7 +35,8 @@ func (c *ConfigChangedHandler) Handle(ctx context.Context) {
 
 	preconditionsFailedCondition := cluster.FindStatusCondition(v1alpha1.ConditionTypePreconditionsFailed)
 	if cluster.GetGeneration() != status.Status.ObservedGeneration || secretHash != status.Status.SecretHash ||
-		(preconditionsFailedCondition != nil && preconditionsFailedCondition.Reason == v1alpha1.ConditionReasonMissingSecret) {
+		(preconditionsFailedCondition != nil && (preconditionsFailedCondition.Reason == v1alpha1.ConditionReasonMissingSecret ||
+			preconditionsFailedCondition.Reason == v1alpha1.ConditionReasonMissingConfigMap)) {
 		logr.FromContextOrDiscard(ctx).V(4).Info("spicedb configuration changed")
 		status.Status.ObservedGeneration = cluster.GetGeneration()
 		status.Status.SecretHash = secretHash
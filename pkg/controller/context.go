@@ -20,12 +20,16 @@ var (
 	CtxSecretNN               = typedctx.WithDefault(types.NamespacedName{})
 	CtxSecret                 = typedctx.WithDefault[*corev1.Secret](nil)
 	CtxSecretHash             = typedctx.WithDefault("")
+	CtxSchemaConfigMapNN      = typedctx.WithDefault(types.NamespacedName{})
+	CtxSchemaConfigMap        = typedctx.WithDefault[*corev1.ConfigMap](nil)
 	CtxCluster                = typedctx.WithDefault[*v1alpha1.SpiceDBCluster](nil)
 	CtxConfig                 = typedctx.WithDefault[*config.Config](nil)
 	CtxMigrationHash          = typedctx.WithDefault("")
 	CtxDeployments            = typedctx.Boxed(make([]*appsv1.Deployment, 0))
+	CtxDispatchDeployments    = typedctx.Boxed(make([]*appsv1.Deployment, 0))
 	CtxJobs                   = typedctx.Boxed(make([]*batchv1.Job, 0))
 	CtxCurrentMigrationJob    = typedctx.WithDefault[*batchv1.Job](nil)
 	CtxCurrentSpiceDeployment = typedctx.WithDefault[*appsv1.Deployment](nil)
 	CtxSelfPauseObject        = typedctx.WithDefault(new(v1alpha1.SpiceDBCluster))
+	CtxSpiceDBSchema          = typedctx.WithDefault[*v1alpha1.SpiceDBSchema](nil)
 )
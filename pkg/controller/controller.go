@@ -7,7 +7,9 @@ import (
 	"io"
 	"os"
 	"sync"
+	"time"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/controller-idioms/adopt"
 	"github.com/authzed/controller-idioms/cachekeys"
 	"github.com/authzed/controller-idioms/component"
@@ -24,6 +26,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -44,18 +47,23 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/textlogger"
 	"k8s.io/kubectl/pkg/util/openapi"
+	"k8s.io/utils/ptr"
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
 	"github.com/authzed/spicedb-operator/pkg/config"
 	"github.com/authzed/spicedb-operator/pkg/metadata"
+	"github.com/authzed/spicedb-operator/pkg/spicedb"
 )
 
 // +kubebuilder:rbac:groups="authzed.com",resources=spicedbclusters,verbs=get;watch;list;create;update;patch;delete
 // +kubebuilder:rbac:groups="authzed.com",resources=spicedbclusters/status,verbs=get;watch;list;create;update;patch;delete
+// +kubebuilder:rbac:groups="authzed.com",resources=spicedbchannels,verbs=get;watch;list
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups="",resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
@@ -70,8 +78,10 @@ func init() {
 
 var (
 	v1alpha1ClusterGVR  = v1alpha1.SchemeGroupVersion.WithResource(v1alpha1.SpiceDBClusterResourceName)
+	v1alpha1ChannelGVR  = v1alpha1.SchemeGroupVersion.WithResource(v1alpha1.SpiceDBChannelResourceName)
 	OwnedFactoryKey     = typed.NewFactoryKey(v1alpha1.SpiceDBClusterResourceName, "local", "unfiltered")
 	DependentFactoryKey = typed.NewFactoryKey(v1alpha1.SpiceDBClusterResourceName, "local", "dependents")
+	ChannelFactoryKey   = typed.NewFactoryKey(v1alpha1.SpiceDBChannelResourceName, "local", "unfiltered")
 )
 
 type Controller struct {
@@ -136,6 +146,23 @@ func NewController(ctx context.Context, registry *typed.Registry, dclient dynami
 		return nil, err
 	}
 
+	// SpiceDBChannels are merged into the update graph when a cluster is
+	// synced, so a change only needs to requeue its subscribers
+	channelInformerFactory := registry.MustNewFilteredDynamicSharedInformerFactory(
+		ChannelFactoryKey,
+		dclient,
+		0,
+		metav1.NamespaceAll,
+		nil,
+	)
+	if _, err := channelInformerFactory.ForResource(v1alpha1ChannelGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { c.syncChannel(nil, obj) },
+		UpdateFunc: func(oldObj, obj any) { c.syncChannel(oldObj, obj) },
+		DeleteFunc: func(obj any) { c.syncChannel(obj, nil) },
+	}); err != nil {
+		return nil, err
+	}
+
 	externalInformerFactory := registry.MustNewFilteredDynamicSharedInformerFactory(
 		DependentFactoryKey,
 		dclient,
@@ -149,6 +176,7 @@ func NewController(ctx context.Context, registry *typed.Registry, dclient dynami
 	for _, gvr := range []schema.GroupVersionResource{
 		appsv1.SchemeGroupVersion.WithResource("deployments"),
 		corev1.SchemeGroupVersion.WithResource("secrets"),
+		corev1.SchemeGroupVersion.WithResource("configmaps"),
 		corev1.SchemeGroupVersion.WithResource("serviceaccounts"),
 		corev1.SchemeGroupVersion.WithResource("services"),
 		corev1.SchemeGroupVersion.WithResource("pods"),
@@ -171,9 +199,11 @@ func NewController(ctx context.Context, registry *typed.Registry, dclient dynami
 
 	// start informers
 	ownedInformerFactory.Start(ctx.Done())
+	channelInformerFactory.Start(ctx.Done())
 	externalInformerFactory.Start(ctx.Done())
 	fileInformerFactory.Start(ctx.Done())
 	ownedInformerFactory.WaitForCacheSync(ctx.Done())
+	channelInformerFactory.WaitForCacheSync(ctx.Done())
 	externalInformerFactory.WaitForCacheSync(ctx.Done())
 	fileInformerFactory.WaitForCacheSync(ctx.Done())
 
@@ -184,6 +214,8 @@ func NewController(ctx context.Context, registry *typed.Registry, dclient dynami
 
 	deploymentHandlerChain := chain(
 		c.ensureDeployment,
+		c.verifyRollout,
+		c.bootstrapSchema,
 		c.cleanupJob,
 	).Handler(HandlerDeploymentKey)
 
@@ -195,18 +227,24 @@ func NewController(ctx context.Context, registry *typed.Registry, dclient dynami
 	c.mainHandler = chain(
 		c.pauseCluster,
 		c.secretAdopter,
+		c.schemaConfigMapAdopter,
 		c.checkConfigChanged,
 		c.validateConfig,
 		parallel(
 			c.ensureServiceAccount,
 			c.ensureRole,
 			c.ensureService,
+			c.ensureDispatchService,
+			c.ensureIsolatedDispatchService,
 		),
 		c.ensureRoleBinding,
+		c.provisionDatastore,
 		CtxDeployments.BoxBuilder("deploymentsPre"),
+		CtxDispatchDeployments.BoxBuilder("dispatchDeploymentsPre"),
 		CtxJobs.BoxBuilder("jobsPre"),
 		parallel(
 			c.getDeployments,
+			c.getDispatchDeployments,
 			c.getJobs,
 		),
 		c.checkMigrations(
@@ -307,10 +345,17 @@ func (c *Controller) syncOwnedResource(ctx context.Context, gvr schema.GroupVers
 		Namespace: cluster.Namespace,
 	})
 
-	c.configLock.RLock()
-	cfg := c.config.Copy()
+	var schemaConfigMap string
+	if cluster.Spec.Schema != nil {
+		schemaConfigMap = cluster.Spec.Schema.ConfigMapName
+	}
+	ctx = CtxSchemaConfigMapNN.WithValue(ctx, types.NamespacedName{
+		Name:      schemaConfigMap,
+		Namespace: cluster.Namespace,
+	})
+
+	cfg := c.operatorConfig()
 	ctx = CtxOperatorConfig.WithValue(ctx, &cfg)
-	c.configLock.RUnlock()
 
 	logger.V(4).Info("syncing owned object", "gvr", gvr)
 
@@ -362,20 +407,88 @@ func (c *Controller) ensureDeployment(next ...handler.Handler) handler.Handler {
 			logr.FromContextOrDiscard(ctx).V(4).Info("deleting deployment", "namespace", nn.Namespace, "name", nn.Name)
 			return c.kclient.AppsV1().Deployments(nn.Namespace).Delete(ctx, nn.Name, metav1.DeleteOptions{})
 		},
-		getDeploymentPods: func(ctx context.Context) []*corev1.Pod {
+		getDeploymentPods: func(ctx context.Context, podComponent string) []*corev1.Pod {
 			return component.NewIndexedComponent(
 				typed.IndexerFor[*corev1.Pod](c.Registry, typed.NewRegistryKey(DependentFactoryKey, corev1.SchemeGroupVersion.WithResource("pods"))),
 				metadata.OwningClusterIndex,
 				func(ctx context.Context) labels.Selector {
-					return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, metadata.ComponentSpiceDBLabelValue)
+					return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, podComponent)
+				},
+			).List(ctx, CtxClusterNN.MustValue(ctx))
+		},
+		getService: func(ctx context.Context) *corev1.Service {
+			services := component.NewIndexedComponent(
+				typed.IndexerFor[*corev1.Service](c.Registry, typed.NewRegistryKey(DependentFactoryKey, corev1.SchemeGroupVersion.WithResource("services"))),
+				metadata.OwningClusterIndex,
+				func(ctx context.Context) labels.Selector {
+					return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, metadata.ComponentServiceLabel)
 				},
 			).List(ctx, CtxClusterNN.MustValue(ctx))
+			if len(services) == 0 {
+				return nil
+			}
+			return services[0]
+		},
+		patchStatus: c.PatchStatus,
+		now:         time.Now,
+		next:        handler.Handlers(next).MustOne(),
+	})
+}
+
+func (c *Controller) verifyRollout(next ...handler.Handler) handler.Handler {
+	return handler.NewTypeHandler(&VerifyRolloutHandler{
+		recorder: c.Recorder,
+		verify: func(ctx context.Context, config *config.Config) error {
+			var check *v1.CheckPermissionRequest
+			if len(config.VerifyRolloutCheck) > 0 {
+				var err error
+				check, err = spicedb.ParseCheck(config.VerifyRolloutCheck)
+				if err != nil {
+					return err
+				}
+			}
+			client, err := newSpiceDBClient(config)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(ctx, verifyRolloutTimeout)
+			defer cancel()
+			return spicedb.VerifyRollout(ctx, client, check)
+		},
+		patchStatus:   c.PatchStatus,
+		nextSelfPause: c.selfPauseCluster(handler.NoopHandler),
+		next:          handler.Handlers(next).MustOne(),
+	})
+}
+
+func (c *Controller) bootstrapSchema(next ...handler.Handler) handler.Handler {
+	return handler.NewTypeHandler(&SchemaBootstrapHandler{
+		recorder: c.Recorder,
+		writeSchema: func(ctx context.Context, config *config.Config, schema string, rels []*v1.Relationship) error {
+			client, err := newSpiceDBClient(config)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			_, err = spicedb.WriteSchema(ctx, client, schema, rels)
+			return err
 		},
 		patchStatus: c.PatchStatus,
 		next:        handler.Handlers(next).MustOne(),
 	})
 }
 
+// newSpiceDBClient returns a client for the API of the cluster described by
+// config, connecting through the cluster's service.
+func newSpiceDBClient(config *config.Config) (*spicedb.Client, error) {
+	return spicedb.NewClient(spicedb.Endpoint(config.Name, config.Namespace), config.PresharedKey, len(config.TLSSecretName) > 0)
+}
+
 func (c *Controller) cleanupJob(...handler.Handler) handler.Handler {
 	return handler.NewTypeHandler(&JobCleanupHandler{
 		registry: c.Registry,
@@ -410,7 +523,25 @@ func (c *Controller) cleanupJob(...handler.Handler) handler.Handler {
 
 func (c *Controller) waitForMigrationsHandler(next ...handler.Handler) handler.Handler {
 	return handler.NewTypeHandler(&WaitForMigrationsHandler{
-		recorder:              c.Recorder,
+		recorder: c.Recorder,
+		now:      time.Now,
+		getJobPods: func(ctx context.Context) []*corev1.Pod {
+			return component.NewIndexedComponent(
+				typed.IndexerFor[*corev1.Pod](c.Registry, typed.NewRegistryKey(DependentFactoryKey, corev1.SchemeGroupVersion.WithResource("pods"))),
+				metadata.OwningClusterIndex,
+				func(ctx context.Context) labels.Selector {
+					return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, metadata.ComponentMigrationJobLabelValue)
+				},
+			).List(ctx, CtxClusterNN.MustValue(ctx))
+		},
+		getPodLogs: func(ctx context.Context, nn types.NamespacedName, tailLines int64) (string, error) {
+			logs, err := c.kclient.CoreV1().Pods(nn.Namespace).GetLogs(nn.Name, &corev1.PodLogOptions{
+				Container:  config.ContainerNameMigrate,
+				TailLines:  &tailLines,
+				LimitBytes: ptr.To(int64(4096)),
+			}).DoRaw(ctx)
+			return string(logs), err
+		},
 		nextSelfPause:         HandlerSelfPauseKey.MustFind(next),
 		nextDeploymentHandler: HandlerDeploymentKey.MustFind(next),
 	})
@@ -464,6 +595,43 @@ func (c *Controller) secretAdopter(next ...handler.Handler) handler.Handler {
 	)
 }
 
+func (c *Controller) schemaConfigMapAdopter(next ...handler.Handler) handler.Handler {
+	configMapsGVR := corev1.SchemeGroupVersion.WithResource("configmaps")
+	return NewConfigMapAdoptionHandler(
+		c.Recorder,
+		func(ctx context.Context) (*corev1.ConfigMap, error) {
+			return typed.ListerFor[*corev1.ConfigMap](c.Registry, typed.NewRegistryKey(DependentFactoryKey, configMapsGVR)).ByNamespace(CtxSchemaConfigMapNN.MustValue(ctx).Namespace).Get(CtxSchemaConfigMapNN.MustValue(ctx).Name)
+		},
+		func(ctx context.Context, err error) {
+			cluster := CtxCluster.MustValue(ctx)
+			status := &v1alpha1.SpiceDBCluster{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       v1alpha1.SpiceDBClusterKind,
+					APIVersion: v1alpha1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{Namespace: cluster.Namespace, Name: cluster.Name},
+				Status:     *cluster.Status.DeepCopy(),
+			}
+			status.Status.ObservedGeneration = cluster.GetGeneration()
+			status.SetStatusCondition(v1alpha1.NewMissingConfigMapCondition(CtxSchemaConfigMapNN.MustValue(ctx)))
+			if err := c.PatchStatus(ctx, status); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+			}
+			// keep checking to see if the configmap is added
+			QueueOps.RequeueErr(ctx, err)
+		},
+		typed.IndexerFor[*corev1.ConfigMap](c.Registry, typed.NewRegistryKey(DependentFactoryKey, configMapsGVR)),
+		func(ctx context.Context, configMap *applycorev1.ConfigMapApplyConfiguration, options metav1.ApplyOptions) (*corev1.ConfigMap, error) {
+			return c.kclient.CoreV1().ConfigMaps(*configMap.Namespace).Apply(ctx, configMap, options)
+		},
+		func(ctx context.Context, nn types.NamespacedName) error {
+			_, err := c.kclient.CoreV1().ConfigMaps(nn.Namespace).Get(ctx, nn.Name, metav1.GetOptions{})
+			return err
+		},
+		handler.Handlers(next).MustOne(),
+	)
+}
+
 func (c *Controller) checkConfigChanged(next ...handler.Handler) handler.Handler {
 	return handler.NewTypeHandler(&ConfigChangedHandler{
 		patchStatus: c.PatchStatus,
@@ -476,6 +644,7 @@ func (c *Controller) validateConfig(next ...handler.Handler) handler.Handler {
 		patchStatus: c.PatchStatus,
 		recorder:    c.Recorder,
 		resources:   c.resources,
+		now:         time.Now,
 		next:        handler.Handlers(next).MustOne(),
 	})
 }
@@ -494,6 +663,24 @@ func (c *Controller) getDeployments(...handler.Handler) handler.Handler {
 	), "getDeployments")
 }
 
+func (c *Controller) getDispatchDeployments(...handler.Handler) handler.Handler {
+	return handler.NewHandler(component.NewComponentContextHandler[*appsv1.Deployment](
+		CtxDispatchDeployments,
+		c.dispatchDeploymentComponent(),
+		CtxClusterNN,
+		handler.NoopHandler,
+	), "getDispatchDeployments")
+}
+
+func (c *Controller) dispatchDeploymentComponent() *component.Component[*appsv1.Deployment] {
+	return component.NewIndexedComponent(
+		typed.IndexerFor[*appsv1.Deployment](c.Registry, typed.NewRegistryKey(DependentFactoryKey, appsv1.SchemeGroupVersion.WithResource("deployments"))),
+		metadata.OwningClusterIndex,
+		func(ctx context.Context) labels.Selector {
+			return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, metadata.ComponentDispatchLabelValue)
+		})
+}
+
 func (c *Controller) getJobs(...handler.Handler) handler.Handler {
 	return handler.NewHandler(component.NewComponentContextHandler[*batchv1.Job](
 		CtxJobs,
@@ -522,6 +709,43 @@ func (c *Controller) runMigration(next ...handler.Handler) handler.Handler {
 	})
 }
 
+func (c *Controller) provisionDatastore(next ...handler.Handler) handler.Handler {
+	jobsGVR := batchv1.SchemeGroupVersion.WithResource("jobs")
+	return handler.NewTypeHandler(&DatastoreProvisioningHandler{
+		recorder: c.Recorder,
+		getAdminURI: func(ctx context.Context, nn types.NamespacedName) (string, error) {
+			// the admin secret isn't adopted, so it's read directly
+			secret, err := c.kclient.CoreV1().Secrets(nn.Namespace).Get(ctx, nn.Name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			uri, ok := secret.Data[config.ProvisioningAdminURIKey]
+			if !ok {
+				return "", fmt.Errorf("provisioning secret %q has no %s", nn.Name, config.ProvisioningAdminURIKey)
+			}
+			return string(uri), nil
+		},
+		applySecret: func(ctx context.Context, secret *applycorev1.SecretApplyConfiguration) error {
+			// use a separate field manager from secret adoption, which
+			// owns the labels and annotations
+			_, err := c.kclient.CoreV1().Secrets(*secret.Namespace).Apply(ctx, secret, metav1.ApplyOptions{FieldManager: metadata.FieldManager + "-provisioning", Force: true})
+			return err
+		},
+		getJob: func(_ context.Context, nn types.NamespacedName) (*batchv1.Job, error) {
+			return typed.ListerFor[*batchv1.Job](c.Registry, typed.NewRegistryKey(DependentFactoryKey, jobsGVR)).ByNamespace(nn.Namespace).Get(nn.Name)
+		},
+		applyJob: func(ctx context.Context, job *applybatchv1.JobApplyConfiguration) error {
+			_, err := c.kclient.BatchV1().Jobs(*job.Namespace).Apply(ctx, job, metadata.ApplyForceOwned)
+			return err
+		},
+		deleteJob: func(ctx context.Context, nn types.NamespacedName) error {
+			return c.kclient.BatchV1().Jobs(nn.Namespace).Delete(ctx, nn.Name, metav1.DeleteOptions{PropagationPolicy: ptr.To(metav1.DeletePropagationBackground)})
+		},
+		patchStatus: c.PatchStatus,
+		next:        handler.Handlers(next).MustOne(),
+	})
+}
+
 func (c *Controller) checkMigrations(next ...handler.Handler) handler.Handler {
 	return handler.NewTypeHandler(&MigrationCheckHandler{
 		recorder:                c.Recorder,
@@ -654,3 +878,121 @@ func (c *Controller) ensureService(...handler.Handler) handler.Handler {
 			return CtxConfig.MustValue(ctx).Service()
 		}), "ensureService")
 }
+
+// ensureDispatchService manages the Service in front of the dispatch tier.
+// When the dispatch tier is turned off, the Service is only removed after
+// the dispatch Deployments are gone, since api pods that haven't been rolled
+// yet still dispatch through it.
+func (c *Controller) ensureDispatchService(...handler.Handler) handler.Handler {
+	services := component.NewIndexedComponent(
+		typed.IndexerFor[*corev1.Service](
+			c.Registry,
+			typed.NewRegistryKey(
+				DependentFactoryKey,
+				corev1.SchemeGroupVersion.WithResource("services"),
+			)),
+		metadata.OwningClusterIndex,
+		func(ctx context.Context) labels.Selector {
+			return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, metadata.ComponentDispatchServiceLabel)
+		})
+	deleteService := func(ctx context.Context, nn types.NamespacedName) error {
+		logr.FromContextOrDiscard(ctx).V(4).Info("deleting dispatch service", "namespace", nn.Namespace, "name", nn.Name)
+		return c.kclient.CoreV1().Services(nn.Namespace).Delete(ctx, nn.Name, metav1.DeleteOptions{})
+	}
+	ensure := component.NewEnsureComponentByHash(
+		component.NewHashableComponent(services, hash.NewObjectHash(), "authzed.com/controller-component-hash"),
+		CtxClusterNN,
+		QueueOps,
+		func(ctx context.Context, apply *applycorev1.ServiceApplyConfiguration) (*corev1.Service, error) {
+			logr.FromContextOrDiscard(ctx).V(4).Info("applying dispatch service", "namespace", *apply.Namespace, "name", *apply.Name)
+			return c.kclient.CoreV1().Services(*apply.Namespace).Apply(ctx, apply, metadata.ApplyForceOwned)
+		},
+		deleteService,
+		func(ctx context.Context) *applycorev1.ServiceApplyConfiguration {
+			return CtxConfig.MustValue(ctx).DispatchService()
+		})
+
+	return handler.NewHandlerFromFunc(func(ctx context.Context) {
+		if CtxConfig.MustValue(ctx).DispatchTier.Enabled() {
+			ensure.Handle(ctx)
+			return
+		}
+		nn := CtxClusterNN.MustValue(ctx)
+		if len(c.dispatchDeploymentComponent().List(ctx, nn)) > 0 {
+			return
+		}
+		for _, s := range services.List(ctx, nn) {
+			if err := deleteService(ctx, types.NamespacedName{Namespace: s.Namespace, Name: s.Name}); err != nil {
+				QueueOps.RequeueErr(ctx, err)
+				return
+			}
+		}
+	}, "ensureDispatchService")
+}
+
+// ensureIsolatedDispatchService manages the Services in front of isolated
+// dispatch rings. During an update between versions that can't dispatch to
+// each other, the new pods dispatch through a Service of their own. The
+// Services of earlier rings are kept until none of their pods are left,
+// since pods that haven't been replaced yet still dispatch through them.
+func (c *Controller) ensureIsolatedDispatchService(...handler.Handler) handler.Handler {
+	services := component.NewIndexedComponent(
+		typed.IndexerFor[*corev1.Service](
+			c.Registry,
+			typed.NewRegistryKey(
+				DependentFactoryKey,
+				corev1.SchemeGroupVersion.WithResource("services"),
+			)),
+		metadata.OwningClusterIndex,
+		func(ctx context.Context) labels.Selector {
+			return metadata.SelectorForComponent(CtxClusterNN.MustValue(ctx).Name, metadata.ComponentIsolatedDispatchServiceLabel)
+		})
+	hashable := component.NewHashableComponent(services, hash.NewObjectHash(), "authzed.com/controller-component-hash")
+
+	return handler.NewHandlerFromFunc(func(ctx context.Context) {
+		config := CtxConfig.MustValue(ctx)
+		nn := CtxClusterNN.MustValue(ctx)
+		existing := services.List(ctx, nn)
+
+		var desiredName string
+		if len(config.DispatchIsolation) > 0 {
+			desired := config.IsolatedDispatchService()
+			desiredName = *desired.Name
+			desiredHash := hashable.Hash(desired)
+			upToDate := false
+			for _, s := range existing {
+				if s.Name == desiredName && hashable.Equal(s.GetAnnotations()[hashable.HashAnnotationKey], desiredHash) {
+					upToDate = true
+				}
+			}
+			if !upToDate {
+				desired = desired.WithAnnotations(map[string]string{hashable.HashAnnotationKey: desiredHash})
+				logr.FromContextOrDiscard(ctx).V(4).Info("applying isolated dispatch service", "namespace", *desired.Namespace, "name", desiredName)
+				if _, err := c.kclient.CoreV1().Services(*desired.Namespace).Apply(ctx, desired, metadata.ApplyForceOwned); err != nil {
+					QueueOps.RequeueAPIErr(ctx, err)
+					return
+				}
+			}
+		}
+
+		for _, s := range existing {
+			if s.Name == desiredName {
+				continue
+			}
+			endpoints, err := c.kclient.CoreV1().Endpoints(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return
+			}
+			if err == nil && len(endpoints.Subsets) > 0 {
+				// pods of this ring are still running
+				continue
+			}
+			logr.FromContextOrDiscard(ctx).V(4).Info("deleting isolated dispatch service", "namespace", s.Namespace, "name", s.Name)
+			if err := c.kclient.CoreV1().Services(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return
+			}
+		}
+	}, "ensureIsolatedDispatchService")
+}
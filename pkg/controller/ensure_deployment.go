@@ -3,11 +3,13 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	applyappsv1 "k8s.io/client-go/applyconfigurations/apps/v1"
 
@@ -15,14 +17,17 @@ import (
 	"github.com/authzed/controller-idioms/hash"
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
 	"github.com/authzed/spicedb-operator/pkg/metadata"
 )
 
 type DeploymentHandler struct {
 	applyDeployment   func(ctx context.Context, dep *applyappsv1.DeploymentApplyConfiguration) (*appsv1.Deployment, error)
 	deleteDeployment  func(ctx context.Context, nn types.NamespacedName) error
-	getDeploymentPods func(ctx context.Context) []*corev1.Pod
+	getDeploymentPods func(ctx context.Context, component string) []*corev1.Pod
+	getService        func(ctx context.Context) *corev1.Service
 	patchStatus       func(ctx context.Context, patch *v1alpha1.SpiceDBCluster) error
+	now               func() time.Time
 	next              handler.ContextHandler
 }
 
@@ -45,12 +50,99 @@ func (m *DeploymentHandler) Handle(ctx context.Context) {
 	migrationHash := CtxMigrationHash.MustValue(ctx)
 	secretHash := CtxSecretHash.MustValue(ctx)
 	config := CtxConfig.MustValue(ctx)
-	newDeployment := config.Deployment(migrationHash, secretHash)
-	deploymentHash := hash.Object(newDeployment)
+
+	// the dispatch tier is rolled first, so that the api tier never points
+	// at dispatch pods that aren't running yet
+	if config.DispatchTier.Enabled() {
+		if _, ok := m.rollout(ctx, currentStatus, deploymentRollout{
+			description: "dispatch deployment",
+			component:   metadata.ComponentDispatchLabelValue,
+			desired:     config.DispatchDeployment(migrationHash, secretHash),
+			existing:    CtxDispatchDeployments.MustValue(ctx),
+			replicas:    config.DispatchTier.Replicas,
+		}); !ok {
+			return
+		}
+	}
+
+	desired := config.Deployment(migrationHash, secretHash)
+	existing := CtxDeployments.MustValue(ctx)
+	if config.Rollout.BlueGreen() {
+		if !m.pinService(ctx, currentStatus, config.Rollout, *desired.Name) {
+			return
+		}
+		// each color is a separate Deployment; only the one for the target
+		// color is rolled, the others keep serving until the cutover
+		existing = slices.DeleteFunc(slices.Clone(existing), func(d *appsv1.Deployment) bool {
+			return d.GetName() != *desired.Name
+		})
+	}
+	cachedDeployment, ok := m.rollout(ctx, currentStatus, deploymentRollout{
+		description: "deployment",
+		component:   metadata.ComponentSpiceDBLabelValue,
+		desired:     desired,
+		existing:    existing,
+		replicas:    config.Replicas,
+	})
+	if !ok {
+		return
+	}
+	ctx = CtxCurrentSpiceDeployment.WithValue(ctx, cachedDeployment)
+
+	if config.Rollout.BlueGreen() {
+		if !m.cutover(ctx, currentStatus, config.Rollout, cachedDeployment) {
+			return
+		}
+	} else if currentStatus.Status.BlueGreen != nil {
+		currentStatus.Status.BlueGreen = nil
+		if err := m.patchStatus(ctx, currentStatus); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+	}
+
+	// the dispatch tier is removed only once the api tier no longer uses it
+	if !config.DispatchTier.Enabled() {
+		for _, o := range CtxDispatchDeployments.MustValue(ctx) {
+			if err := m.deleteDeployment(ctx, types.NamespacedName{Namespace: currentStatus.Namespace, Name: o.GetName()}); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return
+			}
+		}
+	}
+
+	// deployment is finished rolling out, remove condition
+	if currentStatus.IsStatusConditionTrue(v1alpha1.ConditionTypeRolling) ||
+		currentStatus.IsStatusConditionTrue(v1alpha1.ConditionTypeRolloutError) {
+		currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeRolling)
+		currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeRolloutError)
+		if err := m.patchStatus(ctx, currentStatus); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+	}
+
+	m.next.Handle(ctx)
+}
+
+// deploymentRollout is one of the spicedb Deployments to roll out
+type deploymentRollout struct {
+	description string
+	component   string
+	desired     *applyappsv1.DeploymentApplyConfiguration
+	existing    []*appsv1.Deployment
+	replicas    int32
+}
+
+// rollout applies the desired Deployment if it doesn't exist yet and waits
+// for it to become available. It returns false if the sync should stop
+// here; the queue has already been updated in that case.
+func (m *DeploymentHandler) rollout(ctx context.Context, currentStatus *v1alpha1.SpiceDBCluster, r deploymentRollout) (*appsv1.Deployment, bool) {
+	deploymentHash := hash.Object(r.desired)
 
 	matchingObjs := make([]*appsv1.Deployment, 0)
 	extraObjs := make([]*appsv1.Deployment, 0)
-	for _, o := range CtxDeployments.MustValue(ctx) {
+	for _, o := range r.existing {
 		annotations := o.GetAnnotations()
 		if annotations == nil {
 			extraObjs = append(extraObjs, o)
@@ -66,90 +158,199 @@ func (m *DeploymentHandler) Handle(ctx context.Context) {
 	// deployment with correct hash exists
 	if len(matchingObjs) == 1 {
 		cachedDeployment = matchingObjs[0]
-		ctx = CtxCurrentSpiceDeployment.WithValue(ctx, cachedDeployment)
 
 		// delete extra objects
 		for _, o := range extraObjs {
 			if err := m.deleteDeployment(ctx, types.NamespacedName{Namespace: currentStatus.Namespace, Name: o.GetName()}); err != nil {
 				QueueOps.RequeueAPIErr(ctx, err)
-				return
+				return nil, false
 			}
 		}
 	}
 
 	// apply if no matching object in controller
 	if len(matchingObjs) == 0 {
-		deployment, err := m.applyDeployment(ctx,
-			newDeployment.WithAnnotations(
+		// the new deployment will need to be verified once it has rolled out
+		if currentStatus.FindStatusCondition(v1alpha1.ConditionTypeVerified) != nil {
+			currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeVerified)
+			if err := m.patchStatus(ctx, currentStatus); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return nil, false
+			}
+		}
+		if _, err := m.applyDeployment(ctx,
+			r.desired.WithAnnotations(
 				map[string]string{metadata.SpiceDBConfigKey: deploymentHash},
 			),
-		)
-		if err != nil {
+		); err != nil {
 			QueueOps.RequeueAPIErr(ctx, err)
-			return
+			return nil, false
 		}
-		ctx = CtxCurrentSpiceDeployment.WithValue(ctx, deployment)
 	}
 
 	// if the deployment isn't in the cache yet, wait until another event
 	// comes in for it
 	if cachedDeployment == nil {
 		QueueOps.RequeueAfter(ctx, time.Second)
-		return
+		return nil, false
 	}
 
 	// check if any pods have errors
 	if cachedDeployment.Status.UnavailableReplicas > 0 {
 		// sort pods by newest first
-		pods := m.getDeploymentPods(ctx)
+		pods := m.getDeploymentPods(ctx, r.component)
 		sort.Slice(pods, func(i, j int) bool {
 			return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
 		})
-		for _, p := range m.getDeploymentPods(ctx) {
+		for _, p := range m.getDeploymentPods(ctx, r.component) {
 			for _, s := range p.Status.ContainerStatuses {
 				if s.LastTerminationState.Terminated != nil {
 					currentStatus.SetStatusCondition(v1alpha1.NewPodErrorCondition(s.LastTerminationState.Terminated.Message))
 					if err := m.patchStatus(ctx, currentStatus); err != nil {
 						QueueOps.RequeueAPIErr(ctx, err)
-						return
+						return nil, false
 					}
 					QueueOps.RequeueAfter(ctx, 2*time.Second)
-					return
+					return nil, false
 				}
 			}
 		}
 	}
 
 	// wait for deployment to be available
-	if cachedDeployment.Status.AvailableReplicas != config.Replicas ||
-		cachedDeployment.Status.ReadyReplicas != config.Replicas ||
-		cachedDeployment.Status.UpdatedReplicas != config.Replicas ||
+	if cachedDeployment.Status.AvailableReplicas != r.replicas ||
+		cachedDeployment.Status.ReadyReplicas != r.replicas ||
+		cachedDeployment.Status.UpdatedReplicas != r.replicas ||
 		cachedDeployment.Status.ObservedGeneration != cachedDeployment.Generation {
 		currentStatus.SetStatusCondition(v1alpha1.NewRollingCondition(
-			fmt.Sprintf("Waiting for deployment to be available: %d/%d available, %d/%d ready, %d/%d updated, %d/%d generation.",
-				cachedDeployment.Status.AvailableReplicas, config.Replicas,
-				cachedDeployment.Status.ReadyReplicas, config.Replicas,
-				cachedDeployment.Status.UpdatedReplicas, config.Replicas,
+			fmt.Sprintf("Waiting for %s to be available: %d/%d available, %d/%d ready, %d/%d updated, %d/%d generation.",
+				r.description,
+				cachedDeployment.Status.AvailableReplicas, r.replicas,
+				cachedDeployment.Status.ReadyReplicas, r.replicas,
+				cachedDeployment.Status.UpdatedReplicas, r.replicas,
 				cachedDeployment.Status.ObservedGeneration, cachedDeployment.Generation,
 			)))
 		if err := m.patchStatus(ctx, currentStatus); err != nil {
 			QueueOps.RequeueAPIErr(ctx, err)
-			return
+			return nil, false
 		}
 		QueueOps.RequeueAfter(ctx, 2*time.Second)
-		return
+		return nil, false
 	}
 
-	// deployment is finished rolling out, remove condition
-	if currentStatus.IsStatusConditionTrue(v1alpha1.ConditionTypeRolling) ||
-		currentStatus.IsStatusConditionTrue(v1alpha1.ConditionTypeRolloutError) {
-		currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeRolling)
-		currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeRolloutError)
+	return cachedDeployment, true
+}
+
+// pinService makes sure that the Service of a blue/green cluster only
+// selects the Deployment that is serving traffic before the Deployment
+// named target is rolled out next to it. Without a recorded active
+// Deployment (the first blue/green rollout of a cluster that used to roll,
+// or a cleared status) the Service selects every spicedb pod, so the
+// serving Deployment is recorded first. It returns false if the sync should
+// stop here; the queue has already been updated in that case.
+func (m *DeploymentHandler) pinService(ctx context.Context, currentStatus *v1alpha1.SpiceDBCluster, rollout config.RolloutConfig, target string) bool {
+	active := rollout.ActiveDeployment
+	if len(active) == 0 {
+		var serving *appsv1.Deployment
+		for _, d := range CtxDeployments.MustValue(ctx) {
+			if d.GetName() == target {
+				continue
+			}
+			if serving == nil || d.Status.AvailableReplicas > serving.Status.AvailableReplicas {
+				serving = d
+			}
+		}
+		// nothing else is serving, the target can take traffic as soon as
+		// it's available
+		if serving == nil {
+			return true
+		}
+		currentStatus.Status.BlueGreen = &v1alpha1.BlueGreenStatus{ActiveDeployment: serving.GetName()}
+		currentStatus.SetStatusCondition(v1alpha1.NewRollingCondition(fmt.Sprintf("Pinning service to %s", serving.GetName())))
 		if err := m.patchStatus(ctx, currentStatus); err != nil {
 			QueueOps.RequeueAPIErr(ctx, err)
-			return
+			return false
 		}
+		// the service picks up the pin on the next sync
+		QueueOps.Requeue(ctx)
+		return false
+	}
+	if active == target {
+		return true
 	}
+	return m.waitForService(ctx, currentStatus, active)
+}
 
-	m.next.Handle(ctx)
+// waitForService returns true once the cached Service only selects the pods
+// of the named Deployment. Otherwise the sync is requeued to check again.
+func (m *DeploymentHandler) waitForService(ctx context.Context, currentStatus *v1alpha1.SpiceDBCluster, deployment string) bool {
+	if s := m.getService(ctx); s != nil && s.Spec.Selector["app.kubernetes.io/instance"] == deployment {
+		return true
+	}
+	currentStatus.SetStatusCondition(v1alpha1.NewRollingCondition(fmt.Sprintf("Waiting for service to select %s", deployment)))
+	if err := m.patchStatus(ctx, currentStatus); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return false
+	}
+	QueueOps.RequeueAfter(ctx, time.Second)
+	return false
+}
+
+// cutover switches the Service of a blue/green cluster to the target
+// Deployment, which is already available, and then removes the other
+// Deployments once they've had time to drain. The drain starts when the
+// cached Service is seen selecting the target. It returns false if the sync
+// should stop here; the queue has already been updated in that case.
+func (m *DeploymentHandler) cutover(ctx context.Context, currentStatus *v1alpha1.SpiceDBCluster, rollout config.RolloutConfig, active *appsv1.Deployment) bool {
+	if bg := currentStatus.Status.BlueGreen; bg == nil || bg.ActiveDeployment != active.GetName() {
+		currentStatus.Status.BlueGreen = &v1alpha1.BlueGreenStatus{ActiveDeployment: active.GetName()}
+		currentStatus.SetStatusCondition(v1alpha1.NewRollingCondition(fmt.Sprintf("Switching service to %s", active.GetName())))
+		if err := m.patchStatus(ctx, currentStatus); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return false
+		}
+		// the service picks up the new deployment on the next sync
+		QueueOps.Requeue(ctx)
+		return false
+	}
+
+	if currentStatus.Status.BlueGreen.SwitchedAt == nil {
+		if !m.waitForService(ctx, currentStatus, active.GetName()) {
+			return false
+		}
+		now := metav1.NewTime(m.now())
+		currentStatus.Status.BlueGreen.SwitchedAt = &now
+		if err := m.patchStatus(ctx, currentStatus); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return false
+		}
+	}
+
+	inactive := make([]*appsv1.Deployment, 0)
+	for _, d := range CtxDeployments.MustValue(ctx) {
+		if d.GetName() != active.GetName() {
+			inactive = append(inactive, d)
+		}
+	}
+	if len(inactive) == 0 {
+		return true
+	}
+
+	if remaining := currentStatus.Status.BlueGreen.SwitchedAt.Add(rollout.DrainDuration).Sub(m.now()); remaining > 0 {
+		currentStatus.SetStatusCondition(v1alpha1.NewRollingCondition(fmt.Sprintf("Draining %d inactive deployment(s) before removing them", len(inactive))))
+		if err := m.patchStatus(ctx, currentStatus); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return false
+		}
+		QueueOps.RequeueAfter(ctx, remaining)
+		return false
+	}
+
+	for _, d := range inactive {
+		if err := m.deleteDeployment(ctx, types.NamespacedName{Namespace: currentStatus.Namespace, Name: d.GetName()}); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return false
+		}
+	}
+	return true
 }
@@ -14,6 +14,7 @@ import (
 	applyappsv1 "k8s.io/client-go/applyconfigurations/apps/v1"
 
 	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/hash"
 	"github.com/authzed/controller-idioms/queue/fake"
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
@@ -33,13 +34,17 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 		pods                []*corev1.Pod
 		currentStatus       *v1alpha1.SpiceDBCluster
 		replicas            int32
+		dispatchReplicas    int32
+		existingDispatch    []*appsv1.Deployment
 
 		expectNext         handler.Key
 		expectStatus       *v1alpha1.SpiceDBCluster
 		expectRequeueErr   error
 		expectRequeueAfter bool
 		expectApply        bool
+		expectApplyName    string
 		expectDelete       bool
+		expectDeleteName   string
 		expectPatchStatus  bool
 	}{
 		{
@@ -62,7 +67,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			migrationHash: "testtesttesttest",
 			secretHash:    "secret",
 			existingDeployments: []*appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-				metadata.SpiceDBConfigKey: "nbh55ch546h5d8h57fhc9h557h684q",
+				metadata.SpiceDBConfigKey: "nb8h66h86h659h559h685hbdhd5q",
 			}}}},
 			expectNext: nextKey,
 		},
@@ -71,7 +76,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			migrationHash: "testtesttesttest",
 			secretHash:    "secret",
 			existingDeployments: []*appsv1.Deployment{{}, {ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-				metadata.SpiceDBConfigKey: "nbh55ch546h5d8h57fhc9h557h684q",
+				metadata.SpiceDBConfigKey: "nb8h66h86h659h559h685hbdhd5q",
 			}}}},
 			expectDelete: true,
 			expectNext:   nextKey,
@@ -81,11 +86,24 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			migrationHash: "testtesttesttest",
 			secretHash:    "secret1",
 			existingDeployments: []*appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-				metadata.SpiceDBConfigKey: "nbh55ch546h5d8h57fhc9h557h684q",
+				metadata.SpiceDBConfigKey: "nb8h66h86h659h559h685hbdhd5q",
 			}}}},
 			expectApply:        true,
 			expectRequeueAfter: true,
 		},
+		{
+			name: "removes verified condition when applying a new deployment",
+			currentStatus: &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{{
+				Type:   v1alpha1.ConditionTypeVerified,
+				Status: metav1.ConditionTrue,
+			}}}},
+			migrationHash:      "testtesttesttest",
+			secretHash:         "secret",
+			expectApply:        true,
+			expectPatchStatus:  true,
+			expectStatus:       &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{}}},
+			expectRequeueAfter: true,
+		},
 		{
 			name: "removes migrating condition if present",
 			currentStatus: &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{{
@@ -116,7 +134,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			}}}},
 			existingDeployments: []*appsv1.Deployment{{
 				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-					metadata.SpiceDBConfigKey: "n598h54fh54fh65dh579h5b8h68ch5ccq",
+					metadata.SpiceDBConfigKey: "n59bh687hf6h58ch5f7h68bhb9h5q",
 				}},
 				Status: appsv1.DeploymentStatus{
 					Replicas:          2,
@@ -149,7 +167,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			}}}},
 			existingDeployments: []*appsv1.Deployment{{
 				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-					metadata.SpiceDBConfigKey: "n598h54fh54fh65dh579h5b8h68ch5ccq",
+					metadata.SpiceDBConfigKey: "n59bh687hf6h58ch5f7h68bhb9h5q",
 				}},
 				Status: appsv1.DeploymentStatus{
 					Replicas:          2,
@@ -176,7 +194,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			}}}},
 			existingDeployments: []*appsv1.Deployment{{
 				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-					metadata.SpiceDBConfigKey: "n598h54fh54fh65dh579h5b8h68ch5ccq",
+					metadata.SpiceDBConfigKey: "n59bh687hf6h58ch5f7h68bhb9h5q",
 				}},
 				Status: appsv1.DeploymentStatus{
 					Replicas:            2,
@@ -223,7 +241,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			}}}},
 			existingDeployments: []*appsv1.Deployment{{
 				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-					metadata.SpiceDBConfigKey: "n598h54fh54fh65dh579h5b8h68ch5ccq",
+					metadata.SpiceDBConfigKey: "n59bh687hf6h58ch5f7h68bhb9h5q",
 				}},
 				Status: appsv1.DeploymentStatus{
 					Replicas:            2,
@@ -291,7 +309,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			}}}},
 			existingDeployments: []*appsv1.Deployment{{
 				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-					metadata.SpiceDBConfigKey: "n598h54fh54fh65dh579h5b8h68ch5ccq",
+					metadata.SpiceDBConfigKey: "n59bh687hf6h58ch5f7h68bhb9h5q",
 				}},
 				Status: appsv1.DeploymentStatus{
 					Replicas:          2,
@@ -323,7 +341,7 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			}}},
 			existingDeployments: []*appsv1.Deployment{{
 				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-					metadata.SpiceDBConfigKey: "n598h54fh54fh65dh579h5b8h68ch5ccq",
+					metadata.SpiceDBConfigKey: "n59bh687hf6h58ch5f7h68bhb9h5q",
 				}},
 				Status: appsv1.DeploymentStatus{
 					Replicas:          2,
@@ -339,6 +357,47 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 			expectNext:        nextKey,
 			expectStatus:      &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{}}},
 		},
+		{
+			name:               "rolls the dispatch tier before the api tier",
+			migrationHash:      "testtesttesttest",
+			secretHash:         "secret",
+			dispatchReplicas:   1,
+			expectApply:        true,
+			expectApplyName:    "-spicedb-dispatch",
+			expectRequeueAfter: true,
+		},
+		{
+			name:             "waits for the dispatch tier to be available",
+			migrationHash:    "testtesttesttest",
+			secretHash:       "secret",
+			dispatchReplicas: 1,
+			existingDispatch: []*appsv1.Deployment{{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					metadata.SpiceDBConfigKey: "n54ch547hc4h7h5c9h547h688h59bq",
+				}},
+			}},
+			expectPatchStatus: true,
+			expectStatus: &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{{
+				Type:               v1alpha1.ConditionTypeRolling,
+				Status:             metav1.ConditionTrue,
+				LastTransitionTime: now,
+				Reason:             "WaitingForDeploymentAvailability",
+				Message:            "Waiting for dispatch deployment to be available: 0/1 available, 0/1 ready, 0/1 updated, 0/0 generation.",
+			}}}},
+			expectRequeueAfter: true,
+		},
+		{
+			name:          "removes the dispatch tier after the api tier stops using it",
+			migrationHash: "testtesttesttest",
+			secretHash:    "secret",
+			existingDeployments: []*appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				metadata.SpiceDBConfigKey: "nb8h66h86h659h559h685hbdhd5q",
+			}}}},
+			existingDispatch: []*appsv1.Deployment{{ObjectMeta: metav1.ObjectMeta{Name: "test-spicedb-dispatch"}}},
+			expectDelete:     true,
+			expectDeleteName: "test-spicedb-dispatch",
+			expectNext:       nextKey,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -356,25 +415,35 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 
 			ctx := CtxConfig.WithValue(context.Background(), &config.Config{
 				MigrationConfig: config.MigrationConfig{TargetSpiceDBImage: "test"},
-				SpiceConfig:     config.SpiceConfig{Replicas: tt.replicas},
+				SpiceConfig: config.SpiceConfig{
+					Replicas:     tt.replicas,
+					DispatchTier: config.DispatchTierConfig{Replicas: tt.dispatchReplicas},
+				},
 			})
 			ctx = QueueOps.WithValue(ctx, ctrls)
 			ctx = CtxCluster.WithValue(ctx, tt.currentStatus)
 			ctx = CtxMigrationHash.WithValue(ctx, tt.migrationHash)
 			ctx = CtxSecretHash.WithValue(ctx, tt.secretHash)
 			ctx = CtxDeployments.WithValue(ctx, tt.existingDeployments)
+			ctx = CtxDispatchDeployments.WithValue(ctx, tt.existingDispatch)
 
 			var called handler.Key
 			h := &DeploymentHandler{
-				applyDeployment: func(_ context.Context, _ *applyappsv1.DeploymentApplyConfiguration) (*appsv1.Deployment, error) {
+				applyDeployment: func(_ context.Context, dep *applyappsv1.DeploymentApplyConfiguration) (*appsv1.Deployment, error) {
 					applyCalled = true
+					if len(tt.expectApplyName) > 0 {
+						require.Equal(t, tt.expectApplyName, *dep.Name)
+					}
 					return nil, nil
 				},
-				deleteDeployment: func(_ context.Context, _ types.NamespacedName) error {
+				deleteDeployment: func(_ context.Context, nn types.NamespacedName) error {
 					deleteCalled = true
+					if len(tt.expectDeleteName) > 0 {
+						require.Equal(t, tt.expectDeleteName, nn.Name)
+					}
 					return nil
 				},
-				getDeploymentPods: func(_ context.Context) []*corev1.Pod {
+				getDeploymentPods: func(_ context.Context, _ string) []*corev1.Pod {
 					return tt.pods
 				},
 				patchStatus: func(_ context.Context, _ *v1alpha1.SpiceDBCluster) error {
@@ -404,3 +473,222 @@ func TestEnsureDeploymentHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureDeploymentBlueGreen(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+	cfg := &config.Config{
+		MigrationConfig: config.MigrationConfig{TargetSpiceDBImage: "image:v2"},
+		SpiceConfig: config.SpiceConfig{
+			Name:      "test",
+			Namespace: "test",
+			Replicas:  1,
+			Rollout: config.RolloutConfig{
+				Strategy:      config.RolloutStrategyBlueGreen,
+				DrainDuration: time.Minute,
+				Color:         "new123",
+			},
+		},
+	}
+	newHash := hash.Object(cfg.Deployment("migration", "secret"))
+	ready := appsv1.DeploymentStatus{AvailableReplicas: 1, ReadyReplicas: 1, UpdatedReplicas: 1}
+	rollingDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-spicedb", Annotations: map[string]string{metadata.SpiceDBConfigKey: "rolling"}},
+		Status:     ready,
+	}
+	oldDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-spicedb-old456", Annotations: map[string]string{metadata.SpiceDBConfigKey: "old"}},
+		Status:     ready,
+	}
+	newDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-spicedb-new123", Annotations: map[string]string{metadata.SpiceDBConfigKey: newHash}},
+		Status:     ready,
+	}
+	oldStatus := &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-old456", SwitchedAt: at(-time.Hour)}
+	switching := &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123"}
+
+	tests := []struct {
+		name                string
+		existingDeployments []*appsv1.Deployment
+		blueGreen           *v1alpha1.BlueGreenStatus
+		serviceSelects      string
+
+		expectApply        bool
+		expectDeleteName   string
+		expectBlueGreen    *v1alpha1.BlueGreenStatus
+		expectRequeue      bool
+		expectRequeueAfter time.Duration
+		expectNext         bool
+	}{
+		{
+			name:                "creates the new color next to the old one",
+			existingDeployments: []*appsv1.Deployment{oldDeployment},
+			blueGreen:           oldStatus,
+			serviceSelects:      "test-spicedb-old456",
+			expectApply:         true,
+			expectBlueGreen:     oldStatus,
+			expectRequeueAfter:  time.Second,
+		},
+		{
+			name:                "waits for the new color to be ready",
+			existingDeployments: []*appsv1.Deployment{oldDeployment, {ObjectMeta: newDeployment.ObjectMeta}},
+			blueGreen:           oldStatus,
+			serviceSelects:      "test-spicedb-old456",
+			expectBlueGreen:     oldStatus,
+			expectRequeueAfter:  2 * time.Second,
+		},
+		{
+			name:                "switches the service once the new color is ready",
+			existingDeployments: []*appsv1.Deployment{oldDeployment, newDeployment},
+			blueGreen:           oldStatus,
+			serviceSelects:      "test-spicedb-old456",
+			expectBlueGreen:     switching,
+			expectRequeue:       true,
+		},
+		{
+			name:                "doesn't drain until the service has switched",
+			existingDeployments: []*appsv1.Deployment{oldDeployment, newDeployment},
+			blueGreen:           switching,
+			serviceSelects:      "test-spicedb-old456",
+			expectBlueGreen:     switching,
+			expectRequeueAfter:  time.Second,
+		},
+		{
+			name:                "starts draining once the service has switched",
+			existingDeployments: []*appsv1.Deployment{oldDeployment, newDeployment},
+			blueGreen:           switching,
+			serviceSelects:      "test-spicedb-new123",
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(0)},
+			expectRequeueAfter:  time.Minute,
+		},
+		{
+			name:                "drains the old color",
+			existingDeployments: []*appsv1.Deployment{oldDeployment, newDeployment},
+			blueGreen:           &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-20 * time.Second)},
+			serviceSelects:      "test-spicedb-new123",
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-20 * time.Second)},
+			expectRequeueAfter:  40 * time.Second,
+		},
+		{
+			name:                "removes the old color after draining",
+			existingDeployments: []*appsv1.Deployment{oldDeployment, newDeployment},
+			blueGreen:           &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-time.Minute)},
+			serviceSelects:      "test-spicedb-new123",
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-time.Minute)},
+			expectDeleteName:    "test-spicedb-old456",
+			expectNext:          true,
+		},
+		{
+			name:                "first rollout creates the only color",
+			existingDeployments: []*appsv1.Deployment{},
+			expectApply:         true,
+			expectRequeueAfter:  time.Second,
+		},
+		{
+			name:                "first rollout only has one color",
+			existingDeployments: []*appsv1.Deployment{newDeployment},
+			blueGreen:           &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-time.Hour)},
+			serviceSelects:      "test-spicedb-new123",
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-time.Hour)},
+			expectNext:          true,
+		},
+		{
+			name:                "switching from rolling with a new image pins the service to the rolling deployment",
+			existingDeployments: []*appsv1.Deployment{rollingDeployment},
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb"},
+			expectRequeue:       true,
+		},
+		{
+			name:                "switching from rolling waits for the service to be pinned",
+			existingDeployments: []*appsv1.Deployment{rollingDeployment},
+			blueGreen:           &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb"},
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb"},
+			expectRequeueAfter:  time.Second,
+		},
+		{
+			name:                "switching from rolling creates the new color once the service is pinned",
+			existingDeployments: []*appsv1.Deployment{rollingDeployment},
+			blueGreen:           &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb"},
+			serviceSelects:      "test-spicedb",
+			expectApply:         true,
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb"},
+			expectRequeueAfter:  time.Second,
+		},
+		{
+			name:                "switching from rolling removes the rolling deployment after draining",
+			existingDeployments: []*appsv1.Deployment{rollingDeployment, newDeployment},
+			blueGreen:           &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-time.Minute)},
+			serviceSelects:      "test-spicedb-new123",
+			expectBlueGreen:     &v1alpha1.BlueGreenStatus{ActiveDeployment: "test-spicedb-new123", SwitchedAt: at(-time.Minute)},
+			expectDeleteName:    "test-spicedb",
+			expectNext:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrls := &fake.FakeInterface{}
+			cluster := &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{BlueGreen: tt.blueGreen.DeepCopy()}}
+
+			// the config's active deployment comes from the status
+			c := *cfg
+			if tt.blueGreen != nil {
+				c.Rollout.ActiveDeployment = tt.blueGreen.ActiveDeployment
+			}
+			service := &corev1.Service{Spec: corev1.ServiceSpec{Selector: metadata.LabelsForComponent("test", metadata.ComponentSpiceDBLabelValue)}}
+			if tt.serviceSelects != "" {
+				service.Spec.Selector["app.kubernetes.io/instance"] = tt.serviceSelects
+			}
+
+			ctx := CtxConfig.WithValue(context.Background(), &c)
+			ctx = QueueOps.WithValue(ctx, ctrls)
+			ctx = CtxCluster.WithValue(ctx, cluster)
+			ctx = CtxMigrationHash.WithValue(ctx, "migration")
+			ctx = CtxSecretHash.WithValue(ctx, "secret")
+			ctx = CtxDeployments.WithValue(ctx, tt.existingDeployments)
+			ctx = CtxDispatchDeployments.WithValue(ctx, []*appsv1.Deployment{})
+
+			applyCalled := false
+			var deleted []string
+			nextCalled := false
+			h := &DeploymentHandler{
+				applyDeployment: func(_ context.Context, dep *applyappsv1.DeploymentApplyConfiguration) (*appsv1.Deployment, error) {
+					applyCalled = true
+					require.Equal(t, "test-spicedb-new123", *dep.Name)
+					return nil, nil
+				},
+				deleteDeployment: func(_ context.Context, nn types.NamespacedName) error {
+					deleted = append(deleted, nn.Name)
+					return nil
+				},
+				getDeploymentPods: func(_ context.Context, _ string) []*corev1.Pod { return nil },
+				getService:        func(_ context.Context) *corev1.Service { return service },
+				patchStatus:       func(_ context.Context, _ *v1alpha1.SpiceDBCluster) error { return nil },
+				now:               func() time.Time { return now },
+				next: handler.ContextHandlerFunc(func(_ context.Context) {
+					nextCalled = true
+				}),
+			}
+			h.Handle(ctx)
+
+			require.Equal(t, tt.expectApply, applyCalled)
+			if tt.expectDeleteName != "" {
+				require.Equal(t, []string{tt.expectDeleteName}, deleted)
+			} else {
+				require.Empty(t, deleted)
+			}
+			require.True(t, tt.expectBlueGreen.Equals(cluster.Status.BlueGreen), "got %v", cluster.Status.BlueGreen)
+			require.Equal(t, tt.expectRequeue, ctrls.RequeueCallCount() == 1)
+			if tt.expectRequeueAfter > 0 {
+				require.Equal(t, 1, ctrls.RequeueAfterCallCount())
+				after := ctrls.RequeueAfterArgsForCall(0)
+				require.Equal(t, tt.expectRequeueAfter, after)
+			} else {
+				require.Zero(t, ctrls.RequeueAfterCallCount())
+			}
+			require.Equal(t, tt.expectNext, nextCalled)
+		})
+	}
+}
@@ -0,0 +1,437 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jzelinskie/stringz"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	applyappsv1 "k8s.io/client-go/applyconfigurations/apps/v1"
+	applybatchv1 "k8s.io/client-go/applyconfigurations/batch/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/queue/fake"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+	"github.com/authzed/spicedb-operator/pkg/metadata"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+var (
+	proposedGraphFile  = stringz.DefaultEmpty(os.Getenv("PROPOSED_GRAPH_FILE"), "../../proposed-update-graph.yaml")
+	validatedGraphFile = stringz.DefaultEmpty(os.Getenv("VALIDATED_GRAPH_FILE"), "../../validated-update-graph.yaml")
+)
+
+// TestNewGraphEdges simulates an update across every edge of the proposed
+// update graph that isn't in the validated graph yet. It catches broken
+// edges before the e2e suite spends time on them in a real cluster.
+func TestNewGraphEdges(t *testing.T) {
+	proposed := loadOperatorConfig(t, proposedGraphFile)
+	validated := loadOperatorConfig(t, validatedGraphFile)
+
+	for _, c := range proposed.UpdateGraph.Difference(&validated.UpdateGraph).Channels {
+		engine := c.Metadata[updates.DatastoreMetadataKey]
+		for from, targets := range c.Edges {
+			for _, to := range targets {
+				t.Run(fmt.Sprintf("%s/%s/%s->%s", engine, c.Name, from, to), func(t *testing.T) {
+					require.NoError(t, simulateUpdate(proposed, engine, c.Name, from, to))
+				})
+			}
+		}
+	}
+}
+
+func TestSimulateUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		engine      string
+		channel     updates.Channel
+		from, to    string
+		expectedErr string
+	}{
+		{
+			name:   "update without migrations",
+			engine: "memory",
+			channel: updates.Channel{
+				Edges: updates.EdgeSet{"v1.0.0": {"v1.1.0"}},
+				Nodes: []updates.State{
+					{ID: "v1.1.0", Tag: "v1.1.0"},
+					{ID: "v1.0.0", Tag: "v1.0.0"},
+				},
+			},
+			from: "v1.0.0",
+			to:   "v1.1.0",
+		},
+		{
+			name:   "update through phased migrations",
+			engine: "postgres",
+			channel: updates.Channel{
+				Edges: updates.EdgeSet{
+					"v1.0.0":        {"v1.1.0-phase1"},
+					"v1.1.0-phase1": {"v1.1.0"},
+				},
+				Nodes: []updates.State{
+					{ID: "v1.1.0", Tag: "v1.1.0", Migration: "b", Phase: "two"},
+					{ID: "v1.1.0-phase1", Tag: "v1.1.0", Migration: "b", Phase: "one"},
+					{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+				},
+			},
+			from: "v1.0.0",
+			to:   "v1.1.0",
+		},
+		{
+			name:   "edge to a version without an image",
+			engine: "postgres",
+			channel: updates.Channel{
+				Edges: updates.EdgeSet{"v1.0.0": {"v1.1.0"}},
+				Nodes: []updates.State{
+					{ID: "v1.1.0", Migration: "a"},
+					{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+				},
+			},
+			from:        "v1.0.0",
+			to:          "v1.1.0",
+			expectedErr: `updating to v1.1.0: invalid config: Error validating config with secret hash "secret": no update found in channel`,
+		},
+		{
+			name:   "version that can't be reached",
+			engine: "postgres",
+			channel: updates.Channel{
+				Edges: updates.EdgeSet{"v1.0.0": {"v1.2.0"}, "v1.1.0": {"v1.2.0"}},
+				Nodes: []updates.State{
+					{ID: "v1.2.0", Tag: "v1.2.0", Migration: "a"},
+					{ID: "v1.1.0", Tag: "v1.1.0", Migration: "a"},
+					{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+				},
+			},
+			from:        "v1.0.0",
+			to:          "v1.1.0",
+			expectedErr: `updating to v1.1.0: invalid config: Error validating config with secret hash "secret": [can't roll back from v1.0.0 to v1.1.0`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.channel.Name = "stable"
+			tt.channel.Metadata = map[string]string{updates.DatastoreMetadataKey: tt.engine, "default": "true"}
+			opconfig := &config.OperatorConfig{
+				ImageName:   "spicedb",
+				UpdateGraph: updates.UpdateGraph{Channels: []updates.Channel{tt.channel}},
+			}
+			err := simulateUpdate(opconfig, tt.engine, "stable", tt.from, tt.to)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func loadOperatorConfig(t *testing.T, path string) *config.OperatorConfig {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var cfg config.OperatorConfig
+	require.NoError(t, yaml.NewYAMLOrJSONDecoder(bytes.NewReader(contents), 100).Decode(&cfg))
+	return &cfg
+}
+
+// simulateUpdate installs `from` in a simulated cluster and then updates it
+// to `to`, checking every target that the controller picks on the way.
+func simulateUpdate(opconfig *config.OperatorConfig, engine, channel, from, to string) error {
+	sim, err := newUpdateSimulator(opconfig, engine, channel)
+	if err != nil {
+		return err
+	}
+	if err := sim.updateTo(from); err != nil {
+		return fmt.Errorf("installing %s: %w", from, err)
+	}
+	if err := sim.updateTo(to); err != nil {
+		return fmt.Errorf("updating to %s: %w", to, err)
+	}
+	return nil
+}
+
+// updateSimulator runs the handlers that move a cluster from one version to
+// the next (config validation, migrations and the deployment) against an
+// in-memory stand-in for the api server, where jobs complete and
+// deployments become available as soon as they're applied.
+type updateSimulator struct {
+	opconfig *config.OperatorConfig
+	engine   string
+	channel  updates.Channel
+
+	cluster     *v1alpha1.SpiceDBCluster
+	secret      *corev1.Secret
+	jobs        []*batchv1.Job
+	deployments []*appsv1.Deployment
+
+	// config is the validated config from the last reconcile
+	config *config.Config
+	// deployed is the state that the deployment is running
+	deployed *updates.State
+	// migrated holds the migration hashes of the jobs that have run
+	migrated map[string]struct{}
+	// err is set by the fake clients if they see an inconsistent update
+	err error
+}
+
+func newUpdateSimulator(opconfig *config.OperatorConfig, engine, channel string) (*updateSimulator, error) {
+	idx := slices.IndexFunc(opconfig.Channels, func(c updates.Channel) bool {
+		return c.Name == channel && c.Metadata[updates.DatastoreMetadataKey] == engine
+	})
+	if idx < 0 {
+		return nil, fmt.Errorf("no channel %s for %s", channel, engine)
+	}
+	clusterConfig, err := json.Marshal(map[string]any{"datastoreEngine": engine})
+	if err != nil {
+		return nil, err
+	}
+	return &updateSimulator{
+		opconfig: opconfig,
+		engine:   engine,
+		channel:  opconfig.Channels[idx],
+		cluster: &v1alpha1.SpiceDBCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test"},
+			Spec: v1alpha1.ClusterSpec{
+				Channel: channel,
+				Config:  clusterConfig,
+			},
+		},
+		secret: &corev1.Secret{Data: map[string][]byte{
+			"datastore_uri": []byte("uri"),
+			"preshared_key": []byte("testtest"),
+		}},
+		migrated: make(map[string]struct{}),
+	}, nil
+}
+
+// updateTo sets the cluster's version and reconciles until it's running
+// that version. It fails if the controller picks a target that isn't an
+// edge from the running version, revisits a version or stops making
+// progress.
+func (s *updateSimulator) updateTo(version string) error {
+	s.cluster.Spec.Version = version
+
+	visited := make(map[string]struct{})
+	if current := s.cluster.Status.CurrentVersion; current != nil {
+		visited[current.Name] = struct{}{}
+	}
+	steadyAt := ""
+	maxReconciles := 10 * (len(s.channel.Nodes) + 1)
+	for i := 0; i < maxReconciles; i++ {
+		previous := s.cluster.Status.CurrentVersion.DeepCopy()
+		steady, err := s.reconcile()
+		if err != nil {
+			return err
+		}
+
+		current := s.cluster.Status.CurrentVersion
+		if current == nil {
+			return fmt.Errorf("no version was picked")
+		}
+		if previous == nil || previous.Name != current.Name {
+			if err := s.checkTarget(previous, current); err != nil {
+				return err
+			}
+			if _, ok := visited[current.Name]; ok {
+				return fmt.Errorf("update loops back to %s", current.Name)
+			}
+			visited[current.Name] = struct{}{}
+			steadyAt = ""
+			continue
+		}
+		if !steady {
+			continue
+		}
+		if current.Name == version {
+			return nil
+		}
+		// the target is computed before the rollout finishes, so it's only
+		// stuck if it stays put once the cluster has settled
+		if steadyAt == current.Name {
+			return fmt.Errorf("stuck at %s", current.Name)
+		}
+		steadyAt = current.Name
+	}
+	return fmt.Errorf("didn't reach %s after %d reconciles", version, maxReconciles)
+}
+
+// checkTarget verifies that the controller is running the image and
+// migration of the version it picked, and that the version can be reached
+// from the previous one.
+func (s *updateSimulator) checkTarget(previous, target *v1alpha1.SpiceDBVersion) error {
+	state, ok := s.opconfig.VersionState(s.engine, *target)
+	if !ok {
+		return fmt.Errorf("picked %s, which isn't in channel %s", target.Name, target.Channel)
+	}
+	if image := s.opconfig.ImageName + ":" + state.Tag; len(state.Digest) == 0 && s.config.TargetSpiceDBImage != image {
+		return fmt.Errorf("picked %s, but the target image is %s instead of %s", target.Name, s.config.TargetSpiceDBImage, image)
+	}
+	if migration := stringz.DefaultEmpty(state.Migration, config.Head); s.config.TargetMigration != migration || s.config.TargetPhase != state.Phase {
+		return fmt.Errorf("picked %s, but the target migration is %s (%s) instead of %s (%s)", target.Name, s.config.TargetMigration, s.config.TargetPhase, migration, state.Phase)
+	}
+	if previous == nil {
+		return nil
+	}
+	if !slices.Contains(s.channel.Edges[previous.Name], target.Name) && !slices.ContainsFunc(s.channel.Rollbacks[previous.Name], func(r updates.Rollback) bool {
+		return r.To == target.Name
+	}) {
+		return fmt.Errorf("picked %s, but there's no edge to it from %s", target.Name, previous.Name)
+	}
+	return nil
+}
+
+// reconcile runs one sync of the cluster. It returns true if the sync got
+// all the way through the deployment, i.e. the cluster has settled.
+func (s *updateSimulator) reconcile() (steady bool, err error) {
+	queue := &fake.FakeInterface{}
+	recorder := &record.FakeRecorder{}
+	patchStatus := func(_ context.Context, patch *v1alpha1.SpiceDBCluster) error {
+		s.cluster.Status = *patch.Status.DeepCopy()
+		return nil
+	}
+	var paused *v1alpha1.SpiceDBCluster
+	selfPause := handler.ContextHandlerFunc(func(ctx context.Context) {
+		paused = CtxSelfPauseObject.MustValue(ctx)
+	})
+
+	deployment := &DeploymentHandler{
+		applyDeployment:   s.applyDeployment,
+		deleteDeployment:  s.deleteDeployment,
+		getDeploymentPods: func(_ context.Context, _ string) []*corev1.Pod { return nil },
+		patchStatus:       patchStatus,
+		now:               time.Now,
+		next: handler.ContextHandlerFunc(func(_ context.Context) {
+			steady = true
+		}),
+	}
+	waitForMigrations := &WaitForMigrationsHandler{
+		recorder:              recorder,
+		now:                   time.Now,
+		getJobPods:            func(_ context.Context) []*corev1.Pod { return nil },
+		nextSelfPause:         selfPause,
+		nextDeploymentHandler: deployment,
+	}
+	checkMigrations := &MigrationCheckHandler{
+		recorder: recorder,
+		nextMigrationRunHandler: &MigrationRunHandler{
+			patchStatus: patchStatus,
+			applyJob:    s.applyJob,
+			deleteJob:   s.deleteJob,
+			next:        waitForMigrations,
+		},
+		nextWaitForJobHandler: waitForMigrations,
+		nextDeploymentHandler: deployment,
+	}
+	validate := &ValidateConfigHandler{
+		recorder:    recorder,
+		patchStatus: patchStatus,
+		now:         time.Now,
+		next: handler.ContextHandlerFunc(func(ctx context.Context) {
+			s.config = CtxConfig.MustValue(ctx)
+			checkMigrations.Handle(ctx)
+		}),
+	}
+
+	ctx := QueueOps.WithValue(context.Background(), queue)
+	ctx = CtxClusterNN.WithValue(ctx, types.NamespacedName{Namespace: s.cluster.Namespace, Name: s.cluster.Name})
+	ctx = CtxCluster.WithValue(ctx, s.cluster.DeepCopy())
+	ctx = CtxSecret.WithValue(ctx, s.secret)
+	ctx = CtxSecretHash.WithValue(ctx, "secret")
+	ctx = CtxOperatorConfig.WithValue(ctx, s.opconfig)
+	ctx = CtxJobs.WithValue(ctx, slices.Clone(s.jobs))
+	ctx = CtxDeployments.WithValue(ctx, slices.Clone(s.deployments))
+	ctx = CtxDispatchDeployments.WithValue(ctx, []*appsv1.Deployment{})
+	validate.Handle(ctx)
+
+	switch {
+	case s.err != nil:
+		return false, s.err
+	case paused != nil:
+		return false, fmt.Errorf("cluster paused itself: %v", paused.Status.Conditions)
+	case queue.RequeueErrCallCount() > 0:
+		return false, queue.RequeueErrArgsForCall(0)
+	case queue.RequeueAPIErrCallCount() > 0:
+		return false, queue.RequeueAPIErrArgsForCall(0)
+	case queue.DoneCallCount() > 0 && !steady:
+		if c := s.cluster.FindStatusCondition(v1alpha1.ConditionValidatingFailed); c != nil {
+			return false, fmt.Errorf("invalid config: %s", c.Message)
+		}
+		return false, fmt.Errorf("sync stopped before the deployment")
+	}
+	return steady, nil
+}
+
+// applyJob stores the job as already completed.
+func (s *updateSimulator) applyJob(_ context.Context, job *applybatchv1.JobApplyConfiguration) error {
+	s.jobs = append(s.jobs, &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: *job.Namespace, Name: *job.Name, Annotations: job.Annotations},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+			Type:   batchv1.JobComplete,
+			Status: corev1.ConditionTrue,
+		}}},
+	})
+	s.migrated[job.Annotations[metadata.SpiceDBMigrationRequirementsKey]] = struct{}{}
+	return nil
+}
+
+func (s *updateSimulator) deleteJob(_ context.Context, nn types.NamespacedName) error {
+	s.jobs = slices.DeleteFunc(s.jobs, func(j *batchv1.Job) bool {
+		return j.Namespace == nn.Namespace && j.Name == nn.Name
+	})
+	return nil
+}
+
+// applyDeployment stores the deployment as already available. It records an
+// error if the deployment would run a version whose migrations haven't run.
+func (s *updateSimulator) applyDeployment(_ context.Context, dep *applyappsv1.DeploymentApplyConfiguration) (*appsv1.Deployment, error) {
+	state, ok := s.opconfig.VersionState(s.engine, *s.config.SpiceDBVersion)
+	if !ok {
+		s.err = fmt.Errorf("deployed %s, which isn't in channel %s", s.config.SpiceDBVersion.Name, s.config.SpiceDBVersion.Channel)
+		return nil, s.err
+	}
+	migrationHash := dep.Annotations[metadata.SpiceDBMigrationRequirementsKey]
+	needsMigration := s.deployed == nil || s.deployed.Migration != state.Migration || s.deployed.Phase != state.Phase
+	if _, migrated := s.migrated[migrationHash]; needsMigration && !migrated && s.engine != "memory" {
+		s.err = fmt.Errorf("deployed %s before running migration %s", state.ID, stringz.DefaultEmpty(state.Migration, config.Head))
+		return nil, s.err
+	}
+	s.deployed = &state
+
+	replicas := *dep.Spec.Replicas
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: *dep.Namespace, Name: *dep.Name, Annotations: dep.Annotations},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          replicas,
+			AvailableReplicas: replicas,
+			ReadyReplicas:     replicas,
+			UpdatedReplicas:   replicas,
+		},
+	}
+	s.deployments = append(slices.DeleteFunc(s.deployments, func(d *appsv1.Deployment) bool {
+		return d.Name == deployment.Name
+	}), deployment)
+	return deployment, nil
+}
+
+func (s *updateSimulator) deleteDeployment(_ context.Context, nn types.NamespacedName) error {
+	s.deployments = slices.DeleteFunc(s.deployments, func(d *appsv1.Deployment) bool {
+		return d.Namespace == nn.Namespace && d.Name == nn.Name
+	})
+	return nil
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	applybatchv1 "k8s.io/client-go/applyconfigurations/batch/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/handler"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+)
+
+const (
+	EventDatastoreProvisioned       = "DatastoreProvisioned"
+	EventDatastoreProvisioningError = "DatastoreProvisioningFailed"
+)
+
+// DatastoreProvisioningHandler creates the database and user for a cluster
+// before anything is migrated. The generated credentials are written to the
+// cluster's secret first, so that the provisioning job and the datastore_uri
+// used by spicedb always agree.
+type DatastoreProvisioningHandler struct {
+	recorder    record.EventRecorder
+	getAdminURI func(ctx context.Context, nn types.NamespacedName) (string, error)
+	applySecret func(ctx context.Context, secret *applycorev1.SecretApplyConfiguration) error
+	getJob      func(ctx context.Context, nn types.NamespacedName) (*batchv1.Job, error)
+	applyJob    func(ctx context.Context, job *applybatchv1.JobApplyConfiguration) error
+	deleteJob   func(ctx context.Context, nn types.NamespacedName) error
+	patchStatus func(ctx context.Context, patch *v1alpha1.SpiceDBCluster) error
+	next        handler.ContextHandler
+}
+
+func (h *DatastoreProvisioningHandler) Handle(ctx context.Context) {
+	cfg := CtxConfig.MustValue(ctx)
+	cluster := CtxCluster.MustValue(ctx)
+	secret := CtxSecret.MustValue(ctx)
+
+	if !cfg.DatastoreProvisioning.Enabled() || cluster.IsStatusConditionTrue(v1alpha1.ConditionTypeDatastoreProvisioned) {
+		h.next.Handle(ctx)
+		return
+	}
+
+	// a datastore_uri that the operator didn't generate is left alone
+	if len(secret.Data["datastore_uri"]) > 0 && len(secret.Data[config.ProvisionedPasswordKey]) == 0 {
+		h.next.Handle(ctx)
+		return
+	}
+
+	if len(secret.Data["datastore_uri"]) == 0 || len(secret.Data[config.ProvisionedPasswordKey]) == 0 {
+		if err := h.writeCredentials(ctx, cfg, secret); err != nil {
+			h.fail(ctx, cluster, cfg, err)
+			return
+		}
+		if err := h.setProvisioning(ctx, cluster, cfg.DatastoreEngine); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+		// the secret update will be picked up on the next sync
+		QueueOps.RequeueAfter(ctx, time.Second)
+		return
+	}
+
+	jobNN := types.NamespacedName{Name: config.ProvisioningJobName(cluster.Name), Namespace: cluster.Namespace}
+	job, err := h.getJob(ctx, jobNN)
+	if apierrors.IsNotFound(err) {
+		if err := h.applyJob(ctx, cfg.ProvisioningJob()); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+		if err := h.setProvisioning(ctx, cluster, cfg.DatastoreEngine); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+		QueueOps.RequeueAfter(ctx, 5*time.Second)
+		return
+	}
+	if err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+
+	if c := findJobCondition(job, batchv1.JobFailed); c != nil && c.Status == corev1.ConditionTrue {
+		// remove the job so that the next attempt starts fresh
+		if err := h.deleteJob(ctx, jobNN); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+		h.fail(ctx, cluster, cfg, fmt.Errorf("provisioning job failed: %s", c.Message))
+		return
+	}
+
+	if !jobConditionHasStatus(job, batchv1.JobComplete, corev1.ConditionTrue) {
+		QueueOps.RequeueAfter(ctx, 5*time.Second)
+		return
+	}
+
+	cluster.SetStatusCondition(v1alpha1.NewDatastoreProvisionedCondition(cfg.DatastoreEngine))
+	if err := h.patchStatus(ctx, cluster); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	h.recorder.Eventf(cluster, corev1.EventTypeNormal, EventDatastoreProvisioned, "Provisioned database %q and user %q", cfg.DatastoreProvisioning.Database, cfg.DatastoreProvisioning.User)
+	if err := h.deleteJob(ctx, jobNN); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+
+	ctx = CtxCluster.WithValue(ctx, cluster)
+	h.next.Handle(ctx)
+}
+
+// writeCredentials generates a password for the provisioned user (unless one
+// exists) and stores it in the cluster's secret alongside the datastore_uri.
+func (h *DatastoreProvisioningHandler) writeCredentials(ctx context.Context, cfg *config.Config, secret *corev1.Secret) error {
+	password := string(secret.Data[config.ProvisionedPasswordKey])
+	if len(password) == 0 {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = hex.EncodeToString(buf)
+	}
+
+	adminURI, err := h.getAdminURI(ctx, types.NamespacedName{Name: cfg.DatastoreProvisioning.AdminSecretName, Namespace: cfg.Namespace})
+	if err != nil {
+		return err
+	}
+	uri, err := config.ProvisionedDatastoreURI(cfg.DatastoreEngine, adminURI, cfg.DatastoreProvisioning, password)
+	if err != nil {
+		return err
+	}
+
+	return h.applySecret(ctx, applycorev1.Secret(secret.Name, secret.Namespace).WithData(map[string][]byte{
+		"datastore_uri":               []byte(uri),
+		config.ProvisionedPasswordKey: []byte(password),
+	}))
+}
+
+// setProvisioning marks the cluster as provisioning, if it isn't already.
+func (h *DatastoreProvisioningHandler) setProvisioning(ctx context.Context, cluster *v1alpha1.SpiceDBCluster, engine string) error {
+	if existing := cluster.FindStatusCondition(v1alpha1.ConditionTypeDatastoreProvisioned); existing != nil && existing.Reason == v1alpha1.ConditionReasonProvisioning {
+		return nil
+	}
+	cluster.SetStatusCondition(v1alpha1.NewDatastoreProvisioningCondition(engine))
+	return h.patchStatus(ctx, cluster)
+}
+
+func (h *DatastoreProvisioningHandler) fail(ctx context.Context, cluster *v1alpha1.SpiceDBCluster, cfg *config.Config, err error) {
+	cluster.SetStatusCondition(v1alpha1.NewDatastoreProvisioningFailedCondition(cfg.DatastoreEngine, err))
+	if err := h.patchStatus(ctx, cluster); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	h.recorder.Eventf(cluster, corev1.EventTypeWarning, EventDatastoreProvisioningError, "Error provisioning datastore: %v", err)
+	QueueOps.RequeueAfter(ctx, time.Minute)
+}
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	applybatchv1 "k8s.io/client-go/applyconfigurations/batch/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/queue/fake"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+)
+
+func TestDatastoreProvisioningHandler(t *testing.T) {
+	var nextKey handler.Key = "next"
+	provisioning := config.DatastoreProvisioningConfig{
+		AdminSecretName: "admin",
+		Image:           "postgres:16-alpine",
+		Database:        "spicedb_test",
+		User:            "spicedb_test",
+	}
+	provisionedSecret := map[string][]byte{
+		"datastore_uri":               []byte("postgresql://spicedb_test:pw@db:5432/spicedb_test"),
+		config.ProvisionedPasswordKey: []byte("pw"),
+	}
+
+	tests := []struct {
+		name string
+
+		provisioning config.DatastoreProvisioningConfig
+		conditions   []metav1.Condition
+		secretData   map[string][]byte
+		job          *batchv1.Job
+
+		expectSecretURI    string
+		expectApplyJob     bool
+		expectDeleteJob    bool
+		expectReason       string
+		expectNext         handler.Key
+		expectRequeueAfter time.Duration
+		expectEvents       []string
+	}{
+		{
+			name:       "provisioning disabled",
+			expectNext: nextKey,
+		},
+		{
+			name:         "already provisioned",
+			provisioning: provisioning,
+			conditions:   []metav1.Condition{v1alpha1.NewDatastoreProvisionedCondition("postgres")},
+			expectReason: v1alpha1.ConditionReasonProvisioned,
+			expectNext:   nextKey,
+		},
+		{
+			name:         "user provided datastore_uri",
+			provisioning: provisioning,
+			secretData:   map[string][]byte{"datastore_uri": []byte("uri")},
+			expectNext:   nextKey,
+		},
+		{
+			name:               "writes credentials to the secret",
+			provisioning:       provisioning,
+			expectSecretURI:    "postgresql://spicedb_test:<password>@db:5432/spicedb_test?sslmode=disable",
+			expectReason:       v1alpha1.ConditionReasonProvisioning,
+			expectRequeueAfter: time.Second,
+		},
+		{
+			name:               "creates the job",
+			provisioning:       provisioning,
+			secretData:         provisionedSecret,
+			expectApplyJob:     true,
+			expectReason:       v1alpha1.ConditionReasonProvisioning,
+			expectRequeueAfter: 5 * time.Second,
+		},
+		{
+			name:               "waits for the job",
+			provisioning:       provisioning,
+			conditions:         []metav1.Condition{v1alpha1.NewDatastoreProvisioningCondition("postgres")},
+			secretData:         provisionedSecret,
+			job:                &batchv1.Job{},
+			expectReason:       v1alpha1.ConditionReasonProvisioning,
+			expectRequeueAfter: 5 * time.Second,
+		},
+		{
+			name:         "job failed",
+			provisioning: provisioning,
+			secretData:   provisionedSecret,
+			job: &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:    batchv1.JobFailed,
+				Status:  corev1.ConditionTrue,
+				Message: "BackoffLimitExceeded",
+			}}}},
+			expectDeleteJob:    true,
+			expectReason:       v1alpha1.ConditionReasonProvisioningFailed,
+			expectRequeueAfter: time.Minute,
+			expectEvents:       []string{"Warning DatastoreProvisioningFailed Error provisioning datastore: provisioning job failed: BackoffLimitExceeded"},
+		},
+		{
+			name:         "job complete",
+			provisioning: provisioning,
+			secretData:   provisionedSecret,
+			job: &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:   batchv1.JobComplete,
+				Status: corev1.ConditionTrue,
+			}}}},
+			expectDeleteJob: true,
+			expectReason:    v1alpha1.ConditionReasonProvisioned,
+			expectEvents:    []string{`Normal DatastoreProvisioned Provisioned database "spicedb_test" and user "spicedb_test"`},
+			expectNext:      nextKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrls := &fake.FakeInterface{}
+			cluster := &v1alpha1.SpiceDBCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+				Status:     v1alpha1.ClusterStatus{Conditions: tt.conditions},
+			}
+			cfg := &config.Config{
+				MigrationConfig: config.MigrationConfig{DatastoreEngine: "postgres"},
+				SpiceConfig: config.SpiceConfig{
+					Name:                  "test",
+					Namespace:             "test",
+					SecretName:            "test",
+					DatastoreProvisioning: tt.provisioning,
+				},
+			}
+			ctx := QueueOps.WithValue(context.Background(), ctrls)
+			ctx = CtxCluster.WithValue(ctx, cluster)
+			ctx = CtxConfig.WithValue(ctx, cfg)
+			ctx = CtxSecret.WithValue(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+				Data:       tt.secretData,
+			})
+
+			recorder := record.NewFakeRecorder(1)
+			var appliedSecret *applycorev1.SecretApplyConfiguration
+			appliedJob := false
+			deletedJob := false
+			var called handler.Key
+			h := &DatastoreProvisioningHandler{
+				recorder: recorder,
+				getAdminURI: func(_ context.Context, nn types.NamespacedName) (string, error) {
+					require.Equal(t, "admin", nn.Name)
+					return "postgresql://postgres:admin@db:5432/postgres?sslmode=disable", nil
+				},
+				applySecret: func(_ context.Context, secret *applycorev1.SecretApplyConfiguration) error {
+					appliedSecret = secret
+					return nil
+				},
+				getJob: func(_ context.Context, nn types.NamespacedName) (*batchv1.Job, error) {
+					require.Equal(t, "test-provision-datastore", nn.Name)
+					if tt.job == nil {
+						return nil, apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+					}
+					return tt.job, nil
+				},
+				applyJob: func(_ context.Context, _ *applybatchv1.JobApplyConfiguration) error {
+					appliedJob = true
+					return nil
+				},
+				deleteJob: func(_ context.Context, _ types.NamespacedName) error {
+					deletedJob = true
+					return nil
+				},
+				patchStatus: func(_ context.Context, _ *v1alpha1.SpiceDBCluster) error {
+					return nil
+				},
+				next: handler.ContextHandlerFunc(func(_ context.Context) {
+					called = nextKey
+				}),
+			}
+			h.Handle(ctx)
+
+			if len(tt.expectSecretURI) > 0 {
+				require.NotNil(t, appliedSecret)
+				password := string(appliedSecret.Data[config.ProvisionedPasswordKey])
+				require.Len(t, password, 48)
+				require.Equal(t, strings.ReplaceAll(tt.expectSecretURI, "<password>", password), string(appliedSecret.Data["datastore_uri"]))
+			} else {
+				require.Nil(t, appliedSecret)
+			}
+			require.Equal(t, tt.expectApplyJob, appliedJob)
+			require.Equal(t, tt.expectDeleteJob, deletedJob)
+			require.Equal(t, tt.expectNext, called)
+			ExpectEvents(t, recorder, tt.expectEvents)
+
+			condition := cluster.FindStatusCondition(v1alpha1.ConditionTypeDatastoreProvisioned)
+			if len(tt.expectReason) == 0 {
+				require.Nil(t, condition)
+			} else {
+				require.NotNil(t, condition)
+				require.Equal(t, tt.expectReason, condition.Reason)
+			}
+
+			if tt.expectRequeueAfter != 0 {
+				require.Equal(t, 1, ctrls.RequeueAfterCallCount())
+				require.Equal(t, tt.expectRequeueAfter, ctrls.RequeueAfterArgsForCall(0))
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/hash"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+	"github.com/authzed/spicedb-operator/pkg/spicedb"
+)
+
+const (
+	EventSchemaApplied     = "SchemaApplied"
+	EventSchemaApplyFailed = "SchemaApplyFailed"
+
+	DefaultSchemaKey        = "schema"
+	DefaultRelationshipsKey = "relationships"
+)
+
+// SchemaBootstrapHandler writes the schema and relationships from
+// `spec.schema` to a cluster that has finished rolling out. The hash of the
+// last written input is stored in the status so that it is only re-applied
+// when it changes.
+type SchemaBootstrapHandler struct {
+	recorder    record.EventRecorder
+	writeSchema func(ctx context.Context, config *config.Config, schema string, rels []*v1.Relationship) error
+	patchStatus func(ctx context.Context, patch *v1alpha1.SpiceDBCluster) error
+	next        handler.ContextHandler
+}
+
+func (s *SchemaBootstrapHandler) Handle(ctx context.Context) {
+	currentStatus := CtxCluster.MustValue(ctx)
+	source := currentStatus.Spec.Schema
+
+	if source == nil {
+		if len(currentStatus.Status.SchemaHash) > 0 || currentStatus.FindStatusCondition(v1alpha1.ConditionTypeSchemaApplied) != nil {
+			currentStatus.Status.SchemaHash = ""
+			currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeSchemaApplied)
+			if err := s.patchStatus(ctx, currentStatus); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return
+			}
+		}
+		s.next.Handle(ctx)
+		return
+	}
+
+	schema, relationships, err := resolveSchemaSource(source, CtxSchemaConfigMap.Value(ctx))
+	if err != nil {
+		s.fail(ctx, currentStatus, err)
+		return
+	}
+
+	schemaHash := hash.SecureObject([]string{schema, relationships})
+	if currentStatus.Status.SchemaHash == schemaHash && currentStatus.IsStatusConditionTrue(v1alpha1.ConditionTypeSchemaApplied) {
+		s.next.Handle(ctx)
+		return
+	}
+
+	rels, err := spicedb.ParseRelationships(relationships)
+	if err != nil {
+		s.fail(ctx, currentStatus, err)
+		return
+	}
+
+	if err := s.writeSchema(ctx, CtxConfig.MustValue(ctx), schema, rels); err != nil {
+		s.fail(ctx, currentStatus, err)
+		return
+	}
+
+	currentStatus.Status.SchemaHash = schemaHash
+	currentStatus.SetStatusCondition(v1alpha1.NewSchemaAppliedCondition(schemaHash))
+	if err := s.patchStatus(ctx, currentStatus); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	s.recorder.Eventf(currentStatus, corev1.EventTypeNormal, EventSchemaApplied, "Wrote schema and %d relationships", len(rels))
+
+	s.next.Handle(ctx)
+}
+
+func (s *SchemaBootstrapHandler) fail(ctx context.Context, currentStatus *v1alpha1.SpiceDBCluster, err error) {
+	runtime.HandleError(err)
+	currentStatus.SetStatusCondition(v1alpha1.NewSchemaApplyFailedCondition(err))
+	if err := s.patchStatus(ctx, currentStatus); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	s.recorder.Eventf(currentStatus, corev1.EventTypeWarning, EventSchemaApplyFailed, "Error writing schema: %v", err)
+	QueueOps.RequeueAfter(ctx, 10*time.Second)
+}
+
+// resolveSchemaSource returns the schema and relationships text referenced
+// by the source.
+func resolveSchemaSource(source *v1alpha1.SchemaSource, configMap *corev1.ConfigMap) (schema, relationships string, err error) {
+	if len(source.ConfigMapName) == 0 {
+		if len(source.Inline) == 0 {
+			return "", "", fmt.Errorf("schema must specify either inline or configMapName")
+		}
+		return source.Inline, source.Relationships, nil
+	}
+
+	if len(source.Inline) > 0 || len(source.Relationships) > 0 {
+		return "", "", fmt.Errorf("schema cannot specify both configMapName and an inline schema or relationships")
+	}
+	if configMap == nil {
+		return "", "", fmt.Errorf("configmap %q not found", source.ConfigMapName)
+	}
+
+	schemaKey := source.SchemaKey
+	if len(schemaKey) == 0 {
+		schemaKey = DefaultSchemaKey
+	}
+	relationshipsKey := source.RelationshipsKey
+	if len(relationshipsKey) == 0 {
+		relationshipsKey = DefaultRelationshipsKey
+	}
+
+	schema, ok := configMap.Data[schemaKey]
+	if !ok {
+		return "", "", fmt.Errorf("configmap %q has no key %q", configMap.Name, schemaKey)
+	}
+	return schema, configMap.Data[relationshipsKey], nil
+}
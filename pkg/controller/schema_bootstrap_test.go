@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/hash"
+	"github.com/authzed/controller-idioms/queue/fake"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+)
+
+func TestSchemaBootstrapHandler(t *testing.T) {
+	var nextKey handler.Key = "next"
+	const schema = "definition user {}"
+	inlineHash := hash.SecureObject([]string{schema, "user:a#self@user:a"})
+
+	tests := []struct {
+		name string
+
+		source    *v1alpha1.SchemaSource
+		configMap *corev1.ConfigMap
+		status    v1alpha1.ClusterStatus
+		writeErr  error
+
+		expectWrite         bool
+		expectSchema        string
+		expectRelationships int
+		expectPatchStatus   bool
+		expectSchemaHash    string
+		expectCondition     *metav1.Condition
+		expectNext          handler.Key
+		expectRequeueAfter  time.Duration
+		expectEvents        []string
+	}{
+		{
+			name:       "no schema",
+			expectNext: nextKey,
+		},
+		{
+			name: "schema removed clears status",
+			status: v1alpha1.ClusterStatus{
+				SchemaHash: "old",
+				Conditions: []metav1.Condition{v1alpha1.NewSchemaAppliedCondition("old")},
+			},
+			expectPatchStatus: true,
+			expectNext:        nextKey,
+		},
+		{
+			name:                "writes inline schema",
+			source:              &v1alpha1.SchemaSource{Inline: schema, Relationships: "user:a#self@user:a"},
+			expectWrite:         true,
+			expectSchema:        schema,
+			expectRelationships: 1,
+			expectPatchStatus:   true,
+			expectSchemaHash:    inlineHash,
+			expectCondition:     ptr.To(v1alpha1.NewSchemaAppliedCondition(inlineHash)),
+			expectEvents:        []string{"Normal SchemaApplied Wrote schema and 1 relationships"},
+			expectNext:          nextKey,
+		},
+		{
+			name:   "skips already applied schema",
+			source: &v1alpha1.SchemaSource{Inline: schema, Relationships: "user:a#self@user:a"},
+			status: v1alpha1.ClusterStatus{
+				SchemaHash: inlineHash,
+				Conditions: []metav1.Condition{v1alpha1.NewSchemaAppliedCondition(inlineHash)},
+			},
+			expectSchemaHash: inlineHash,
+			expectCondition:  ptr.To(v1alpha1.NewSchemaAppliedCondition(inlineHash)),
+			expectNext:       nextKey,
+		},
+		{
+			name:   "writes schema from configmap",
+			source: &v1alpha1.SchemaSource{ConfigMapName: "schema", SchemaKey: "schema.zed"},
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "schema"},
+				Data:       map[string]string{"schema.zed": schema},
+			},
+			expectWrite:       true,
+			expectSchema:      schema,
+			expectPatchStatus: true,
+			expectSchemaHash:  hash.SecureObject([]string{schema, ""}),
+			expectCondition:   ptr.To(v1alpha1.NewSchemaAppliedCondition(hash.SecureObject([]string{schema, ""}))),
+			expectEvents:      []string{"Normal SchemaApplied Wrote schema and 0 relationships"},
+			expectNext:        nextKey,
+		},
+		{
+			name:   "configmap missing schema key",
+			source: &v1alpha1.SchemaSource{ConfigMapName: "schema"},
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "schema"},
+				Data:       map[string]string{"other": schema},
+			},
+			expectPatchStatus:  true,
+			expectCondition:    ptr.To(v1alpha1.NewSchemaApplyFailedCondition(errors.New(`configmap "schema" has no key "schema"`))),
+			expectEvents:       []string{`Warning SchemaApplyFailed Error writing schema: configmap "schema" has no key "schema"`},
+			expectRequeueAfter: 10 * time.Second,
+		},
+		{
+			name:               "invalid relationships",
+			source:             &v1alpha1.SchemaSource{Inline: schema, Relationships: "user:a@user:a"},
+			expectPatchStatus:  true,
+			expectCondition:    ptr.To(v1alpha1.NewSchemaApplyFailedCondition(errors.New(`line 1: invalid relationship "user:a@user:a": missing relation`))),
+			expectEvents:       []string{`Warning SchemaApplyFailed Error writing schema: line 1: invalid relationship "user:a@user:a": missing relation`},
+			expectRequeueAfter: 10 * time.Second,
+		},
+		{
+			name:               "write fails",
+			source:             &v1alpha1.SchemaSource{Inline: schema},
+			writeErr:           errors.New("unavailable"),
+			expectWrite:        true,
+			expectSchema:       schema,
+			expectPatchStatus:  true,
+			expectCondition:    ptr.To(v1alpha1.NewSchemaApplyFailedCondition(errors.New("unavailable"))),
+			expectEvents:       []string{"Warning SchemaApplyFailed Error writing schema: unavailable"},
+			expectRequeueAfter: 10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrls := &fake.FakeInterface{}
+			cluster := &v1alpha1.SpiceDBCluster{
+				Spec:   v1alpha1.ClusterSpec{Schema: tt.source},
+				Status: tt.status,
+			}
+			ctx := CtxConfig.WithValue(context.Background(), &config.Config{})
+			ctx = QueueOps.WithValue(ctx, ctrls)
+			ctx = CtxCluster.WithValue(ctx, cluster)
+			ctx = CtxSchemaConfigMap.WithValue(ctx, tt.configMap)
+
+			recorder := record.NewFakeRecorder(1)
+			writeCalled := false
+			patchCalled := false
+			var gotSchema string
+			var gotRels []*v1.Relationship
+
+			var called handler.Key
+			h := &SchemaBootstrapHandler{
+				recorder: recorder,
+				writeSchema: func(_ context.Context, _ *config.Config, schema string, rels []*v1.Relationship) error {
+					writeCalled = true
+					gotSchema = schema
+					gotRels = rels
+					return tt.writeErr
+				},
+				patchStatus: func(_ context.Context, _ *v1alpha1.SpiceDBCluster) error {
+					patchCalled = true
+					return nil
+				},
+				next: handler.ContextHandlerFunc(func(_ context.Context) {
+					called = nextKey
+				}),
+			}
+			h.Handle(ctx)
+
+			require.Equal(t, tt.expectWrite, writeCalled)
+			require.Equal(t, tt.expectSchema, gotSchema)
+			require.Len(t, gotRels, tt.expectRelationships)
+			require.Equal(t, tt.expectPatchStatus, patchCalled)
+			require.Equal(t, tt.expectSchemaHash, cluster.Status.SchemaHash)
+			require.Equal(t, tt.expectNext, called)
+			ExpectEvents(t, recorder, tt.expectEvents)
+
+			got := cluster.FindStatusCondition(v1alpha1.ConditionTypeSchemaApplied)
+			if tt.expectCondition == nil {
+				require.Nil(t, got)
+			} else {
+				require.NotNil(t, got)
+				require.Equal(t, tt.expectCondition.Status, got.Status)
+				require.Equal(t, tt.expectCondition.Reason, got.Reason)
+				require.Equal(t, tt.expectCondition.Message, got.Message)
+			}
+
+			if tt.expectRequeueAfter != 0 {
+				require.Equal(t, 1, ctrls.RequeueAfterCallCount())
+				require.Equal(t, tt.expectRequeueAfter, ctrls.RequeueAfterArgsForCall(0))
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/authzed/controller-idioms/cachekeys"
+	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/manager"
+	"github.com/authzed/controller-idioms/middleware"
+	"github.com/authzed/controller-idioms/typed"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/textlogger"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+	"github.com/authzed/spicedb-operator/pkg/spicedb"
+)
+
+// +kubebuilder:rbac:groups="authzed.com",resources=spicedbschemas,verbs=get;watch;list
+// +kubebuilder:rbac:groups="authzed.com",resources=spicedbschemas/status,verbs=get;update;patch
+
+var (
+	v1alpha1SchemaGVR = v1alpha1.SchemeGroupVersion.WithResource(v1alpha1.SpiceDBSchemaResourceName)
+	SchemaFactoryKey  = typed.NewFactoryKey(v1alpha1.SpiceDBSchemaResourceName, "local", "unfiltered")
+)
+
+// schemaClusterIndex indexes SpiceDBSchemas by the namespace/name of the
+// cluster they reference.
+const schemaClusterIndex = "schema-cluster"
+
+// SchemaController writes SpiceDBSchemas to the clusters they reference.
+type SchemaController struct {
+	*manager.OwnedResourceController
+	client      dynamic.Interface
+	mainHandler handler.Handler
+}
+
+// NewSchemaController returns a controller for SpiceDBSchemas. Clusters and
+// their secrets are read from the informers started by NewController, which
+// must be called first with the same registry.
+func NewSchemaController(ctx context.Context, registry *typed.Registry, dclient dynamic.Interface, broadcaster record.EventBroadcaster) (*SchemaController, error) {
+	c := SchemaController{
+		client: dclient,
+	}
+	c.OwnedResourceController = manager.NewOwnedResourceController(
+		textlogger.NewLogger(textlogger.NewConfig()),
+		v1alpha1.SpiceDBSchemaResourceName,
+		v1alpha1SchemaGVR,
+		QueueOps,
+		registry,
+		broadcaster,
+		c.syncOwnedResource,
+	)
+
+	informerFactory := registry.MustNewFilteredDynamicSharedInformerFactory(
+		SchemaFactoryKey,
+		dclient,
+		0,
+		metav1.NamespaceAll,
+		nil,
+	)
+	schemaInformer := informerFactory.ForResource(v1alpha1SchemaGVR).Informer()
+	if err := schemaInformer.AddIndexers(cache.Indexers{schemaClusterIndex: schemaClusterKey}); err != nil {
+		return nil, err
+	}
+	if _, err := schemaInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { c.enqueue(obj) },
+		UpdateFunc: func(_, obj any) { c.enqueue(obj) },
+	}); err != nil {
+		return nil, err
+	}
+
+	// schemas wait for their cluster to be created and rolled out, so they
+	// are requeued whenever it changes
+	clusterInformer, err := registry.InformerForKey(typed.NewRegistryKey(OwnedFactoryKey, v1alpha1ClusterGVR))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := clusterInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { c.syncCluster(obj) },
+		UpdateFunc: func(_, obj any) { c.syncCluster(obj) },
+		DeleteFunc: func(obj any) { c.syncCluster(obj) },
+	}); err != nil {
+		return nil, err
+	}
+
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	c.mainHandler = handler.NewTypeHandler(&SchemaApplyHandler{
+		recorder:    c.Recorder,
+		getCluster:  c.getCluster,
+		connect:     c.connect,
+		patchStatus: c.PatchStatus,
+	})
+
+	return &c, nil
+}
+
+func (c *SchemaController) enqueue(obj any) {
+	key, err := cachekeys.GVRMetaNamespaceKeyFunc(v1alpha1SchemaGVR, obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.Queue.AddRateLimited(key)
+}
+
+// syncCluster requeues the SpiceDBSchemas that reference a cluster.
+func (c *SchemaController) syncCluster(obj any) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	schemas, err := typed.IndexerFor[*v1alpha1.SpiceDBSchema](c.Registry, typed.NewRegistryKey(SchemaFactoryKey, v1alpha1SchemaGVR)).ByIndex(schemaClusterIndex, key)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, s := range schemas {
+		c.enqueue(s)
+	}
+}
+
+func schemaClusterKey(obj any) ([]string, error) {
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object in schema informer: %T", obj)
+	}
+	s, err := typed.UnstructuredObjToTypedObj[*v1alpha1.SpiceDBSchema](runtimeObj)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s.ClusterNN().String()}, nil
+}
+
+// syncOwnedResource is called when a SpiceDBSchema is updated
+func (c *SchemaController) syncOwnedResource(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) {
+	spicedbSchema, err := typed.ListerFor[*v1alpha1.SpiceDBSchema](c.Registry, typed.NewRegistryKey(SchemaFactoryKey, v1alpha1SchemaGVR)).ByNamespace(namespace).Get(name)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("syncOwnedResource called on unknown object (%s::%s/%s): %w", gvr.String(), namespace, name, err))
+		QueueOps.Done(ctx)
+		return
+	}
+
+	logger := textlogger.NewLogger(textlogger.NewConfig()).WithValues(
+		"syncID", middleware.NewSyncID(5),
+		"controller", c.Name(),
+		"obj", klog.KObj(spicedbSchema).MarshalLog(),
+	)
+	ctx = logr.NewContext(ctx, logger)
+	ctx = CtxSpiceDBSchema.WithValue(ctx, spicedbSchema.DeepCopy())
+
+	logger.V(4).Info("syncing owned object", "gvr", gvr)
+
+	c.mainHandler.Handle(ctx)
+}
+
+func (c *SchemaController) getCluster(_ context.Context, nn types.NamespacedName) (*v1alpha1.SpiceDBCluster, error) {
+	return typed.ListerFor[*v1alpha1.SpiceDBCluster](c.Registry, typed.NewRegistryKey(OwnedFactoryKey, v1alpha1ClusterGVR)).ByNamespace(nn.Namespace).Get(nn.Name)
+}
+
+// connect dials the cluster's service with the preshared key from the
+// cluster's secret. The secret is adopted by the cluster before it rolls
+// out, so it's read from the cache of the cluster's dependents.
+func (c *SchemaController) connect(_ context.Context, cluster *v1alpha1.SpiceDBCluster) (schemaClient, error) {
+	secret, err := typed.ListerFor[*corev1.Secret](c.Registry, typed.NewRegistryKey(DependentFactoryKey, corev1.SchemeGroupVersion.WithResource("secrets"))).ByNamespace(cluster.Namespace).Get(cluster.Spec.SecretRef)
+	if err != nil {
+		return nil, err
+	}
+	psk := secret.Data["preshared_key"]
+	if len(psk) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no preshared_key", cluster.Namespace, cluster.Spec.SecretRef)
+	}
+	client, err := spicedb.NewClient(spicedb.Endpoint(cluster.Name, cluster.Namespace), string(psk), len(config.TLSSecretName(cluster)) > 0)
+	if err != nil {
+		return nil, err
+	}
+	return &spiceDBSchemaClient{client: client}, nil
+}
+
+// spiceDBSchemaClient adapts a spicedb.Client to schemaClient, bounding each
+// call with a timeout.
+type spiceDBSchemaClient struct {
+	client *spicedb.Client
+}
+
+func (s *spiceDBSchemaClient) DiffSchema(ctx context.Context, schema string) (*spicedb.SchemaDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return spicedb.DiffSchema(ctx, s.client, schema)
+}
+
+func (s *spiceDBSchemaClient) HasRelationships(ctx context.Context, relation string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return spicedb.HasRelationships(ctx, s.client, relation)
+}
+
+func (s *spiceDBSchemaClient) DeleteRelationships(ctx context.Context, relation string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	return spicedb.DeleteRelationships(ctx, s.client, relation)
+}
+
+func (s *spiceDBSchemaClient) WriteSchema(ctx context.Context, schema string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	token, err := spicedb.WriteSchema(ctx, s.client, schema, nil)
+	if err != nil {
+		return "", err
+	}
+	return token.GetToken(), nil
+}
+
+func (s *spiceDBSchemaClient) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+func TestSchemaClusterKey(t *testing.T) {
+	toUnstructured := func(obj any) *unstructured.Unstructured {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		require.NoError(t, err)
+		return &unstructured.Unstructured{Object: u}
+	}
+	schema := toUnstructured(&v1alpha1.SpiceDBSchema{
+		ObjectMeta: metav1.ObjectMeta{Name: "schema", Namespace: "test"},
+		Spec:       v1alpha1.SchemaSpec{ClusterName: "dev"},
+	})
+	cluster := toUnstructured(&v1alpha1.SpiceDBCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "test"},
+	})
+
+	keys, err := schemaClusterKey(schema)
+	require.NoError(t, err)
+
+	// schemas are looked up with the cache key of the cluster that changed
+	clusterKey, err := cache.DeletionHandlingMetaNamespaceKeyFunc(cluster)
+	require.NoError(t, err)
+	require.Equal(t, []string{clusterKey}, keys)
+
+	_, err = schemaClusterKey("not an object")
+	require.EqualError(t, err, "unexpected object in schema informer: string")
+}
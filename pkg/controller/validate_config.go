@@ -2,10 +2,13 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/kubectl/pkg/util/openapi"
 
@@ -16,12 +19,16 @@ import (
 	"github.com/authzed/spicedb-operator/pkg/config"
 )
 
-const EventInvalidSpiceDBConfig = "InvalidSpiceDBConfig"
+const (
+	EventInvalidSpiceDBConfig = "InvalidSpiceDBConfig"
+	EventEndOfLife            = "EndOfLife"
+)
 
 type ValidateConfigHandler struct {
 	recorder    record.EventRecorder
 	resources   openapi.Resources
 	patchStatus func(ctx context.Context, patch *v1alpha1.SpiceDBCluster) error
+	now         func() time.Time
 	next        handler.ContextHandler
 }
 
@@ -30,7 +37,8 @@ func (c *ValidateConfigHandler) Handle(ctx context.Context) {
 	secret := CtxSecret.Value(ctx)
 	operatorConfig := CtxOperatorConfig.MustValue(ctx)
 
-	validatedConfig, warning, err := config.NewConfig(cluster, operatorConfig, secret, c.resources)
+	now := c.now()
+	validatedConfig, warning, err := config.NewConfig(cluster, operatorConfig, secret, c.resources, now)
 	if err != nil {
 		failedCondition := v1alpha1.NewInvalidConfigCondition(CtxSecretHash.Value(ctx), err)
 		if existing := cluster.FindStatusCondition(v1alpha1.ConditionValidatingFailed); existing != nil && existing.Message == failedCondition.Message {
@@ -50,9 +58,29 @@ func (c *ValidateConfigHandler) Handle(ctx context.Context) {
 		return
 	}
 
-	var warningCondition *metav1.Condition
+	warnings := make([]error, 0)
 	if warning != nil {
-		cond := v1alpha1.NewConfigWarningCondition(warning)
+		warnings = append(warnings, warning)
+	}
+
+	// deprecated versions are a warning, versions past their end of life get
+	// a condition of their own
+	var endOfLifeCondition *metav1.Condition
+	if version := validatedConfig.SpiceDBVersion; version != nil {
+		if state, ok := operatorConfig.UpdateGraph.VersionState(validatedConfig.DatastoreEngine, *version); ok {
+			switch {
+			case state.IsEndOfLife(now):
+				cond := v1alpha1.NewEndOfLifeCondition(version.Name, state.EndOfLife)
+				endOfLifeCondition = &cond
+			case state.IsDeprecated(now):
+				warnings = append(warnings, fmt.Errorf("SpiceDB %s is deprecated, update to a newer version", version.Name))
+			}
+		}
+	}
+
+	var warningCondition *metav1.Condition
+	if len(warnings) > 0 {
+		cond := v1alpha1.NewConfigWarningCondition(errors.NewAggregate(warnings))
 		warningCondition = &cond
 	}
 
@@ -64,14 +92,23 @@ func (c *ValidateConfigHandler) Handle(ctx context.Context) {
 		TargetMigrationHash:  migrationHash,
 		CurrentMigrationHash: cluster.Status.CurrentMigrationHash,
 		SecretHash:           cluster.Status.SecretHash,
+		SchemaHash:           cluster.Status.SchemaHash,
 		Image:                validatedConfig.TargetSpiceDBImage,
 		Migration:            validatedConfig.TargetMigration,
 		Phase:                validatedConfig.TargetPhase,
 		CurrentVersion:       validatedConfig.SpiceDBVersion,
+		Patches:              validatedConfig.PatchStatuses,
+		OperatorConfig:       validatedConfig.OperatorConfigStatus,
+		BlueGreen:            cluster.Status.BlueGreen,
 		Conditions:           *cluster.GetStatusConditions(),
 	}
 	if version := validatedConfig.SpiceDBVersion; version != nil {
-		computedStatus.AvailableVersions, err = operatorConfig.UpdateGraph.AvailableVersions(validatedConfig.DatastoreEngine, *version)
+		computedStatus.AvailableVersions, err = operatorConfig.UpdateGraph.AvailableVersions(validatedConfig.DatastoreEngine, *version, cluster.Spec.VersionConstraint, now)
+		if err != nil {
+			QueueOps.RequeueErr(ctx, err)
+			return
+		}
+		computedStatus.PlannedPath, err = operatorConfig.UpdateGraph.PlannedPath(validatedConfig.DatastoreEngine, *version, cluster.Spec.Version, cluster.Spec.VersionConstraint, now)
 		if err != nil {
 			QueueOps.RequeueErr(ctx, err)
 			return
@@ -84,6 +121,13 @@ func (c *ValidateConfigHandler) Handle(ctx context.Context) {
 	} else {
 		meta.RemoveStatusCondition(&computedStatus.Conditions, v1alpha1.ConditionTypeConfigWarnings)
 	}
+	newlyEndOfLife := false
+	if endOfLifeCondition != nil {
+		newlyEndOfLife = cluster.FindStatusCondition(v1alpha1.ConditionTypeEndOfLife) == nil
+		meta.SetStatusCondition(&computedStatus.Conditions, *endOfLifeCondition)
+	} else {
+		meta.RemoveStatusCondition(&computedStatus.Conditions, v1alpha1.ConditionTypeEndOfLife)
+	}
 
 	// Remove invalid config status and set image and hash
 	if !cluster.Status.Equals(computedStatus) {
@@ -93,6 +137,9 @@ func (c *ValidateConfigHandler) Handle(ctx context.Context) {
 			return
 		}
 	}
+	if newlyEndOfLife {
+		c.recorder.Eventf(cluster, corev1.EventTypeWarning, EventEndOfLife, "SpiceDB %s is past its end of life", validatedConfig.SpiceDBVersion.Name)
+	}
 
 	ctx = CtxConfig.WithValue(ctx, validatedConfig)
 	ctx = CtxCluster.WithValue(ctx, cluster)
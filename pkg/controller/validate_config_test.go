@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -32,6 +33,7 @@ func TestValidateConfigHandler(t *testing.T) {
 		expectStatusImage string
 		expectPatchStatus bool
 		expectConditions  []string
+		expectPatches     []v1alpha1.PatchStatus
 		expectRequeue     bool
 		expectDone        bool
 	}{
@@ -108,6 +110,63 @@ func TestValidateConfigHandler(t *testing.T) {
 			expectStatusImage: "image:v1",
 			expectNext:        nextKey,
 		},
+		{
+			name: "valid config with patches reports patch results",
+			cluster: &v1alpha1.SpiceDBCluster{
+				Spec: v1alpha1.ClusterSpec{
+					Config: json.RawMessage(`{
+						"datastoreEngine": "cockroachdb",
+						"tlsSecretName":   "secret"
+					}`),
+					Patches: []v1alpha1.Patch{
+						{Kind: "*", Patch: json.RawMessage(`[
+							{"op": "add", "path": "/metadata/labels/team", "value": "authz"},
+							{"op": "add", "path": "/metadata/labels/tier", "value": "backend"}
+						]`)},
+						{Kind: "Secret", Patch: json.RawMessage(`{"op": "add", "path": "/metadata/labels/team", "value": "authz"}`)},
+					},
+				},
+				Status: v1alpha1.ClusterStatus{Image: "image"},
+			},
+			existingSecret: &corev1.Secret{
+				Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("testtest"),
+				},
+			},
+			expectPatchStatus: true,
+			expectConditions:  []string{"ConfigurationWarning"},
+			expectPatches: []v1alpha1.PatchStatus{
+				{Index: 0, Result: v1alpha1.PatchResultApplied, Kinds: []string{"Deployment", "Job", "Role", "RoleBinding", "Service", "ServiceAccount"}},
+				{Index: 1, Result: v1alpha1.PatchResultUnmatched, Message: "didn't match any objects"},
+			},
+			expectStatusImage: "image:v1",
+			expectNext:        nextKey,
+		},
+		{
+			name: "invalid config, patch template uses a disallowed function",
+			cluster: &v1alpha1.SpiceDBCluster{
+				Spec: v1alpha1.ClusterSpec{
+					Config: json.RawMessage(`{
+						"datastoreEngine": "cockroachdb",
+						"tlsSecretName":   "secret"
+					}`),
+					Patches: []v1alpha1.Patch{
+						{Kind: "Deployment", Patch: json.RawMessage(`{"metadata": {"labels": {"name": "{{ printf .Cluster.Name }}"}}}`), Template: true},
+					},
+				},
+			},
+			existingSecret: &corev1.Secret{
+				Data: map[string][]byte{
+					"datastore_uri": []byte("uri"),
+					"preshared_key": []byte("testtest"),
+				},
+			},
+			expectEvents:      []string{"Warning InvalidSpiceDBConfig invalid config: error rendering patch 0: invalid template: function \"printf\" is not allowed"},
+			expectConditions:  []string{"ValidatingFailed"},
+			expectPatchStatus: true,
+			expectDone:        true,
+		},
 		{
 			name: "invalid config, missing secret",
 			cluster: &v1alpha1.SpiceDBCluster{
@@ -248,6 +307,7 @@ func TestValidateConfigHandler(t *testing.T) {
 					return nil
 				},
 				recorder: recorder,
+				now:      time.Now,
 				next: handler.ContextHandlerFunc(func(_ context.Context) {
 					called = nextKey
 				}),
@@ -261,6 +321,7 @@ func TestValidateConfigHandler(t *testing.T) {
 			}
 			require.Equal(t, len(tt.expectConditions), len(cluster.Status.Conditions))
 			require.Equal(t, tt.expectStatusImage, cluster.Status.Image)
+			require.Equal(t, tt.expectPatches, cluster.Status.Patches)
 			require.Equal(t, tt.expectPatchStatus, patchCalled)
 			require.Equal(t, tt.expectNext, called)
 			require.Equal(t, tt.expectRequeue, ctrls.RequeueCallCount() == 1)
@@ -269,3 +330,110 @@ func TestValidateConfigHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateConfigHandlerLifecycle(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	endOfLife := metav1.Condition{Type: v1alpha1.ConditionTypeEndOfLife, Status: metav1.ConditionTrue}
+	tests := []struct {
+		name              string
+		state             updates.State
+		existing          []metav1.Condition
+		expectConditions  []string
+		expectWarning     string
+		expectEvents      []string
+		expectPatchStatus bool
+	}{
+		{
+			name:              "supported version",
+			state:             updates.State{ID: "v1", Tag: "v1", DeprecatedSince: "2024-07-01"},
+			expectPatchStatus: false,
+		},
+		{
+			name:              "deprecated version warns",
+			state:             updates.State{ID: "v1", Tag: "v1", DeprecatedSince: "2024-05-01"},
+			expectConditions:  []string{v1alpha1.ConditionTypeConfigWarnings},
+			expectWarning:     "SpiceDB v1 is deprecated, update to a newer version",
+			expectPatchStatus: true,
+		},
+		{
+			name:              "end of life version sets a condition and emits an event",
+			state:             updates.State{ID: "v1", Tag: "v1", EndOfLife: "2024-05-01"},
+			expectConditions:  []string{v1alpha1.ConditionTypeEndOfLife},
+			expectEvents:      []string{"Warning EndOfLife SpiceDB v1 is past its end of life"},
+			expectPatchStatus: true,
+		},
+		{
+			name:              "end of life condition is only reported once",
+			state:             updates.State{ID: "v1", Tag: "v1", EndOfLife: "2024-05-01"},
+			existing:          []metav1.Condition{endOfLife},
+			expectConditions:  []string{v1alpha1.ConditionTypeEndOfLife},
+			expectPatchStatus: true,
+		},
+		{
+			name:              "end of life condition is removed when no longer true",
+			state:             updates.State{ID: "v1", Tag: "v1"},
+			existing:          []metav1.Condition{endOfLife},
+			expectPatchStatus: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrls := &fake.FakeInterface{}
+			recorder := record.NewFakeRecorder(1)
+			patchCalled := false
+
+			cluster := &v1alpha1.SpiceDBCluster{
+				Spec: v1alpha1.ClusterSpec{Config: json.RawMessage(`{
+					"datastoreEngine": "cockroachdb",
+					"tlsSecretName":   "secret"
+				}`)},
+				Status: v1alpha1.ClusterStatus{
+					Image:                "image:v1",
+					Migration:            "head",
+					TargetMigrationHash:  "n549hbh555h557h65ch64chc8h6dq",
+					CurrentMigrationHash: "n549hbh555h557h65ch64chc8h6dq",
+					CurrentVersion:       &v1alpha1.SpiceDBVersion{Name: "v1", Channel: "cockroachdb"},
+					AvailableVersions:    []v1alpha1.SpiceDBVersion{},
+					Conditions:           tt.existing,
+				},
+			}
+			ctx := context.Background()
+			ctx = QueueOps.WithValue(ctx, ctrls)
+			ctx = CtxSecret.WithValue(ctx, &corev1.Secret{Data: map[string][]byte{
+				"datastore_uri": []byte("uri"),
+				"preshared_key": []byte("testtest"),
+			}})
+			ctx = CtxClusterNN.WithValue(ctx, types.NamespacedName{Namespace: "test", Name: "test"})
+			ctx = CtxCluster.WithValue(ctx, cluster)
+			ctx = CtxOperatorConfig.WithValue(ctx, &config.OperatorConfig{
+				ImageName: "image",
+				UpdateGraph: updates.UpdateGraph{Channels: []updates.Channel{{
+					Name:     "cockroachdb",
+					Metadata: map[string]string{"datastore": "cockroachdb", "default": "true"},
+					Nodes:    []updates.State{tt.state},
+					Edges:    map[string][]string{"v1": {}},
+				}}},
+			})
+			h := &ValidateConfigHandler{
+				patchStatus: func(_ context.Context, _ *v1alpha1.SpiceDBCluster) error {
+					patchCalled = true
+					return nil
+				},
+				recorder: recorder,
+				now:      func() time.Time { return now },
+				next:     handler.ContextHandlerFunc(func(_ context.Context) {}),
+			}
+			h.Handle(ctx)
+
+			for _, c := range tt.expectConditions {
+				require.True(t, cluster.IsStatusConditionTrue(c))
+			}
+			require.Equal(t, len(tt.expectConditions), len(cluster.Status.Conditions))
+			if tt.expectWarning != "" {
+				require.Equal(t, tt.expectWarning, cluster.FindStatusCondition(v1alpha1.ConditionTypeConfigWarnings).Message)
+			}
+			require.Equal(t, tt.expectPatchStatus, patchCalled)
+			ExpectEvents(t, recorder, tt.expectEvents)
+		})
+	}
+}
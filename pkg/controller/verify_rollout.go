@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/handler"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+)
+
+const (
+	EventRolloutVerified           = "RolloutVerified"
+	EventRolloutVerificationFailed = "RolloutVerificationFailed"
+)
+
+const (
+	// verifyRolloutTimeout bounds the requests made to verify a rollout, so
+	// that a cluster that accepts connections but never answers fails
+	// verification instead of holding up the sync.
+	verifyRolloutTimeout = 10 * time.Second
+
+	// verifyRolloutRetryInterval is how long to wait before verifying a
+	// rollout again after it failed. Pods that have just become ready can
+	// fail while they warm up, so a failure is retried rather than treated
+	// as final.
+	verifyRolloutRetryInterval = 10 * time.Second
+)
+
+// VerifyRolloutHandler calls the SpiceDB API of a cluster that has finished
+// rolling out to confirm that it can serve requests before the rollout is
+// considered complete.
+type VerifyRolloutHandler struct {
+	recorder      record.EventRecorder
+	verify        func(ctx context.Context, config *config.Config) error
+	patchStatus   func(ctx context.Context, patch *v1alpha1.SpiceDBCluster) error
+	nextSelfPause handler.ContextHandler
+	next          handler.ContextHandler
+}
+
+func (v *VerifyRolloutHandler) Handle(ctx context.Context) {
+	currentStatus := CtxCluster.MustValue(ctx)
+	config := CtxConfig.MustValue(ctx)
+
+	if !config.VerifyRollout {
+		if currentStatus.FindStatusCondition(v1alpha1.ConditionTypeVerified) != nil {
+			currentStatus.RemoveStatusCondition(v1alpha1.ConditionTypeVerified)
+			if err := v.patchStatus(ctx, currentStatus); err != nil {
+				QueueOps.RequeueAPIErr(ctx, err)
+				return
+			}
+		}
+		v.next.Handle(ctx)
+		return
+	}
+
+	// the current deployment has already been verified
+	if currentStatus.IsStatusConditionTrue(v1alpha1.ConditionTypeVerified) {
+		v.next.Handle(ctx)
+		return
+	}
+
+	if err := v.verify(ctx, config); err != nil {
+		runtime.HandleError(err)
+		currentStatus.SetStatusCondition(v1alpha1.NewVerificationFailedCondition(err))
+		v.recorder.Eventf(currentStatus, corev1.EventTypeWarning, EventRolloutVerificationFailed, "Rollout of %s failed verification: %v", config.TargetSpiceDBImage, err)
+
+		// pause so that the failure can be diagnosed before anything else
+		// changes
+		if config.VerifyRolloutPauseOnFailure {
+			ctx = CtxSelfPauseObject.WithValue(ctx, currentStatus)
+			v.nextSelfPause.Handle(ctx)
+			return
+		}
+
+		if err := v.patchStatus(ctx, currentStatus); err != nil {
+			QueueOps.RequeueAPIErr(ctx, err)
+			return
+		}
+		QueueOps.RequeueAfter(ctx, verifyRolloutRetryInterval)
+		return
+	}
+
+	currentStatus.SetStatusCondition(v1alpha1.NewVerifiedCondition("Verified rollout of " + config.TargetSpiceDBImage))
+	if err := v.patchStatus(ctx, currentStatus); err != nil {
+		QueueOps.RequeueAPIErr(ctx, err)
+		return
+	}
+	v.recorder.Eventf(currentStatus, corev1.EventTypeNormal, EventRolloutVerified, "Verified rollout of %s", config.TargetSpiceDBImage)
+
+	v.next.Handle(ctx)
+}
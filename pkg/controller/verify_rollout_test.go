@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/authzed/controller-idioms/handler"
+	"github.com/authzed/controller-idioms/queue/fake"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
+)
+
+func TestVerifyRolloutHandler(t *testing.T) {
+	var nextKey handler.Key = "next"
+	tests := []struct {
+		name string
+
+		config        config.SpiceConfig
+		currentStatus *v1alpha1.SpiceDBCluster
+		verifyErr     error
+
+		expectVerify       bool
+		expectPatchStatus  bool
+		expectConditions   []metav1.Condition
+		expectNext         handler.Key
+		expectRequeueAfter time.Duration
+		expectEvents       []string
+	}{
+		{
+			name:          "verification disabled",
+			currentStatus: &v1alpha1.SpiceDBCluster{},
+			expectNext:    nextKey,
+		},
+		{
+			name: "verification disabled removes stale condition",
+			currentStatus: &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{
+				v1alpha1.NewVerifiedCondition("Verified rollout of old"),
+			}}},
+			expectPatchStatus: true,
+			expectConditions:  []metav1.Condition{},
+			expectNext:        nextKey,
+		},
+		{
+			name:   "already verified",
+			config: config.SpiceConfig{VerifyRollout: true},
+			currentStatus: &v1alpha1.SpiceDBCluster{Status: v1alpha1.ClusterStatus{Conditions: []metav1.Condition{
+				v1alpha1.NewVerifiedCondition("Verified rollout of test"),
+			}}},
+			expectConditions: []metav1.Condition{
+				v1alpha1.NewVerifiedCondition("Verified rollout of test"),
+			},
+			expectNext: nextKey,
+		},
+		{
+			name:              "verification succeeds",
+			config:            config.SpiceConfig{VerifyRollout: true},
+			currentStatus:     &v1alpha1.SpiceDBCluster{},
+			expectVerify:      true,
+			expectPatchStatus: true,
+			expectConditions: []metav1.Condition{
+				v1alpha1.NewVerifiedCondition("Verified rollout of test"),
+			},
+			expectEvents: []string{"Normal RolloutVerified Verified rollout of test"},
+			expectNext:   nextKey,
+		},
+		{
+			name:              "verification fails, retry",
+			config:            config.SpiceConfig{VerifyRollout: true},
+			currentStatus:     &v1alpha1.SpiceDBCluster{},
+			verifyErr:         errors.New("unavailable"),
+			expectVerify:      true,
+			expectPatchStatus: true,
+			expectConditions: []metav1.Condition{
+				v1alpha1.NewVerificationFailedCondition(errors.New("unavailable")),
+			},
+			expectEvents:       []string{"Warning RolloutVerificationFailed Rollout of test failed verification: unavailable"},
+			expectRequeueAfter: verifyRolloutRetryInterval,
+		},
+		{
+			name:          "verification fails, pause",
+			config:        config.SpiceConfig{VerifyRollout: true, VerifyRolloutPauseOnFailure: true},
+			currentStatus: &v1alpha1.SpiceDBCluster{},
+			verifyErr:     errors.New("unavailable"),
+			expectVerify:  true,
+			expectConditions: []metav1.Condition{
+				v1alpha1.NewVerificationFailedCondition(errors.New("unavailable")),
+			},
+			expectEvents: []string{"Warning RolloutVerificationFailed Rollout of test failed verification: unavailable"},
+			expectNext:   HandlerSelfPauseKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrls := &fake.FakeInterface{}
+			ctx := CtxConfig.WithValue(context.Background(), &config.Config{
+				MigrationConfig: config.MigrationConfig{TargetSpiceDBImage: "test"},
+				SpiceConfig:     tt.config,
+			})
+			ctx = QueueOps.WithValue(ctx, ctrls)
+			ctx = CtxCluster.WithValue(ctx, tt.currentStatus)
+
+			recorder := record.NewFakeRecorder(1)
+			verifyCalled := false
+			patchCalled := false
+
+			var called handler.Key
+			h := &VerifyRolloutHandler{
+				recorder: recorder,
+				verify: func(_ context.Context, _ *config.Config) error {
+					verifyCalled = true
+					return tt.verifyErr
+				},
+				patchStatus: func(_ context.Context, _ *v1alpha1.SpiceDBCluster) error {
+					patchCalled = true
+					return nil
+				},
+				nextSelfPause: handler.ContextHandlerFunc(func(_ context.Context) {
+					called = HandlerSelfPauseKey
+				}),
+				next: handler.ContextHandlerFunc(func(_ context.Context) {
+					called = nextKey
+				}),
+			}
+			h.Handle(ctx)
+
+			require.Equal(t, tt.expectVerify, verifyCalled)
+			require.Equal(t, tt.expectPatchStatus, patchCalled)
+			require.Equal(t, tt.expectNext, called)
+			ExpectEvents(t, recorder, tt.expectEvents)
+
+			conditions := CtxCluster.MustValue(ctx).Status.Conditions
+			for i := range conditions {
+				conditions[i].LastTransitionTime = metav1.Time{}
+			}
+			for i := range tt.expectConditions {
+				tt.expectConditions[i].LastTransitionTime = metav1.Time{}
+			}
+			require.Equal(t, tt.expectConditions, conditions)
+
+			if tt.expectRequeueAfter != 0 {
+				require.Equal(t, 1, ctrls.RequeueAfterCallCount())
+				require.Equal(t, tt.expectRequeueAfter, ctrls.RequeueAfterArgsForCall(0))
+			}
+		})
+	}
+}
@@ -3,35 +3,71 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/record"
 
 	"github.com/authzed/controller-idioms/handler"
 
 	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+	"github.com/authzed/spicedb-operator/pkg/config"
 )
 
-const EventMigrationsComplete = "MigrationsCompleted"
+const (
+	EventMigrationsComplete = "MigrationsCompleted"
+	EventMigrationTimedOut  = "MigrationTimedOut"
+	EventMigrationFailed    = "MigrationFailed"
+
+	// migrationLogTailLines is how many lines of the migration job's logs are
+	// kept in the status when it fails or times out
+	migrationLogTailLines = 20
+
+	// maxMigrationExcerptBytes bounds the output of a migration pod that is
+	// copied into conditions and events; the end of the output is kept
+	maxMigrationExcerptBytes = 1024
+)
 
 type WaitForMigrationsHandler struct {
 	recorder              record.EventRecorder
+	now                   func() time.Time
+	getJobPods            func(ctx context.Context) []*corev1.Pod
+	getPodLogs            func(ctx context.Context, nn types.NamespacedName, tailLines int64) (string, error)
 	nextSelfPause         handler.ContextHandler
 	nextDeploymentHandler handler.ContextHandler
 }
 
 func (m *WaitForMigrationsHandler) Handle(ctx context.Context) {
 	job := CtxCurrentMigrationJob.MustValue(ctx)
+	timeout := CtxConfig.MustValue(ctx).MigrationJobSettings.Timeout
+
+	// if migration ran for too long, pause with the job's logs so we can
+	// diagnose
+	remaining := 5 * time.Second
+	if timeout > 0 && !jobConditionHasStatus(job, batchv1.JobComplete, corev1.ConditionTrue) {
+		var timedOut bool
+		timedOut, remaining = m.timedOut(job, timeout)
+		if timedOut {
+			m.pauseTimedOut(ctx, job, timeout)
+			return
+		}
+	}
 
 	// if migration failed entirely, pause so we can diagnose
 	if c := findJobCondition(job, batchv1.JobFailed); c != nil && c.Status == corev1.ConditionTrue {
 		currentStatus := CtxCluster.MustValue(ctx)
 		config := CtxConfig.MustValue(ctx)
-		err := fmt.Errorf("migration job failed: %s", c.Message)
-		runtime.HandleError(err)
+		runtime.HandleError(fmt.Errorf("migration job failed: %s", c.Message))
+		details := c.Message
+		if excerpt := m.failureExcerpt(ctx, job); len(excerpt) > 0 {
+			details += "; last output from the migration pod:\n" + excerpt
+		}
+		err := fmt.Errorf("migration job failed: %s", details)
+		m.recorder.Eventf(currentStatus, corev1.EventTypeWarning, EventMigrationFailed, "Migration job %s failed: %s", job.Name, details)
 		currentStatus.SetStatusCondition(v1alpha1.NewMigrationFailedCondition(config.DatastoreEngine, "head", err))
 		ctx = CtxSelfPauseObject.WithValue(ctx, currentStatus)
 		m.nextSelfPause.Handle(ctx)
@@ -46,7 +82,114 @@ func (m *WaitForMigrationsHandler) Handle(ctx context.Context) {
 	}
 
 	// otherwise, it's created but still running, just wait
-	QueueOps.RequeueAfter(ctx, 5*time.Second)
+	QueueOps.RequeueAfter(ctx, remaining)
+}
+
+// timedOut returns whether the job has run for longer than the timeout and,
+// if it hasn't, how long to wait before checking again.
+func (m *WaitForMigrationsHandler) timedOut(job *batchv1.Job, timeout time.Duration) (bool, time.Duration) {
+	if c := findJobCondition(job, batchv1.JobFailed); c != nil && c.Status == corev1.ConditionTrue && c.Reason == batchv1.JobReasonDeadlineExceeded {
+		return true, 0
+	}
+	if job.Status.StartTime == nil {
+		return false, 5 * time.Second
+	}
+	remaining := job.Status.StartTime.Add(timeout).Sub(m.now())
+	if remaining <= 0 {
+		return true, 0
+	}
+	return false, min(remaining, 5*time.Second)
+}
+
+func (m *WaitForMigrationsHandler) pauseTimedOut(ctx context.Context, job *batchv1.Job, timeout time.Duration) {
+	currentStatus := CtxCluster.MustValue(ctx)
+	config := CtxConfig.MustValue(ctx)
+	logs := m.jobLogs(ctx, job)
+	runtime.HandleError(fmt.Errorf("migration job %s/%s timed out after %s", job.Namespace, job.Name, timeout))
+	m.recorder.Eventf(currentStatus, corev1.EventTypeWarning, EventMigrationTimedOut, "Migration job %s did not finish within %s", job.Name, timeout)
+	currentStatus.SetStatusCondition(v1alpha1.NewMigrationTimedOutCondition(config.DatastoreEngine, config.TargetMigration, timeout, logs))
+	ctx = CtxSelfPauseObject.WithValue(ctx, currentStatus)
+	m.nextSelfPause.Handle(ctx)
+}
+
+// failureExcerpt returns the end of the output of the job's newest pod. The
+// termination message is read from the pod status, and the logs are only
+// fetched if the container didn't leave one.
+func (m *WaitForMigrationsHandler) failureExcerpt(ctx context.Context, job *batchv1.Job) string {
+	pod := m.newestJobPod(ctx, job)
+	if pod == nil {
+		return ""
+	}
+	if msg := terminationMessage(pod, config.ContainerNameMigrate); len(msg) > 0 {
+		return truncateExcerpt(msg)
+	}
+	return truncateExcerpt(m.podLogs(ctx, pod))
+}
+
+// jobLogs returns the last lines logged by the newest pod of the job, or an
+// empty string if there are none.
+func (m *WaitForMigrationsHandler) jobLogs(ctx context.Context, job *batchv1.Job) string {
+	pod := m.newestJobPod(ctx, job)
+	if pod == nil {
+		return ""
+	}
+	return truncateExcerpt(m.podLogs(ctx, pod))
+}
+
+func (m *WaitForMigrationsHandler) newestJobPod(ctx context.Context, job *batchv1.Job) *corev1.Pod {
+	var newest *corev1.Pod
+	for _, p := range m.getJobPods(ctx) {
+		if p.Labels["job-name"] != job.Name {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&p.CreationTimestamp) {
+			newest = p
+		}
+	}
+	return newest
+}
+
+func (m *WaitForMigrationsHandler) podLogs(ctx context.Context, pod *corev1.Pod) string {
+	logs, err := m.getPodLogs(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, migrationLogTailLines)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("error fetching logs for migration pod %s/%s: %w", pod.Namespace, pod.Name, err))
+		return ""
+	}
+	return logs
+}
+
+// terminationMessage returns the termination message of the most recent
+// termination of a container in the pod.
+func terminationMessage(pod *corev1.Pod, container string) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != container {
+			continue
+		}
+		if t := status.State.Terminated; t != nil && len(strings.TrimSpace(t.Message)) > 0 {
+			return t.Message
+		}
+		if t := status.LastTerminationState.Terminated; t != nil {
+			return t.Message
+		}
+	}
+	return ""
+}
+
+// truncateExcerpt trims output to at most maxMigrationExcerptBytes, keeping
+// the end, which is where errors are usually logged.
+func truncateExcerpt(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= maxMigrationExcerptBytes {
+		return output
+	}
+	output = output[len(output)-maxMigrationExcerptBytes:]
+	// don't start in the middle of a line or a multibyte character
+	if i := strings.IndexByte(output, '\n'); i >= 0 && i < len(output)-1 {
+		output = output[i+1:]
+	} else {
+		output = strings.ToValidUTF8(output, "")
+	}
+	return "..." + output
 }
 
 func findJobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
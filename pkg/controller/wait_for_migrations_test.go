@@ -2,13 +2,20 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/require"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 
 	"github.com/authzed/controller-idioms/handler"
 	"github.com/authzed/controller-idioms/queue/fake"
@@ -18,14 +25,27 @@ import (
 )
 
 func TestWaitForMigrationsHandler(t *testing.T) {
+	now := time.Now()
+	failedJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-migrate", Namespace: "test"},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+			Type:    batchv1.JobFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "BackoffLimitExceeded",
+			Message: "BackoffLimitExceeded",
+		}}},
+	}
 	tests := []struct {
 		name string
 
 		migrationJob *batchv1.Job
+		timeout      time.Duration
+		jobPods      []*corev1.Pod
 
 		expectNext         handler.Key
 		expectRequeueAfter time.Duration
 		expectEvents       []string
+		expectCondition    *metav1.Condition
 	}{
 		{
 			name:               "job is still running, requeue with delay",
@@ -44,21 +64,124 @@ func TestWaitForMigrationsHandler(t *testing.T) {
 			expectNext: HandlerDeploymentKey,
 		},
 		{
-			name: "job failed, pause reconciliation",
-			migrationJob: &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
-				Type:   batchv1.JobFailed,
-				Status: corev1.ConditionTrue,
-			}}}},
+			name:         "job failed, pause reconciliation",
+			migrationJob: failedJob,
+			expectNext:   HandlerSelfPauseKey,
+			expectEvents: []string{
+				"Warning MigrationFailed Migration job test-migrate failed: BackoffLimitExceeded",
+			},
+			expectCondition: ptr.To(v1alpha1.NewMigrationFailedCondition("cockroachdb", "head", errors.New("migration job failed: BackoffLimitExceeded"))),
+		},
+		{
+			name:         "job failed, report the pod's termination message",
+			migrationJob: failedJob,
+			jobPods: []*corev1.Pod{{
+				ObjectMeta: metav1.ObjectMeta{Name: "failed", Labels: map[string]string{"job-name": "test-migrate"}},
+				Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+					Name: config.ContainerNameMigrate,
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						Message: "connection refused\n",
+					}},
+				}}},
+			}},
 			expectNext: HandlerSelfPauseKey,
+			expectEvents: []string{
+				"Warning MigrationFailed Migration job test-migrate failed: BackoffLimitExceeded; last output from the migration pod:\nconnection refused",
+			},
+			expectCondition: ptr.To(v1alpha1.NewMigrationFailedCondition("cockroachdb", "head", errors.New("migration job failed: BackoffLimitExceeded; last output from the migration pod:\nconnection refused"))),
+		},
+		{
+			name:         "job failed, report the pod's logs without a termination message",
+			migrationJob: failedJob,
+			jobPods: []*corev1.Pod{{
+				ObjectMeta: metav1.ObjectMeta{Name: "failed", Labels: map[string]string{"job-name": "test-migrate"}},
+			}},
+			expectNext: HandlerSelfPauseKey,
+			expectEvents: []string{
+				"Warning MigrationFailed Migration job test-migrate failed: BackoffLimitExceeded; last output from the migration pod:\nlogs from failed",
+			},
+			expectCondition: ptr.To(v1alpha1.NewMigrationFailedCondition("cockroachdb", "head", errors.New("migration job failed: BackoffLimitExceeded; last output from the migration pod:\nlogs from failed"))),
+		},
+		{
+			name: "job is running within its timeout, requeue with delay",
+			migrationJob: &batchv1.Job{Status: batchv1.JobStatus{
+				StartTime: &metav1.Time{Time: now.Add(-time.Minute)},
+			}},
+			timeout:            10 * time.Minute,
+			expectRequeueAfter: 5 * time.Second,
+		},
+		{
+			name: "job is about to time out, requeue at the timeout",
+			migrationJob: &batchv1.Job{Status: batchv1.JobStatus{
+				StartTime: &metav1.Time{Time: now.Add(-10*time.Minute + 2*time.Second)},
+			}},
+			timeout:            10 * time.Minute,
+			expectRequeueAfter: 2 * time.Second,
+		},
+		{
+			name: "job timed out, pause with logs of the newest pod",
+			migrationJob: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-migrate", Namespace: "test"},
+				Status: batchv1.JobStatus{
+					StartTime: &metav1.Time{Time: now.Add(-11 * time.Minute)},
+				},
+			},
+			timeout: 10 * time.Minute,
+			jobPods: []*corev1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "old", Labels: map[string]string{"job-name": "test-migrate"}, CreationTimestamp: metav1.NewTime(now.Add(-10 * time.Minute))}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "new", Labels: map[string]string{"job-name": "test-migrate"}, CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"job-name": "other-migrate"}, CreationTimestamp: metav1.NewTime(now)}},
+			},
+			expectNext: HandlerSelfPauseKey,
+			expectEvents: []string{
+				"Warning MigrationTimedOut Migration job test-migrate did not finish within 10m0s",
+			},
+			expectCondition: ptr.To(v1alpha1.NewMigrationTimedOutCondition("cockroachdb", "head", 10*time.Minute, "logs from new")),
+		},
+		{
+			name: "job hit its deadline, pause without logs",
+			migrationJob: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-migrate", Namespace: "test"},
+				Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+					Type:   batchv1.JobFailed,
+					Status: corev1.ConditionTrue,
+					Reason: batchv1.JobReasonDeadlineExceeded,
+				}}},
+			},
+			timeout:    10 * time.Minute,
+			expectNext: HandlerSelfPauseKey,
+			expectEvents: []string{
+				"Warning MigrationTimedOut Migration job test-migrate did not finish within 10m0s",
+			},
+			expectCondition: ptr.To(v1alpha1.NewMigrationTimedOutCondition("cockroachdb", "head", 10*time.Minute, "")),
+		},
+		{
+			name: "completed job isn't timed out",
+			migrationJob: &batchv1.Job{Status: batchv1.JobStatus{
+				StartTime: &metav1.Time{Time: now.Add(-time.Hour)},
+				Conditions: []batchv1.JobCondition{{
+					Type:   batchv1.JobComplete,
+					Status: corev1.ConditionTrue,
+				}},
+			}},
+			timeout: 10 * time.Minute,
+			expectEvents: []string{
+				"Normal MigrationsCompleted Migrations completed for test",
+			},
+			expectNext: HandlerDeploymentKey,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrls := &fake.FakeInterface{}
 
-			ctx := CtxConfig.WithValue(context.Background(), &config.Config{MigrationConfig: config.MigrationConfig{TargetSpiceDBImage: "test"}})
+			ctx := CtxConfig.WithValue(context.Background(), &config.Config{
+				MigrationConfig: config.MigrationConfig{TargetSpiceDBImage: "test", DatastoreEngine: "cockroachdb", TargetMigration: "head"},
+				SpiceConfig:     config.SpiceConfig{MigrationJobSettings: config.MigrationJobConfig{Timeout: tt.timeout}},
+			})
 			ctx = QueueOps.WithValue(ctx, ctrls)
-			ctx = CtxCluster.WithValue(ctx, &v1alpha1.SpiceDBCluster{})
+			cluster := &v1alpha1.SpiceDBCluster{}
+			ctx = CtxCluster.WithValue(ctx, cluster)
 			ctx = CtxCurrentMigrationJob.WithValue(ctx, tt.migrationJob)
 
 			recorder := record.NewFakeRecorder(1)
@@ -66,6 +189,14 @@ func TestWaitForMigrationsHandler(t *testing.T) {
 			var called handler.Key
 			h := &WaitForMigrationsHandler{
 				recorder: recorder,
+				now:      func() time.Time { return now },
+				getJobPods: func(_ context.Context) []*corev1.Pod {
+					return tt.jobPods
+				},
+				getPodLogs: func(_ context.Context, nn types.NamespacedName, tailLines int64) (string, error) {
+					require.Equal(t, int64(migrationLogTailLines), tailLines)
+					return "logs from " + nn.Name + "\n", nil
+				},
 				nextSelfPause: handler.ContextHandlerFunc(func(_ context.Context) {
 					called = HandlerSelfPauseKey
 				}),
@@ -77,6 +208,12 @@ func TestWaitForMigrationsHandler(t *testing.T) {
 
 			require.Equal(t, tt.expectNext, called)
 			ExpectEvents(t, recorder, tt.expectEvents)
+			if tt.expectCondition != nil {
+				cond := cluster.FindStatusCondition(v1alpha1.ConditionTypeMigrating)
+				require.NotNil(t, cond)
+				require.Equal(t, tt.expectCondition.Reason, cond.Reason)
+				require.Equal(t, tt.expectCondition.Message, cond.Message)
+			}
 
 			if tt.expectRequeueAfter != 0 {
 				require.Equal(t, 1, ctrls.RequeueAfterCallCount())
@@ -85,3 +222,19 @@ func TestWaitForMigrationsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTruncateExcerpt(t *testing.T) {
+	require.Equal(t, "short output", truncateExcerpt("  short output\n"))
+
+	lines := make([]string, 0)
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("log line %03d with some padding", i))
+	}
+	got := truncateExcerpt(strings.Join(lines, "\n"))
+	require.LessOrEqual(t, len(got), maxMigrationExcerptBytes+len("..."))
+	require.True(t, strings.HasPrefix(got, "...log line "), got)
+	require.True(t, strings.HasSuffix(got, "log line 099 with some padding"))
+
+	got = truncateExcerpt(strings.Repeat("é", maxMigrationExcerptBytes))
+	require.True(t, utf8.ValidString(got))
+}
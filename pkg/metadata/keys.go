@@ -16,23 +16,28 @@ import (
 )
 
 const (
-	OwningClusterIndex              = "owning-cluster"
-	OperatorManagedLabelKey         = "authzed.com/managed-by"
-	OperatorManagedLabelValue       = "operator"
-	OwnerLabelKey                   = "authzed.com/cluster"
-	OwnerAnnotationKeyPrefix        = "authzed.com.cluster-owner/"
-	ComponentLabelKey               = "authzed.com/cluster-component"
-	ComponentSpiceDBLabelValue      = "spicedb"
-	ComponentMigrationJobLabelValue = "migration-job"
-	ComponentServiceAccountLabel    = "spicedb-serviceaccount"
-	ComponentRoleLabel              = "spicedb-role"
-	ComponentServiceLabel           = "spicedb-service"
-	ComponentRoleBindingLabel       = "spicedb-rolebinding"
-	SpiceDBMigrationRequirementsKey = "authzed.com/spicedb-migration"
-	SpiceDBTargetMigrationKey       = "authzed.com/spicedb-target-migration"
-	SpiceDBSecretRequirementsKey    = "authzed.com/spicedb-secret" // nolint: gosec
-	SpiceDBConfigKey                = "authzed.com/spicedb-configuration"
-	FieldManager                    = "spicedb-operator"
+	OwningClusterIndex                    = "owning-cluster"
+	OperatorManagedLabelKey               = "authzed.com/managed-by"
+	OperatorManagedLabelValue             = "operator"
+	OwnerLabelKey                         = "authzed.com/cluster"
+	OwnerAnnotationKeyPrefix              = "authzed.com.cluster-owner/"
+	ComponentLabelKey                     = "authzed.com/cluster-component"
+	ComponentSpiceDBLabelValue            = "spicedb"
+	ComponentDispatchLabelValue           = "spicedb-dispatch"
+	ComponentMigrationJobLabelValue       = "migration-job"
+	ComponentProvisioningJobLabel         = "provisioning-job"
+	ComponentServiceAccountLabel          = "spicedb-serviceaccount"
+	ComponentRoleLabel                    = "spicedb-role"
+	ComponentServiceLabel                 = "spicedb-service"
+	ComponentDispatchServiceLabel         = "spicedb-dispatch-service"
+	ComponentIsolatedDispatchServiceLabel = "spicedb-isolated-dispatch-service"
+	ComponentRoleBindingLabel             = "spicedb-rolebinding"
+	ColorLabelKey                         = "authzed.com/spicedb-color"
+	SpiceDBMigrationRequirementsKey       = "authzed.com/spicedb-migration"
+	SpiceDBTargetMigrationKey             = "authzed.com/spicedb-target-migration"
+	SpiceDBSecretRequirementsKey          = "authzed.com/spicedb-secret" // nolint: gosec
+	SpiceDBConfigKey                      = "authzed.com/spicedb-configuration"
+	FieldManager                          = "spicedb-operator"
 )
 
 var (
@@ -0,0 +1,68 @@
+// Package spicedb contains helpers for talking to the SpiceDB API of a
+// cluster managed by the operator.
+package spicedb
+
+import (
+	"fmt"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCPort is the port that the SpiceDB API is exposed on by the cluster
+// service.
+const GRPCPort = 50051
+
+// Client is a connection to the SpiceDB API of a single cluster.
+type Client struct {
+	v1.SchemaServiceClient
+	v1.PermissionsServiceClient
+	v1.ExperimentalServiceClient
+
+	conn *grpc.ClientConn
+}
+
+// Endpoint returns the in-cluster address of the grpc API for the cluster
+// with the given name and namespace.
+func Endpoint(name, namespace string) string {
+	return fmt.Sprintf("%s.%s:%d", name, namespace, GRPCPort)
+}
+
+// NewClient returns a client for the SpiceDB API at endpoint, authenticated
+// with the preshared key. If tlsEnabled is set the connection uses TLS, but
+// the certificate is not verified: the operator doesn't have access to the
+// CA that signed the cluster's serving certificate.
+func NewClient(endpoint, presharedKey string, tlsEnabled bool) (*Client, error) {
+	opts := make([]grpc.DialOption, 0, 2)
+	if tlsEnabled {
+		certs, err := grpcutil.WithSystemCerts(grpcutil.SkipVerifyCA)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, certs, grpcutil.WithBearerToken(presharedKey))
+	} else {
+		opts = append(opts,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpcutil.WithInsecureBearerToken(presharedKey),
+		)
+	}
+
+	conn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", endpoint, err)
+	}
+
+	return &Client{
+		SchemaServiceClient:       v1.NewSchemaServiceClient(conn),
+		PermissionsServiceClient:  v1.NewPermissionsServiceClient(conn),
+		ExperimentalServiceClient: v1.NewExperimentalServiceClient(conn),
+		conn:                      conn,
+	}, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
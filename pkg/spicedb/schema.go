@@ -0,0 +1,158 @@
+package spicedb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// writeBatchSize is kept well under SpiceDB's default limit on the number of
+// updates allowed in a single WriteRelationships call.
+const writeBatchSize = 500
+
+// ParseRelationships parses newline-separated relationships of the form
+// `resource:id#relation@subject:id[#relation]`. Blank lines and lines
+// starting with `//` are ignored.
+func ParseRelationships(text string) ([]*v1.Relationship, error) {
+	rels := make([]*v1.Relationship, 0)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	line := 0
+	for scanner.Scan() {
+		line++
+		rel := strings.TrimSpace(scanner.Text())
+		if len(rel) == 0 || strings.HasPrefix(rel, "//") {
+			continue
+		}
+		parsed, err := ParseRelationship(rel)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rels = append(rels, parsed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rels, nil
+}
+
+// ParseRelationship parses a single relationship of the form
+// `resource:id#relation@subject:id[#relation]`.
+func ParseRelationship(rel string) (*v1.Relationship, error) {
+	resource, subject, ok := strings.Cut(rel, "@")
+	if !ok {
+		return nil, fmt.Errorf("invalid relationship %q: expected resource#relation@subject", rel)
+	}
+	resource, relation, ok := strings.Cut(resource, "#")
+	if !ok || len(relation) == 0 {
+		return nil, fmt.Errorf("invalid relationship %q: missing relation", rel)
+	}
+	resourceRef, err := parseObject(resource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relationship %q: %w", rel, err)
+	}
+	subjectRef, err := parseSubject(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relationship %q: %w", rel, err)
+	}
+	return &v1.Relationship{
+		Resource: resourceRef,
+		Relation: relation,
+		Subject:  subjectRef,
+	}, nil
+}
+
+// WriteSchema writes the schema to the cluster and then touches each of the
+// relationships, so that re-applying the same input is idempotent.
+func WriteSchema(ctx context.Context, client *Client, schema string, rels []*v1.Relationship) (*v1.ZedToken, error) {
+	resp, err := client.SchemaServiceClient.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: schema})
+	if err != nil {
+		return nil, fmt.Errorf("error writing schema: %w", err)
+	}
+	token := resp.GetWrittenAt()
+
+	for start := 0; start < len(rels); start += writeBatchSize {
+		end := min(start+writeBatchSize, len(rels))
+		updates := make([]*v1.RelationshipUpdate, 0, end-start)
+		for _, rel := range rels[start:end] {
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+				Relationship: rel,
+			})
+		}
+		resp, err := client.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+		if err != nil {
+			return nil, fmt.Errorf("error writing relationships: %w", err)
+		}
+		token = resp.GetWrittenAt()
+	}
+	return token, nil
+}
+
+// HasRelationships returns true if any relationships exist for the relation,
+// given as `definition#relation`.
+func HasRelationships(ctx context.Context, client *Client, relation string) (bool, error) {
+	filter, err := relationFilter(relation)
+	if err != nil {
+		return false, err
+	}
+	stream, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency:        &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: filter,
+		OptionalLimit:      1,
+	})
+	if err != nil {
+		return false, fmt.Errorf("error reading relationships for %s: %w", relation, err)
+	}
+	_, err = stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading relationships for %s: %w", relation, err)
+	}
+	return true, nil
+}
+
+// deleteBatchSize is the number of relationships removed per
+// DeleteRelationships call. It matches SpiceDB's default limit; larger
+// requests are rejected unless the server is configured to allow them.
+const deleteBatchSize = 1000
+
+// DeleteRelationships deletes all relationships for the relation, given as
+// `definition#relation`. Relationships are deleted in batches until none are
+// left.
+func DeleteRelationships(ctx context.Context, client *Client, relation string) error {
+	filter, err := relationFilter(relation)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := client.PermissionsServiceClient.DeleteRelationships(ctx, &v1.DeleteRelationshipsRequest{
+			RelationshipFilter:            filter,
+			OptionalLimit:                 deleteBatchSize,
+			OptionalAllowPartialDeletions: true,
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting relationships for %s: %w", relation, err)
+		}
+		if resp.GetDeletionProgress() != v1.DeleteRelationshipsResponse_DELETION_PROGRESS_PARTIAL {
+			return nil
+		}
+	}
+}
+
+func relationFilter(relation string) (*v1.RelationshipFilter, error) {
+	definition, name, ok := strings.Cut(relation, "#")
+	if !ok {
+		return nil, fmt.Errorf("expected a relation of the form definition#relation, got %q", relation)
+	}
+	return &v1.RelationshipFilter{
+		ResourceType:     definition,
+		OptionalRelation: name,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package spicedb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestParseRelationships(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantCount int
+		wantErr   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "relationships with comments and blank lines",
+			text: `
+// the readme is public
+document:readme#viewer@user:*
+
+document:readme#viewer@group:eng#member
+`,
+			wantCount: 2,
+		},
+		{
+			name:    "invalid relationship",
+			text:    "document:readme#viewer@user:alice\ndocument:readme@user:bob",
+			wantErr: `line 2: invalid relationship "document:readme@user:bob": missing relation`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRelationships(tt.text)
+			if len(tt.wantErr) > 0 {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, got, tt.wantCount)
+		})
+	}
+}
+
+// fakePermissionsClient deletes from a fixed number of relationships,
+// honoring the limit the same way SpiceDB does.
+type fakePermissionsClient struct {
+	v1.PermissionsServiceClient
+
+	remaining int
+	requests  []*v1.DeleteRelationshipsRequest
+	err       error
+}
+
+func (f *fakePermissionsClient) DeleteRelationships(_ context.Context, in *v1.DeleteRelationshipsRequest, _ ...grpc.CallOption) (*v1.DeleteRelationshipsResponse, error) {
+	f.requests = append(f.requests, in)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if in.OptionalLimit > 0 && f.remaining > int(in.OptionalLimit) {
+		if !in.OptionalAllowPartialDeletions {
+			return nil, errors.New("found more than the limit")
+		}
+		f.remaining -= int(in.OptionalLimit)
+		return &v1.DeleteRelationshipsResponse{DeletionProgress: v1.DeleteRelationshipsResponse_DELETION_PROGRESS_PARTIAL}, nil
+	}
+	f.remaining = 0
+	return &v1.DeleteRelationshipsResponse{DeletionProgress: v1.DeleteRelationshipsResponse_DELETION_PROGRESS_COMPLETE}, nil
+}
+
+func TestDeleteRelationships(t *testing.T) {
+	tests := []struct {
+		name         string
+		existing     int
+		err          error
+		wantRequests int
+		wantErr      string
+	}{
+		{
+			name:         "no relationships",
+			wantRequests: 1,
+		},
+		{
+			name:         "single batch",
+			existing:     deleteBatchSize,
+			wantRequests: 1,
+		},
+		{
+			name:         "multiple batches",
+			existing:     2*deleteBatchSize + 1,
+			wantRequests: 3,
+		},
+		{
+			name:         "error",
+			existing:     2 * deleteBatchSize,
+			err:          errors.New("unavailable"),
+			wantRequests: 1,
+			wantErr:      "error deleting relationships for document#viewer: unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakePermissionsClient{remaining: tt.existing, err: tt.err}
+			err := DeleteRelationships(context.Background(), &Client{PermissionsServiceClient: fake}, "document#viewer")
+			require.Len(t, fake.requests, tt.wantRequests)
+			for _, req := range fake.requests {
+				require.Equal(t, "document", req.RelationshipFilter.ResourceType)
+				require.Equal(t, "viewer", req.RelationshipFilter.OptionalRelation)
+			}
+			if len(tt.wantErr) > 0 {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Zero(t, fake.remaining)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package spicedb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// SchemaDiff describes the changes between two schemas. Relations and
+// permissions are named `definition#name`.
+type SchemaDiff struct {
+	AddedDefinitions   []string
+	RemovedDefinitions []string
+	AddedRelations     []string
+	RemovedRelations   []string
+	AddedPermissions   []string
+	RemovedPermissions []string
+
+	// Changes counts every difference SpiceDB reported, including the ones
+	// that don't add or remove anything.
+	Changes int
+}
+
+// DiffSchema computes the difference between the schema written to the
+// cluster and the desired schema. SpiceDB compiles both schemas, so the
+// diff is based on the same definitions that WriteSchema would store, and
+// an invalid desired schema is reported with codes.InvalidArgument.
+func DiffSchema(ctx context.Context, client *Client, desired string) (*SchemaDiff, error) {
+	resp, err := client.ExperimentalDiffSchema(ctx, &v1.ExperimentalDiffSchemaRequest{
+		Consistency:      &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		ComparisonSchema: desired,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error diffing schema: %w", err)
+	}
+	return NewSchemaDiff(resp.GetDiffs()), nil
+}
+
+// NewSchemaDiff summarizes the diffs returned by SpiceDB. The relations and
+// permissions of added and removed definitions are included with the other
+// added and removed members, since removing a definition has the same effect
+// on data as removing each of its relations. Changes that don't add or
+// remove anything, like edited comments or permission expressions, are left
+// out.
+func NewSchemaDiff(diffs []*v1.ExpSchemaDiff) *SchemaDiff {
+	diff := &SchemaDiff{Changes: len(diffs)}
+	for _, d := range diffs {
+		switch d := d.GetDiff().(type) {
+		case *v1.ExpSchemaDiff_DefinitionAdded:
+			def := d.DefinitionAdded
+			diff.AddedDefinitions = append(diff.AddedDefinitions, def.GetName())
+			diff.AddedRelations = append(diff.AddedRelations, relationNames(def.GetRelations())...)
+			diff.AddedPermissions = append(diff.AddedPermissions, permissionNames(def.GetPermissions())...)
+		case *v1.ExpSchemaDiff_DefinitionRemoved:
+			def := d.DefinitionRemoved
+			diff.RemovedDefinitions = append(diff.RemovedDefinitions, def.GetName())
+			diff.RemovedRelations = append(diff.RemovedRelations, relationNames(def.GetRelations())...)
+			diff.RemovedPermissions = append(diff.RemovedPermissions, permissionNames(def.GetPermissions())...)
+		case *v1.ExpSchemaDiff_RelationAdded:
+			diff.AddedRelations = append(diff.AddedRelations, relationNames([]*v1.ExpRelation{d.RelationAdded})...)
+		case *v1.ExpSchemaDiff_RelationRemoved:
+			diff.RemovedRelations = append(diff.RemovedRelations, relationNames([]*v1.ExpRelation{d.RelationRemoved})...)
+		case *v1.ExpSchemaDiff_PermissionAdded:
+			diff.AddedPermissions = append(diff.AddedPermissions, permissionNames([]*v1.ExpPermission{d.PermissionAdded})...)
+		case *v1.ExpSchemaDiff_PermissionRemoved:
+			diff.RemovedPermissions = append(diff.RemovedPermissions, permissionNames([]*v1.ExpPermission{d.PermissionRemoved})...)
+		}
+	}
+
+	for _, l := range []*[]string{
+		&diff.AddedDefinitions, &diff.RemovedDefinitions,
+		&diff.AddedRelations, &diff.RemovedRelations,
+		&diff.AddedPermissions, &diff.RemovedPermissions,
+	} {
+		slices.Sort(*l)
+		*l = slices.Compact(*l)
+	}
+	return diff
+}
+
+func relationNames(relations []*v1.ExpRelation) []string {
+	names := make([]string, 0, len(relations))
+	for _, r := range relations {
+		names = append(names, r.GetParentDefinitionName()+"#"+r.GetName())
+	}
+	return names
+}
+
+func permissionNames(permissions []*v1.ExpPermission) []string {
+	names := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		names = append(names, p.GetParentDefinitionName()+"#"+p.GetName())
+	}
+	return names
+}
+
+// Empty returns true if the schemas define the same relations and
+// permissions.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.AddedDefinitions)+len(d.RemovedDefinitions)+
+		len(d.AddedRelations)+len(d.RemovedRelations)+
+		len(d.AddedPermissions)+len(d.RemovedPermissions) == 0
+}
+
+// String summarizes the diff for use in status messages.
+func (d *SchemaDiff) String() string {
+	parts := make([]string, 0)
+	for _, p := range []struct {
+		desc  string
+		items []string
+	}{
+		{"added definitions", d.AddedDefinitions},
+		{"removed definitions", d.RemovedDefinitions},
+		{"added relations", d.AddedRelations},
+		{"removed relations", d.RemovedRelations},
+		{"added permissions", d.AddedPermissions},
+		{"removed permissions", d.RemovedPermissions},
+	} {
+		if len(p.items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", p.desc, strings.Join(p.items, ", ")))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes to definitions, relations or permissions"
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,141 @@
+package spicedb
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewSchemaDiff(t *testing.T) {
+	relation := func(definition, name string) *v1.ExpRelation {
+		return &v1.ExpRelation{ParentDefinitionName: definition, Name: name}
+	}
+	permission := func(definition, name string) *v1.ExpPermission {
+		return &v1.ExpPermission{ParentDefinitionName: definition, Name: name}
+	}
+	document := &v1.ExpDefinition{
+		Name:        "document",
+		Relations:   []*v1.ExpRelation{relation("document", "viewer"), relation("document", "owner")},
+		Permissions: []*v1.ExpPermission{permission("document", "view")},
+	}
+
+	tests := []struct {
+		name      string
+		diffs     []*v1.ExpSchemaDiff
+		want      *SchemaDiff
+		wantEmpty bool
+	}{
+		{
+			name:      "no changes",
+			want:      &SchemaDiff{},
+			wantEmpty: true,
+		},
+		{
+			name: "comment and expression changes are ignored",
+			diffs: []*v1.ExpSchemaDiff{
+				{Diff: &v1.ExpSchemaDiff_DefinitionDocCommentChanged{DefinitionDocCommentChanged: document}},
+				{Diff: &v1.ExpSchemaDiff_PermissionExprChanged{PermissionExprChanged: permission("document", "view")}},
+			},
+			want:      &SchemaDiff{},
+			wantEmpty: true,
+		},
+		{
+			name: "added definition adds its members",
+			diffs: []*v1.ExpSchemaDiff{
+				{Diff: &v1.ExpSchemaDiff_DefinitionAdded{DefinitionAdded: document}},
+			},
+			want: &SchemaDiff{
+				AddedDefinitions: []string{"document"},
+				AddedRelations:   []string{"document#owner", "document#viewer"},
+				AddedPermissions: []string{"document#view"},
+			},
+		},
+		{
+			name: "added and removed members",
+			diffs: []*v1.ExpSchemaDiff{
+				{Diff: &v1.ExpSchemaDiff_RelationAdded{RelationAdded: relation("document", "editor")}},
+				{Diff: &v1.ExpSchemaDiff_RelationRemoved{RelationRemoved: relation("document", "viewer")}},
+				{Diff: &v1.ExpSchemaDiff_PermissionAdded{PermissionAdded: permission("document", "edit")}},
+				{Diff: &v1.ExpSchemaDiff_PermissionRemoved{PermissionRemoved: permission("document", "share")}},
+			},
+			want: &SchemaDiff{
+				AddedRelations:     []string{"document#editor"},
+				RemovedRelations:   []string{"document#viewer"},
+				AddedPermissions:   []string{"document#edit"},
+				RemovedPermissions: []string{"document#share"},
+			},
+		},
+		{
+			name: "removed definition removes its members once",
+			diffs: []*v1.ExpSchemaDiff{
+				{Diff: &v1.ExpSchemaDiff_DefinitionRemoved{DefinitionRemoved: document}},
+				{Diff: &v1.ExpSchemaDiff_RelationRemoved{RelationRemoved: relation("document", "viewer")}},
+			},
+			want: &SchemaDiff{
+				RemovedDefinitions: []string{"document"},
+				RemovedRelations:   []string{"document#owner", "document#viewer"},
+				RemovedPermissions: []string{"document#view"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewSchemaDiff(tt.diffs)
+			require.Equal(t, tt.want.AddedDefinitions, got.AddedDefinitions)
+			require.Equal(t, tt.want.RemovedDefinitions, got.RemovedDefinitions)
+			require.Equal(t, tt.want.AddedRelations, got.AddedRelations)
+			require.Equal(t, tt.want.RemovedRelations, got.RemovedRelations)
+			require.Equal(t, tt.want.AddedPermissions, got.AddedPermissions)
+			require.Equal(t, tt.want.RemovedPermissions, got.RemovedPermissions)
+			require.Equal(t, tt.wantEmpty, got.Empty())
+			require.Equal(t, len(tt.diffs), got.Changes)
+		})
+	}
+}
+
+// fakeExperimentalClient returns a fixed diff for any schema.
+type fakeExperimentalClient struct {
+	v1.ExperimentalServiceClient
+
+	request *v1.ExperimentalDiffSchemaRequest
+	diffs   []*v1.ExpSchemaDiff
+	err     error
+}
+
+func (f *fakeExperimentalClient) ExperimentalDiffSchema(_ context.Context, in *v1.ExperimentalDiffSchemaRequest, _ ...grpc.CallOption) (*v1.ExperimentalDiffSchemaResponse, error) {
+	f.request = in
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &v1.ExperimentalDiffSchemaResponse{Diffs: f.diffs}, nil
+}
+
+func TestDiffSchema(t *testing.T) {
+	fake := &fakeExperimentalClient{diffs: []*v1.ExpSchemaDiff{
+		{Diff: &v1.ExpSchemaDiff_DefinitionAdded{DefinitionAdded: &v1.ExpDefinition{Name: "user"}}},
+	}}
+	diff, err := DiffSchema(context.Background(), &Client{ExperimentalServiceClient: fake}, "definition user {}")
+	require.NoError(t, err)
+	require.Equal(t, []string{"user"}, diff.AddedDefinitions)
+	require.Equal(t, "definition user {}", fake.request.ComparisonSchema)
+	require.True(t, fake.request.Consistency.GetFullyConsistent())
+
+	// compilation errors keep their code so that they aren't retried
+	fake.err = status.Error(codes.InvalidArgument, "parse error in `schema`, line 1, column 17: Expected end of statement or definition, found: TokenTypeEOF")
+	_, err = DiffSchema(context.Background(), &Client{ExperimentalServiceClient: fake}, "definition user {")
+	require.ErrorContains(t, err, "error diffing schema: ")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSchemaDiffString(t *testing.T) {
+	require.Equal(t, "no changes to definitions, relations or permissions", (&SchemaDiff{}).String())
+	require.Equal(t, "added definitions: folder; removed relations: document#viewer, folder#owner", (&SchemaDiff{
+		AddedDefinitions: []string{"folder"},
+		RemovedRelations: []string{"document#viewer", "folder#owner"},
+	}).String())
+}
@@ -0,0 +1,97 @@
+package spicedb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ParseCheck parses a check of the form
+// `resourceType:resourceID#permission@subjectType:subjectID[#relation]`
+// into a fully consistent CheckPermissionRequest.
+func ParseCheck(check string) (*v1.CheckPermissionRequest, error) {
+	resource, subject, ok := strings.Cut(check, "@")
+	if !ok {
+		return nil, fmt.Errorf("invalid check %q: expected resource#permission@subject", check)
+	}
+	resource, permission, ok := strings.Cut(resource, "#")
+	if !ok || len(permission) == 0 {
+		return nil, fmt.Errorf("invalid check %q: missing permission", check)
+	}
+	resourceRef, err := parseObject(resource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid check %q: %w", check, err)
+	}
+	subjectRef, err := parseSubject(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid check %q: %w", check, err)
+	}
+
+	return &v1.CheckPermissionRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		Resource:    resourceRef,
+		Permission:  permission,
+		Subject:     subjectRef,
+	}, nil
+}
+
+func parseSubject(subject string) (*v1.SubjectReference, error) {
+	subject, relation, _ := strings.Cut(subject, "#")
+	subjectRef, err := parseObject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.SubjectReference{
+		Object:           subjectRef,
+		OptionalRelation: relation,
+	}, nil
+}
+
+func parseObject(object string) (*v1.ObjectReference, error) {
+	objectType, objectID, ok := strings.Cut(object, ":")
+	if !ok || len(objectType) == 0 || len(objectID) == 0 {
+		return nil, fmt.Errorf("expected an object of the form type:id, got %q", object)
+	}
+	return &v1.ObjectReference{ObjectType: objectType, ObjectId: objectID}, nil
+}
+
+// VerifyRollout issues a ReadSchema request (and check, if provided) against
+// the SpiceDB API to confirm that a cluster is serving requests. A cluster
+// without a schema is considered healthy.
+func VerifyRollout(ctx context.Context, client *Client, check *v1.CheckPermissionRequest) error {
+	if _, err := client.ReadSchema(ctx, &v1.ReadSchemaRequest{}); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("error reading schema: %w", err)
+	}
+
+	if check == nil {
+		return nil
+	}
+
+	resp, err := client.CheckPermission(ctx, check)
+	if err != nil {
+		return fmt.Errorf("error checking permission %s: %w", FormatCheck(check), err)
+	}
+	if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED {
+		return fmt.Errorf("unexpected response checking permission %s", FormatCheck(check))
+	}
+	return nil
+}
+
+// FormatCheck is the inverse of ParseCheck.
+func FormatCheck(check *v1.CheckPermissionRequest) string {
+	out := fmt.Sprintf("%s:%s#%s@%s:%s",
+		check.GetResource().GetObjectType(),
+		check.GetResource().GetObjectId(),
+		check.GetPermission(),
+		check.GetSubject().GetObject().GetObjectType(),
+		check.GetSubject().GetObject().GetObjectId(),
+	)
+	if relation := check.GetSubject().GetOptionalRelation(); len(relation) > 0 {
+		out += "#" + relation
+	}
+	return out
+}
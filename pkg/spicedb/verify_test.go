@@ -0,0 +1,56 @@
+package spicedb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   string
+		wantErr bool
+	}{
+		{
+			name:  "object subject",
+			check: "document:readme#view@user:alice",
+		},
+		{
+			name:  "subject with relation",
+			check: "document:readme#view@group:eng#member",
+		},
+		{
+			name:    "missing subject",
+			check:   "document:readme#view",
+			wantErr: true,
+		},
+		{
+			name:    "missing permission",
+			check:   "document:readme@user:alice",
+			wantErr: true,
+		},
+		{
+			name:    "missing resource id",
+			check:   "document#view@user:alice",
+			wantErr: true,
+		},
+		{
+			name:    "missing subject id",
+			check:   "document:readme#view@user",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCheck(tt.check)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, got.GetConsistency().GetFullyConsistent())
+			require.Equal(t, tt.check, FormatCheck(got))
+		})
+	}
+}
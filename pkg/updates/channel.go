@@ -0,0 +1,72 @@
+package updates
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// ChannelFromAPI converts a SpiceDBChannel into a channel. Admission only
+// checks that the nodes and edges are well-formed, so the channel is also
+// checked for a path to the head from every node and valid lifecycle dates.
+func ChannelFromAPI(in *v1alpha1.SpiceDBChannel) (Channel, error) {
+	c := Channel{
+		Name:     in.ChannelName(),
+		Metadata: map[string]string{DatastoreMetadataKey: in.Spec.Datastore},
+		Nodes:    make([]State, 0, len(in.Spec.Nodes)),
+	}
+	for _, n := range in.Spec.Nodes {
+		c.Nodes = append(c.Nodes, State{
+			ID:              n.ID,
+			Tag:             n.Tag,
+			Migration:       n.Migration,
+			Phase:           n.Phase,
+			Digest:          n.Digest,
+			Deprecated:      n.Deprecated,
+			DeprecatedSince: n.DeprecatedSince,
+			EndOfLife:       n.EndOfLife,
+		})
+	}
+	if len(in.Spec.Edges) > 0 {
+		c.Edges = make(EdgeSet, len(in.Spec.Edges))
+		for from, to := range in.Spec.Edges {
+			c.Edges[from] = slices.Clone(to)
+		}
+	}
+	if len(in.Spec.Rollbacks) > 0 {
+		c.Rollbacks = make(RollbackSet, len(in.Spec.Rollbacks))
+		for from, to := range in.Spec.Rollbacks {
+			for _, r := range to {
+				c.Rollbacks[from] = append(c.Rollbacks[from], Rollback{To: r.To})
+			}
+		}
+	}
+	if len(in.Spec.IncompatibleDispatch) > 0 {
+		c.IncompatibleDispatch = make(EdgeSet, len(in.Spec.IncompatibleDispatch))
+		for from, to := range in.Spec.IncompatibleDispatch {
+			c.IncompatibleDispatch[from] = slices.Clone(to)
+		}
+	}
+
+	if _, err := NewMemorySource(c.Nodes, c.Edges); err != nil {
+		return Channel{}, fmt.Errorf("invalid channel %s/%s: %w", in.Spec.Datastore, c.Name, err)
+	}
+	return c, nil
+}
+
+// WithChannels returns a copy of the graph with the channels added to it.
+// Channels that are already in the graph (or earlier in the list) are left
+// out and returned so that the conflict can be reported; the channels in the
+// graph always take precedence.
+func (g *UpdateGraph) WithChannels(channels ...Channel) (merged UpdateGraph, conflicts []Channel) {
+	merged = g.Copy()
+	for _, c := range channels {
+		if _, ok := merged.channel(c.Metadata[DatastoreMetadataKey], c.Name); ok {
+			conflicts = append(conflicts, c)
+			continue
+		}
+		merged.Channels = append(merged.Channels, c)
+	}
+	return merged, conflicts
+}
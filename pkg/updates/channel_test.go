@@ -0,0 +1,109 @@
+package updates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+func TestChannelFromAPI(t *testing.T) {
+	tests := []struct {
+		name        string
+		channel     *v1alpha1.SpiceDBChannel
+		expected    Channel
+		expectedErr string
+	}{
+		{
+			name: "converts nodes, edges, and rollbacks",
+			channel: &v1alpha1.SpiceDBChannel{
+				ObjectMeta: metav1.ObjectMeta{Name: "hotfix"},
+				Spec: v1alpha1.ChannelSpec{
+					Datastore: "postgres",
+					Nodes: []v1alpha1.ChannelNode{
+						{ID: "v1.1.0-hotfix", Tag: "v1.1.0-hotfix", Migration: "b"},
+						{ID: "v1.0.0", Digest: "sha256:abc", Migration: "a", EndOfLife: "2026-01-01"},
+					},
+					Edges:                map[string]v1alpha1.ChannelEdges{"v1.0.0": {"v1.1.0-hotfix"}},
+					Rollbacks:            map[string]v1alpha1.ChannelRollbacks{"v1.1.0-hotfix": {{To: "v1.0.0"}}},
+					IncompatibleDispatch: map[string]v1alpha1.ChannelEdges{"v1.0.0": {"v1.1.0-hotfix"}},
+				},
+			},
+			expected: Channel{
+				Name:     "hotfix",
+				Metadata: map[string]string{"datastore": "postgres"},
+				Nodes: []State{
+					{ID: "v1.1.0-hotfix", Tag: "v1.1.0-hotfix", Migration: "b"},
+					{ID: "v1.0.0", Digest: "sha256:abc", Migration: "a", EndOfLife: "2026-01-01"},
+				},
+				Edges:                EdgeSet{"v1.0.0": {"v1.1.0-hotfix"}},
+				Rollbacks:            RollbackSet{"v1.1.0-hotfix": {{To: "v1.0.0"}}},
+				IncompatibleDispatch: EdgeSet{"v1.0.0": {"v1.1.0-hotfix"}},
+			},
+		},
+		{
+			name: "channel name overrides the object name",
+			channel: &v1alpha1.SpiceDBChannel{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a-hotfix"},
+				Spec: v1alpha1.ChannelSpec{
+					Channel:   "hotfix",
+					Datastore: "memory",
+					Nodes:     []v1alpha1.ChannelNode{{ID: "v1.0.1", Tag: "v1.0.1"}, {ID: "v1.0.0", Tag: "v1.0.0"}},
+					Edges:     map[string]v1alpha1.ChannelEdges{"v1.0.0": {"v1.0.1"}},
+				},
+			},
+			expected: Channel{
+				Name:     "hotfix",
+				Metadata: map[string]string{"datastore": "memory"},
+				Nodes:    []State{{ID: "v1.0.1", Tag: "v1.0.1"}, {ID: "v1.0.0", Tag: "v1.0.0"}},
+				Edges:    EdgeSet{"v1.0.0": {"v1.0.1"}},
+			},
+		},
+		{
+			name: "no path to head",
+			channel: &v1alpha1.SpiceDBChannel{
+				ObjectMeta: metav1.ObjectMeta{Name: "hotfix"},
+				Spec: v1alpha1.ChannelSpec{
+					Datastore: "memory",
+					Nodes:     []v1alpha1.ChannelNode{{ID: "v1.0.2", Tag: "v1.0.2"}, {ID: "v1.0.1", Tag: "v1.0.1"}, {ID: "v1.0.0", Tag: "v1.0.0"}},
+					Edges:     map[string]v1alpha1.ChannelEdges{"v1.0.0": {"v1.0.1"}, "v1.0.1": {}},
+				},
+			},
+			expectedErr: "invalid channel memory/hotfix: v1.0.1 has no outgoing edges, but it is not the head of the channel",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, err := ChannelFromAPI(tt.channel)
+			if len(tt.expectedErr) > 0 {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, channel)
+		})
+	}
+}
+
+func TestWithChannels(t *testing.T) {
+	stable := Channel{
+		Name:     "stable",
+		Metadata: map[string]string{"datastore": "postgres", "default": "true"},
+		Nodes:    []State{{ID: "v1.0.0"}},
+	}
+	graph := UpdateGraph{Channels: []Channel{stable}}
+
+	hotfix := Channel{Name: "hotfix", Metadata: map[string]string{"datastore": "postgres"}, Nodes: []State{{ID: "v1.0.1"}}}
+	otherHotfix := Channel{Name: "Hotfix", Metadata: map[string]string{"datastore": "postgres"}, Nodes: []State{{ID: "v1.0.2"}}}
+	mysqlStable := Channel{Name: "stable", Metadata: map[string]string{"datastore": "mysql"}, Nodes: []State{{ID: "v1.0.0"}}}
+	shadowed := Channel{Name: "stable", Metadata: map[string]string{"datastore": "postgres"}, Nodes: []State{{ID: "v9.0.0"}}}
+
+	merged, conflicts := graph.WithChannels(hotfix, shadowed, mysqlStable, otherHotfix)
+	require.Equal(t, []Channel{stable, hotfix, mysqlStable}, merged.Channels)
+	require.Equal(t, []Channel{shadowed, otherHotfix}, conflicts)
+
+	// the original graph isn't modified
+	require.Equal(t, []Channel{stable}, graph.Channels)
+}
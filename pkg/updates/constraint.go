@@ -0,0 +1,85 @@
+package updates
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// parseConstraint parses a semver constraint like `~1.33` or `>=1.30 <1.35`.
+func parseConstraint(constraint string) (*semver.Constraints, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	return c, nil
+}
+
+// satisfies returns true if id is a semantic version that satisfies the
+// constraint. Node ids that aren't versions never satisfy a constraint.
+func satisfies(c *semver.Constraints, id string) bool {
+	v, err := semver.NewVersion(id)
+	if err != nil {
+		return false
+	}
+	return c.Check(v)
+}
+
+// SatisfiesConstraint returns true if the version satisfies the constraint.
+func SatisfiesConstraint(constraint, version string) (bool, error) {
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	return satisfies(c, version), nil
+}
+
+// resolveConstraint returns the newest version in the channel that satisfies
+// the constraint. Deprecated versions are never picked as a new target, but
+// a current version that satisfies the constraint is kept (even once it's
+// deprecated) if nothing newer does, so that a cluster is never moved to an
+// older version when a lifecycle date passes. A current version outside the
+// constraint is never kept.
+func (g *UpdateGraph) resolveConstraint(engine, channel, constraint, current string, now time.Time) (string, error) {
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	ch, ok := g.channel(engine, channel)
+	if !ok {
+		return "", fmt.Errorf("no channel for %q found with name %q", engine, channel)
+	}
+	// nodes are ordered newest first
+	for _, n := range ch.Nodes {
+		if !satisfies(c, n.ID) {
+			continue
+		}
+		if len(current) > 0 && n.ID == current {
+			return current, nil
+		}
+		if !n.IsDeprecated(now) {
+			return n.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no version in channel %q satisfies %q", channel, constraint)
+}
+
+// annotateConstraint marks each version with whether it satisfies the
+// constraint.
+func annotateConstraint(versions []v1alpha1.SpiceDBVersion, constraint string) error {
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return err
+	}
+	for i := range versions {
+		if satisfies(c, versions[i].Name) {
+			versions[i].Attributes = append(versions[i].Attributes, v1alpha1.SpiceDBVersionAttributesSatisfiesConstraint)
+		} else {
+			versions[i].Attributes = append(versions[i].Attributes, v1alpha1.SpiceDBVersionAttributesOutsideConstraint)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package updates
+
+import (
+	"slices"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// dispatchCompatible returns false if the channel declares that the two
+// versions can't dispatch to each other. Incompatibility goes both ways, so
+// it doesn't matter which end of the edge it's declared on.
+func (c Channel) dispatchCompatible(a, b string) bool {
+	return !slices.Contains(c.IncompatibleDispatch[a], b) && !slices.Contains(c.IncompatibleDispatch[b], a)
+}
+
+// markIncompatibleDispatch sets the incompatibleDispatch attribute on target
+// if pods running `from` can't dispatch to pods running the target. Any
+// attribute carried over from an earlier update is dropped first.
+func (g *UpdateGraph) markIncompatibleDispatch(engine, from string, target *v1alpha1.SpiceDBVersion) {
+	target.Attributes = slices.DeleteFunc(target.Attributes, func(a v1alpha1.SpiceDBVersionAttributes) bool {
+		return a == v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch
+	})
+	c, ok := g.channel(engine, target.Channel)
+	if !ok || c.dispatchCompatible(from, target.Name) {
+		return
+	}
+	target.Attributes = append(target.Attributes, v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch)
+}
+
+// annotateDispatch marks each version that v can't dispatch to.
+func (g *UpdateGraph) annotateDispatch(engine string, v v1alpha1.SpiceDBVersion, versions []v1alpha1.SpiceDBVersion) {
+	for i := range versions {
+		c, ok := g.channel(engine, versions[i].Channel)
+		if !ok || c.dispatchCompatible(v.Name, versions[i].Name) {
+			continue
+		}
+		versions[i].Attributes = append(versions[i].Attributes, v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch)
+		versions[i].Description += ", pods will not dispatch across versions during the update"
+	}
+}
@@ -0,0 +1,111 @@
+package updates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// dispatchGraph has a dispatch API change between v1.0.1 and v1.1.0
+func dispatchGraph() *UpdateGraph {
+	return &UpdateGraph{Channels: []Channel{{
+		Name:     "postgres",
+		Metadata: map[string]string{"datastore": "postgres"},
+		Edges: EdgeSet{
+			"v1.0.0": {"v1.0.1"},
+			"v1.0.1": {"v1.1.0"},
+			"v1.1.0": {"v1.1.1"},
+			"v1.1.1": {},
+		},
+		IncompatibleDispatch: EdgeSet{"v1.0.1": {"v1.1.0"}},
+		Rollbacks:            RollbackSet{"v1.1.0": {{To: "v1.0.1"}}},
+		Nodes: []State{
+			{ID: "v1.1.1", Tag: "v1.1.1", Migration: "a"},
+			{ID: "v1.1.0", Tag: "v1.1.0", Migration: "a"},
+			{ID: "v1.0.1", Tag: "v1.0.1", Migration: "a"},
+			{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+		},
+	}}}
+}
+
+func TestComputeTargetIncompatibleDispatch(t *testing.T) {
+	incompatible := []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch}
+	tests := []struct {
+		name           string
+		current        *v1alpha1.SpiceDBVersion
+		version        string
+		rolling        bool
+		expectedTarget *v1alpha1.SpiceDBVersion
+	}{
+		{
+			name:           "compatible update",
+			current:        &v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: "postgres"},
+			expectedTarget: &v1alpha1.SpiceDBVersion{Name: "v1.0.1", Channel: "postgres"},
+		},
+		{
+			name:           "incompatible update",
+			current:        &v1alpha1.SpiceDBVersion{Name: "v1.0.1", Channel: "postgres"},
+			expectedTarget: &v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres", Attributes: incompatible},
+		},
+		{
+			name:           "incompatible update stays marked while rolling",
+			current:        &v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres", Attributes: incompatible},
+			rolling:        true,
+			expectedTarget: &v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres", Attributes: incompatible},
+		},
+		{
+			name:           "next compatible update drops the mark",
+			current:        &v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres", Attributes: incompatible},
+			expectedTarget: &v1alpha1.SpiceDBVersion{Name: "v1.1.1", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{}},
+		},
+		{
+			name:    "rollbacks across an incompatible edge",
+			current: &v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres"},
+			version: "v1.0.1",
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:       "v1.0.1",
+				Channel:    "postgres",
+				Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesRollback, v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, target, _, err := dispatchGraph().ComputeTarget("image", "", tt.version, "", "postgres", "postgres", tt.current, tt.rolling, time.Now())
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTarget, target)
+		})
+	}
+}
+
+func TestAvailableVersionsIncompatibleDispatch(t *testing.T) {
+	versions, err := dispatchGraph().AvailableVersions("postgres", v1alpha1.SpiceDBVersion{Name: "v1.0.1", Channel: "postgres"}, "", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []v1alpha1.SpiceDBVersion{
+		{
+			Name:        "v1.1.0",
+			Channel:     "postgres",
+			Attributes:  []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesNext, v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch},
+			Description: "direct update with no migrations, pods will not dispatch across versions during the update",
+		},
+		{
+			Name:        "v1.1.1",
+			Channel:     "postgres",
+			Attributes:  []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesLatest, v1alpha1.SpiceDBVersionAttributesMigration},
+			Description: "head of the channel, multiple updates will run in sequence",
+		},
+	}, versions)
+}
+
+func TestPlannedPathIncompatibleDispatch(t *testing.T) {
+	path, err := dispatchGraph().PlannedPath("postgres", v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: "postgres"}, "", "", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []v1alpha1.SpiceDBVersion{
+		{Name: "v1.0.1", Channel: "postgres"},
+		{Name: "v1.1.0", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesIncompatibleDispatch}},
+		{Name: "v1.1.1", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesLatest}},
+	}, path)
+}
@@ -3,6 +3,7 @@ package updates
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jzelinskie/stringz"
 	"github.com/samber/lo"
@@ -28,6 +29,16 @@ type Channel struct {
 	// Edges are the transitions between states in the update graph.
 	Edges EdgeSet `json:"edges,omitempty"`
 
+	// Rollbacks are transitions from newer states back to older ones. A
+	// cluster can only be set to an older version if there's a rollback,
+	// and only if the versions share a migration and phase.
+	Rollbacks RollbackSet `json:"rollbacks,omitempty"`
+
+	// IncompatibleDispatch lists the edges (or rollbacks) between versions
+	// that can't dispatch to each other. Pods on either end of one of these
+	// edges must not be in the same dispatch ring during a rollout.
+	IncompatibleDispatch EdgeSet `json:"incompatibleDispatch,omitempty"`
+
 	// Nodes are the possible states in an update graph.
 	Nodes []State `json:"nodes,omitempty"`
 }
@@ -49,6 +60,12 @@ func (c Channel) Clone() Channel {
 		Edges: lo.MapEntries(c.Edges, func(k string, v []string) (string, []string) {
 			return k, slices.Clone(v)
 		}),
+		Rollbacks: lo.MapEntries(c.Rollbacks, func(k string, v []Rollback) (string, []Rollback) {
+			return k, slices.Clone(v)
+		}),
+		IncompatibleDispatch: lo.MapEntries(c.IncompatibleDispatch, func(k string, v []string) (string, []string) {
+			return k, slices.Clone(v)
+		}),
 		Nodes: slices.Clone(c.Nodes),
 	}
 }
@@ -67,6 +84,22 @@ func (c Channel) RemoveNodes(nodes []State) Channel {
 			}
 		}
 
+		// remove rollbacks to/from removed nodes
+		delete(c.Rollbacks, n.ID)
+		for from, rollbacks := range c.Rollbacks {
+			c.Rollbacks[from] = slices.DeleteFunc(rollbacks, func(r Rollback) bool {
+				return r.To == n.ID
+			})
+		}
+
+		// remove dispatch incompatibilities to/from removed nodes
+		delete(c.IncompatibleDispatch, n.ID)
+		for from, to := range c.IncompatibleDispatch {
+			c.IncompatibleDispatch[from] = slices.DeleteFunc(to, func(id string) bool {
+				return id == n.ID
+			})
+		}
+
 		// remove node from node list
 		idx := slices.Index(c.Nodes, n)
 		if idx < 0 {
@@ -87,12 +120,23 @@ type State struct {
 	Digest    string `json:"digest,omitempty"`
 
 	// Deprecated releases can be updated from, but not to
-	Deprecated bool `json:"-"`
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// DeprecatedSince is the date (YYYY-MM-DD) the release is deprecated
+	// from. The release is treated as deprecated on and after that date.
+	DeprecatedSince string `json:"deprecatedSince,omitempty"`
+
+	// EndOfLife is the date (YYYY-MM-DD) the release stops being supported.
+	EndOfLife string `json:"endOfLife,omitempty"`
 }
 
 // UpdateGraph holds a graph of required update edges
 type UpdateGraph struct {
 	Channels []Channel `json:"channels,omitempty"`
+
+	// PlanStrategy decides which path is taken when there's more than one
+	// way to update to a version. Defaults to fewestMigrations.
+	PlanStrategy PlanStrategy `json:"planStrategy,omitempty"`
 }
 
 // DefaultChannelForDatastore returns the first channel for a specific datastore.
@@ -120,12 +164,14 @@ func (g *UpdateGraph) SourceForChannel(engine, channel string) (Source, error) {
 // Copy returns a copy of the graph. The controller gets a copy so that
 // the graph doesn't change during a single reconciliation.
 func (g *UpdateGraph) Copy() UpdateGraph {
-	return UpdateGraph{Channels: slices.Clone(g.Channels)}
+	return UpdateGraph{Channels: slices.Clone(g.Channels), PlanStrategy: g.PlanStrategy}
 }
 
 // AvailableVersions traverses an UpdateGraph and collects a list of the
-// safe versions for updating from the provided currentVersion.
-func (g *UpdateGraph) AvailableVersions(engine string, v v1alpha1.SpiceDBVersion) ([]v1alpha1.SpiceDBVersion, error) {
+// safe versions for updating from the provided currentVersion. If a version
+// constraint is provided, each version is marked with whether it satisfies
+// the constraint, and versions that are deprecated as of now are marked.
+func (g *UpdateGraph) AvailableVersions(engine string, v v1alpha1.SpiceDBVersion, constraint string, now time.Time) ([]v1alpha1.SpiceDBVersion, error) {
 	source, err := g.SourceForChannel(engine, v.Channel)
 	if err != nil {
 		return nil, fmt.Errorf("no source found for channel %q, can't compute available versions: %w", v.Channel, err)
@@ -135,7 +181,6 @@ func (g *UpdateGraph) AvailableVersions(engine string, v v1alpha1.SpiceDBVersion
 	nextWithoutMigrations := source.NextVersionWithoutMigrations(v.Name)
 	latest := source.LatestVersion(v.Name)
 	if len(nextWithoutMigrations) > 0 {
-		// TODO: should also account for downtime, i.e. dispatch api changes
 		nextDirectVersion := v1alpha1.SpiceDBVersion{
 			Name:        nextWithoutMigrations,
 			Channel:     v.Channel,
@@ -172,6 +217,8 @@ func (g *UpdateGraph) AvailableVersions(engine string, v v1alpha1.SpiceDBVersion
 		})
 	}
 
+	availableVersions = append(availableVersions, g.availableRollbacks(engine, source, v)...)
+
 	// Check for options in other channels, but only show the safest update for
 	// for each available channel.
 	for _, c := range g.Channels {
@@ -204,6 +251,15 @@ func (g *UpdateGraph) AvailableVersions(engine string, v v1alpha1.SpiceDBVersion
 		}
 	}
 
+	g.annotateDispatch(engine, v, availableVersions)
+	g.annotateLifecycle(engine, availableVersions, now)
+
+	if len(constraint) > 0 {
+		if err := annotateConstraint(availableVersions, constraint); err != nil {
+			return nil, err
+		}
+	}
+
 	return availableVersions, nil
 }
 
@@ -220,8 +276,9 @@ func explodeImage(image string) (baseImage, tag, digest string) {
 }
 
 // ComputeTarget determines the target update version and state given an update
-// graph and the proper context.
-func (g *UpdateGraph) ComputeTarget(defaultBaseImage, image, version, channel, engine string, currentVersion *v1alpha1.SpiceDBVersion, rolling bool) (baseImage string, target *v1alpha1.SpiceDBVersion, state State, err error) {
+// graph and the proper context. now decides which versions are deprecated
+// when resolving a version constraint.
+func (g *UpdateGraph) ComputeTarget(defaultBaseImage, image, version, constraint, channel, engine string, currentVersion *v1alpha1.SpiceDBVersion, rolling bool, now time.Time) (baseImage string, target *v1alpha1.SpiceDBVersion, state State, err error) {
 	baseImage, tag, digest := explodeImage(image)
 
 	// If digest or tag are set, don't use an update graph.
@@ -260,6 +317,23 @@ func (g *UpdateGraph) ComputeTarget(defaultBaseImage, image, version, channel, e
 		}
 	}
 
+	// A constraint selects the newest version in the channel that satisfies
+	// it, which is then treated as if it were the explicit version.
+	if len(constraint) > 0 {
+		if len(version) > 0 {
+			err = fmt.Errorf("version and versionConstraint can't both be set")
+			return
+		}
+		var current string
+		if currentVersion != nil {
+			current = currentVersion.Name
+		}
+		version, err = g.resolveConstraint(engine, channel, constraint, current, now)
+		if err != nil {
+			return
+		}
+	}
+
 	target = &v1alpha1.SpiceDBVersion{}
 	// Default to the currentVersion we're working towards.
 	if currentVersion != nil {
@@ -319,6 +393,24 @@ func (g *UpdateGraph) ComputeTarget(defaultBaseImage, image, version, channel, e
 		return
 	}
 
+	// If the explicit version can't be reached by updating from the current
+	// version, it's older, and can only be reached by a rollback edge.
+	if currentVersion != nil && len(version) > 0 && len(updateSource.State(currentVersion.Name).ID) > 0 && len(updateSource.State(version).ID) > 0 {
+		if _, planErr := updateSource.Plan(currentVersion.Name, version, g.PlanStrategy); planErr != nil {
+			state, target.Attributes, err = g.computeRollback(engine, channel, updateSource, currentVersion.Name, version)
+			if err != nil {
+				if len(constraint) > 0 {
+					err = fmt.Errorf("%s doesn't satisfy versionConstraint %q: %w", currentVersion.Name, constraint, err)
+				}
+				target = nil
+				return
+			}
+			target.Name = state.ID
+			g.markIncompatibleDispatch(engine, currentVersion.Name, target)
+			return
+		}
+	}
+
 	// If currentVersion is set, we only use the subset of the update graph that leads
 	// to that currentVersion.
 	if currentVersion != nil && len(version) > 0 {
@@ -331,13 +423,16 @@ func (g *UpdateGraph) ComputeTarget(defaultBaseImage, image, version, channel, e
 
 	var targetVersion string
 	if currentVersion != nil && len(currentVersion.Name) > 0 {
-		targetVersion = updateSource.NextVersion(currentVersion.Name)
-		if len(targetVersion) == 0 {
+		// Take the first step of the planned path to the head of the
+		// (sub)graph.
+		path, planErr := updateSource.Plan(currentVersion.Name, updateSource.LatestVersion(""), g.PlanStrategy)
+		if planErr != nil || len(path) == 0 {
 			// There's no next currentVersion, so use the current state.
 			state = currentState
 			return
 		}
-		if targetVersion != updateSource.NextVersionWithoutMigrations(currentVersion.Name) {
+		targetVersion = path[0]
+		if requiresMigration(currentState, updateSource.State(targetVersion)) {
 			target.Attributes = []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration}
 		}
 	} else {
@@ -349,6 +444,9 @@ func (g *UpdateGraph) ComputeTarget(defaultBaseImage, image, version, channel, e
 	// If we found the next step to take, return it.
 	state = updateSource.State(targetVersion)
 	target.Name = state.ID
+	if currentVersion != nil && len(currentVersion.Name) > 0 {
+		g.markIncompatibleDispatch(engine, currentVersion.Name, target)
+	}
 	return
 }
 
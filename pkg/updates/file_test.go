@@ -2,6 +2,7 @@ package updates
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -45,6 +46,7 @@ func TestAvailableVersions(t *testing.T) {
 		graph          *UpdateGraph
 		engine         string
 		currentVersion v1alpha1.SpiceDBVersion
+		constraint     string
 		expected       []v1alpha1.SpiceDBVersion
 		expectedErr    string
 	}{
@@ -152,11 +154,30 @@ func TestAvailableVersions(t *testing.T) {
 			currentVersion: v1alpha1.SpiceDBVersion{Name: "v1.0.1", Channel: "cockroachdb"},
 			expected:       []v1alpha1.SpiceDBVersion{{Name: "v1.1.0", Channel: "cockroachdb", Attributes: []v1alpha1.SpiceDBVersionAttributes{"next", "latest"}, Description: "direct update with no migrations, head of channel"}},
 		},
+		{
+			name: "marks versions that satisfy the constraint",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.0.0": {"v1.0.1", "v1.1.0"},
+					"v1.0.1": {"v1.1.0"},
+				},
+				Nodes: []State{{ID: "v1.1.0", Migration: "b"}, {ID: "v1.0.1"}, {ID: "v1.0.0"}},
+			}}},
+			engine:         "cockroachdb",
+			currentVersion: v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: "cockroachdb"},
+			constraint:     "~1.0",
+			expected: []v1alpha1.SpiceDBVersion{
+				{Name: "v1.0.1", Channel: "cockroachdb", Attributes: []v1alpha1.SpiceDBVersionAttributes{"next", "satisfiesConstraint"}, Description: "direct update with no migrations"},
+				{Name: "v1.1.0", Channel: "cockroachdb", Attributes: []v1alpha1.SpiceDBVersionAttributes{"next", "migration", "latest", "outsideConstraint"}, Description: "update will run a migration, head of channel"},
+			},
+		},
 	}
 
 	for _, tt := range table {
 		t.Run(tt.name, func(t *testing.T) {
-			versions, err := tt.graph.AvailableVersions(tt.engine, tt.currentVersion)
+			versions, err := tt.graph.AvailableVersions(tt.engine, tt.currentVersion, tt.constraint, time.Now())
 
 			switch tt.expectedErr {
 			case "":
@@ -178,6 +199,7 @@ func TestComputeTarget(t *testing.T) {
 		baseImage         string
 		image             string
 		version           string
+		constraint        string
 		channel           string
 		engine            string
 		currentVersion    *v1alpha1.SpiceDBVersion
@@ -592,6 +614,198 @@ func TestComputeTarget(t *testing.T) {
 			},
 			expectedState: State{ID: "v1.0.1"},
 		},
+		{
+			name: "constraint and no current version installs the newest satisfying version",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.33.0": {"v1.33.1", "v1.34.0"},
+					"v1.33.1": {"v1.34.0"},
+					"v1.34.0": {"v1.35.0"},
+				},
+				Nodes: []State{{ID: "v1.35.0"}, {ID: "v1.34.0"}, {ID: "v1.33.1"}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "~1.33",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:       "v1.33.1",
+				Channel:    "cockroachdb",
+				Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration},
+			},
+			expectedState: State{ID: "v1.33.1"},
+		},
+		{
+			name: "constraint updates towards the newest satisfying version",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.33.0": {"v1.33.1", "v1.34.0"},
+					"v1.33.1": {"v1.34.0"},
+					"v1.34.0": {"v1.35.0"},
+				},
+				Nodes: []State{{ID: "v1.35.0"}, {ID: "v1.34.0"}, {ID: "v1.33.1"}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        ">=1.30 <1.35",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			currentVersion:    &v1alpha1.SpiceDBVersion{Name: "v1.33.0", Channel: "cockroachdb"},
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:    "v1.34.0",
+				Channel: "cockroachdb",
+			},
+			expectedState: State{ID: "v1.34.0"},
+		},
+		{
+			name: "constraint satisfied by the current version stays put",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.33.0": {"v1.33.1", "v1.34.0"},
+					"v1.33.1": {"v1.34.0"},
+					"v1.34.0": {"v1.35.0"},
+				},
+				Nodes: []State{{ID: "v1.35.0"}, {ID: "v1.34.0"}, {ID: "v1.33.1"}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "~1.34",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			currentVersion:    &v1alpha1.SpiceDBVersion{Name: "v1.34.0", Channel: "cockroachdb"},
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:    "v1.34.0",
+				Channel: "cockroachdb",
+			},
+			expectedState: State{ID: "v1.34.0"},
+		},
+		{
+			name: "current above the constraint rolls back",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.30.0": {"v1.33.0"},
+				},
+				Rollbacks: RollbackSet{"v1.33.0": {{To: "v1.30.0"}}},
+				Nodes:     []State{{ID: "v1.33.0"}, {ID: "v1.30.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "~1.30",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			currentVersion:    &v1alpha1.SpiceDBVersion{Name: "v1.33.0", Channel: "cockroachdb"},
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:       "v1.30.0",
+				Channel:    "cockroachdb",
+				Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesRollback},
+			},
+			expectedState: State{ID: "v1.30.0"},
+		},
+		{
+			name: "current above the constraint without a rollback edge",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.30.0": {"v1.33.0"},
+				},
+				Nodes: []State{{ID: "v1.33.0"}, {ID: "v1.30.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "~1.30",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			currentVersion:    &v1alpha1.SpiceDBVersion{Name: "v1.33.0", Channel: "cockroachdb"},
+			expectedErr:       `v1.33.0 doesn't satisfy versionConstraint "~1.30": can't roll back from v1.33.0 to v1.30.0`,
+		},
+		{
+			name: "constraint skips deprecated versions",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges:    EdgeSet{"v1.33.0": {"v1.33.1"}},
+				Nodes:    []State{{ID: "v1.33.1", Deprecated: true}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "~1.33",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:       "v1.33.0",
+				Channel:    "cockroachdb",
+				Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration},
+			},
+			expectedState: State{ID: "v1.33.0"},
+		},
+		{
+			name: "constraint not satisfied by any version",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.33.0": {"v1.33.1", "v1.34.0"},
+					"v1.33.1": {"v1.34.0"},
+					"v1.34.0": {"v1.35.0"},
+				},
+				Nodes: []State{{ID: "v1.35.0"}, {ID: "v1.34.0"}, {ID: "v1.33.1"}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "~2.0",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			expectedErr:       "no version in channel \"cockroachdb\" satisfies \"~2.0\"",
+		},
+		{
+			name: "invalid constraint",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.33.0": {"v1.33.1", "v1.34.0"},
+					"v1.33.1": {"v1.34.0"},
+					"v1.34.0": {"v1.35.0"},
+				},
+				Nodes: []State{{ID: "v1.35.0"}, {ID: "v1.34.0"}, {ID: "v1.33.1"}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			constraint:        "not a constraint",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			expectedErr:       "invalid version constraint",
+		},
+		{
+			name: "constraint and version can't both be set",
+			graph: &UpdateGraph{Channels: []Channel{{
+				Name:     "cockroachdb",
+				Metadata: map[string]string{"datastore": "cockroachdb"},
+				Edges: EdgeSet{
+					"v1.33.0": {"v1.33.1", "v1.34.0"},
+					"v1.33.1": {"v1.34.0"},
+					"v1.34.0": {"v1.35.0"},
+				},
+				Nodes: []State{{ID: "v1.35.0"}, {ID: "v1.34.0"}, {ID: "v1.33.1"}, {ID: "v1.33.0"}},
+			}}},
+			engine:            "cockroachdb",
+			channel:           "cockroachdb",
+			version:           "v1.33.0",
+			constraint:        "~1.33",
+			baseImage:         "ghcr.io/authzed/spicedb",
+			expectedBaseImage: "ghcr.io/authzed/spicedb",
+			expectedErr:       "version and versionConstraint can't both be set",
+		},
 	}
 
 	for _, tt := range table {
@@ -600,10 +814,12 @@ func TestComputeTarget(t *testing.T) {
 				tt.baseImage,
 				tt.image,
 				tt.version,
+				tt.constraint,
 				tt.channel,
 				tt.engine,
 				tt.currentVersion,
 				tt.rolling,
+				time.Now(),
 			)
 
 			switch tt.expectedErr {
@@ -0,0 +1,84 @@
+package updates
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// lifecycleDateLayout is the format of DeprecatedSince and EndOfLife
+const lifecycleDateLayout = time.DateOnly
+
+// validateLifecycle checks that the lifecycle dates of a node can be parsed
+func (s State) validateLifecycle() error {
+	if _, err := parseLifecycleDate(s.DeprecatedSince); err != nil {
+		return fmt.Errorf("node %s has an invalid deprecatedSince: %w", s.ID, err)
+	}
+	if _, err := parseLifecycleDate(s.EndOfLife); err != nil {
+		return fmt.Errorf("node %s has an invalid endOfLife: %w", s.ID, err)
+	}
+	return nil
+}
+
+func parseLifecycleDate(date string) (time.Time, error) {
+	if len(date) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(lifecycleDateLayout, date)
+}
+
+// reached returns true if date is set and now is on or after it. Dates that
+// don't parse are never reached; they're rejected when the graph is loaded.
+func reached(date string, now time.Time) bool {
+	t, err := parseLifecycleDate(date)
+	if err != nil || t.IsZero() {
+		return false
+	}
+	return !now.Before(t)
+}
+
+// IsDeprecated returns true if the release is marked deprecated, or if its
+// deprecation date has passed.
+func (s State) IsDeprecated(now time.Time) bool {
+	return s.Deprecated || reached(s.DeprecatedSince, now) || s.IsEndOfLife(now)
+}
+
+// IsEndOfLife returns true if the release's end of life date has passed.
+func (s State) IsEndOfLife(now time.Time) bool {
+	return reached(s.EndOfLife, now)
+}
+
+// VersionState returns the node for a version in its channel. The second
+// return value is false if the channel or version isn't in the graph.
+func (g *UpdateGraph) VersionState(engine string, v v1alpha1.SpiceDBVersion) (State, bool) {
+	c, ok := g.channel(engine, v.Channel)
+	if !ok {
+		return State{}, false
+	}
+	for _, n := range c.Nodes {
+		if n.ID == v.Name {
+			return n, true
+		}
+	}
+	return State{}, false
+}
+
+// annotateLifecycle marks each version that is deprecated or past its end of
+// life.
+func (g *UpdateGraph) annotateLifecycle(engine string, versions []v1alpha1.SpiceDBVersion, now time.Time) {
+	for i := range versions {
+		state, ok := g.VersionState(engine, versions[i])
+		if !ok {
+			continue
+		}
+		switch {
+		case state.IsEndOfLife(now):
+			versions[i].Attributes = append(versions[i].Attributes, v1alpha1.SpiceDBVersionAttributesEndOfLife)
+			versions[i].Description += ", past end of life"
+		case state.IsDeprecated(now):
+			versions[i].Attributes = append(versions[i].Attributes, v1alpha1.SpiceDBVersionAttributesDeprecated)
+			versions[i].Description += ", deprecated"
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package updates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+func TestStateLifecycle(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		state          State
+		wantDeprecated bool
+		wantEndOfLife  bool
+	}{
+		{
+			name:  "supported",
+			state: State{ID: "v1"},
+		},
+		{
+			name:           "marked deprecated",
+			state:          State{ID: "v1", Deprecated: true},
+			wantDeprecated: true,
+		},
+		{
+			name:           "deprecated since a past date",
+			state:          State{ID: "v1", DeprecatedSince: "2024-05-01"},
+			wantDeprecated: true,
+		},
+		{
+			name:           "deprecated since today",
+			state:          State{ID: "v1", DeprecatedSince: "2024-06-01"},
+			wantDeprecated: true,
+		},
+		{
+			name:  "deprecated in the future",
+			state: State{ID: "v1", DeprecatedSince: "2024-07-01", EndOfLife: "2025-01-01"},
+		},
+		{
+			name:           "past end of life",
+			state:          State{ID: "v1", EndOfLife: "2024-01-01"},
+			wantDeprecated: true,
+			wantEndOfLife:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantDeprecated, tt.state.IsDeprecated(now))
+			require.Equal(t, tt.wantEndOfLife, tt.state.IsEndOfLife(now))
+		})
+	}
+}
+
+func TestNewMemorySourceInvalidLifecycle(t *testing.T) {
+	_, err := NewMemorySource([]State{{ID: "v1", EndOfLife: "01/02/2024"}}, EdgeSet{"v1": {}})
+	require.ErrorContains(t, err, "node v1 has an invalid endOfLife")
+
+	_, err = NewMemorySource([]State{{ID: "v1", DeprecatedSince: "2024-13-01"}}, EdgeSet{"v1": {}})
+	require.ErrorContains(t, err, "node v1 has an invalid deprecatedSince")
+}
+
+func TestAvailableVersionsLifecycle(t *testing.T) {
+	graph := &UpdateGraph{Channels: []Channel{{
+		Name:     "postgres",
+		Metadata: map[string]string{"datastore": "postgres"},
+		Edges: EdgeSet{
+			"v1.0.0": {"v1.0.1", "v1.1.0"},
+			"v1.0.1": {"v1.1.0"},
+			"v1.1.0": {},
+		},
+		Nodes: []State{
+			{ID: "v1.1.0", Tag: "v1.1.0", Migration: "b"},
+			{ID: "v1.0.1", Tag: "v1.0.1", Migration: "a", EndOfLife: "2000-01-01"},
+			{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a", Deprecated: true},
+		},
+	}}}
+	versions, err := graph.AvailableVersions("postgres", v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: "postgres"}, "", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []v1alpha1.SpiceDBVersion{
+		{
+			Name:        "v1.0.1",
+			Channel:     "postgres",
+			Attributes:  []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesNext, v1alpha1.SpiceDBVersionAttributesEndOfLife},
+			Description: "direct update with no migrations, past end of life",
+		},
+		{
+			Name:        "v1.1.0",
+			Channel:     "postgres",
+			Attributes:  []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesNext, v1alpha1.SpiceDBVersionAttributesMigration, v1alpha1.SpiceDBVersionAttributesLatest},
+			Description: "update will run a migration, head of channel",
+		},
+	}, versions)
+}
+
+func TestComputeTargetConstraintLifecycle(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	graph := func(nodes ...State) *UpdateGraph {
+		edges := EdgeSet{}
+		for i, n := range nodes {
+			if i > 0 {
+				edges[n.ID] = []string{nodes[0].ID}
+			}
+		}
+		return &UpdateGraph{Channels: []Channel{{
+			Name:     "stable",
+			Metadata: map[string]string{"datastore": "postgres"},
+			Nodes:    nodes,
+			Edges:    edges,
+		}}}
+	}
+
+	tests := []struct {
+		name     string
+		graph    *UpdateGraph
+		current  string
+		now      time.Time
+		expected string
+	}{
+		{
+			name:     "keeps a current version that became deprecated",
+			graph:    graph(State{ID: "v1.16.2", Tag: "v1.16.2", DeprecatedSince: "2020-01-01"}, State{ID: "v1.16.1", Tag: "v1.16.1"}),
+			current:  "v1.16.2",
+			now:      now,
+			expected: "v1.16.2",
+		},
+		{
+			name:     "keeps a current version past end of life",
+			graph:    graph(State{ID: "v1.16.2", Tag: "v1.16.2", EndOfLife: "2020-01-01"}, State{ID: "v1.16.1", Tag: "v1.16.1"}),
+			current:  "v1.16.2",
+			now:      now,
+			expected: "v1.16.2",
+		},
+		{
+			name:     "doesn't update to a deprecated version",
+			graph:    graph(State{ID: "v1.16.2", Tag: "v1.16.2", DeprecatedSince: "2020-01-01"}, State{ID: "v1.16.1", Tag: "v1.16.1"}),
+			current:  "v1.16.1",
+			now:      now,
+			expected: "v1.16.1",
+		},
+		{
+			name:     "updates to a version before its deprecation date",
+			graph:    graph(State{ID: "v1.16.2", Tag: "v1.16.2", DeprecatedSince: "2030-01-01"}, State{ID: "v1.16.1", Tag: "v1.16.1"}),
+			current:  "v1.16.1",
+			now:      now,
+			expected: "v1.16.2",
+		},
+		{
+			name:     "installs the newest version that isn't deprecated",
+			graph:    graph(State{ID: "v1.16.2", Tag: "v1.16.2", DeprecatedSince: "2020-01-01"}, State{ID: "v1.16.1", Tag: "v1.16.1"}),
+			now:      now,
+			expected: "v1.16.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var current *v1alpha1.SpiceDBVersion
+			if len(tt.current) > 0 {
+				current = &v1alpha1.SpiceDBVersion{Name: tt.current, Channel: "stable"}
+			}
+			_, target, state, err := tt.graph.ComputeTarget("image", "", "", "~1.16", "stable", "postgres", current, false, tt.now)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, target.Name)
+			require.Equal(t, tt.expected, state.ID)
+		})
+	}
+}
@@ -134,6 +134,9 @@ func NewMemorySource(nodes []State, edges EdgeSet) (Source, error) {
 		if _, ok := nodeSet[n.ID]; ok {
 			return nil, fmt.Errorf("more than one node with ID %s", n.ID)
 		}
+		if err := n.validateLifecycle(); err != nil {
+			return nil, err
+		}
 		nodeSet[n.ID] = i
 	}
 
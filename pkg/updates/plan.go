@@ -0,0 +1,160 @@
+package updates
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// PlanStrategy picks between update paths when there's more than one way to
+// get from one version to another.
+type PlanStrategy string
+
+const (
+	// PlanFewestMigrations prefers paths that run the fewest migrations, and
+	// then the fewest updates. This is the default.
+	PlanFewestMigrations PlanStrategy = "fewestMigrations"
+
+	// PlanFewestHops prefers paths with the fewest updates, and then the
+	// fewest migrations.
+	PlanFewestHops PlanStrategy = "fewestHops"
+)
+
+// requiresMigration returns true if updating from one state to another runs
+// a migration, either to a new migration or to a new phase of a migration.
+func requiresMigration(from, to State) bool {
+	return from.Migration != to.Migration || from.Phase != to.Phase
+}
+
+// planCost is the cost of a path through the update graph.
+type planCost struct {
+	migrations int
+	hops       int
+}
+
+func (c planCost) less(other planCost, strategy PlanStrategy) bool {
+	if strategy == PlanFewestHops {
+		if c.hops != other.hops {
+			return c.hops < other.hops
+		}
+		return c.migrations < other.migrations
+	}
+	if c.migrations != other.migrations {
+		return c.migrations < other.migrations
+	}
+	return c.hops < other.hops
+}
+
+func (m *MemorySource) Plan(from, to string, strategy PlanStrategy) ([]string, error) {
+	start, ok := m.Nodes[from]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the channel", from)
+	}
+	end, ok := m.Nodes[to]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the channel", to)
+	}
+	if start == end {
+		return nil, nil
+	}
+
+	// Dijkstra over node indexes; channels are small so a linear scan for
+	// the next node is fine. Ties go to newer nodes (lower indexes) so that
+	// plans are deterministic.
+	costs := make(map[int]planCost, len(m.OrderedNodes))
+	prev := make(map[int]int, len(m.OrderedNodes))
+	done := make(map[int]bool, len(m.OrderedNodes))
+	costs[start] = planCost{}
+	for {
+		current := -1
+		for i := range m.OrderedNodes {
+			c, reached := costs[i]
+			if !reached || done[i] {
+				continue
+			}
+			if current < 0 || c.less(costs[current], strategy) {
+				current = i
+			}
+		}
+		if current < 0 {
+			return nil, fmt.Errorf("there is no path from %s to %s", from, to)
+		}
+		if current == end {
+			break
+		}
+		done[current] = true
+
+		currentState := m.OrderedNodes[current]
+		for _, id := range m.Edges[currentState.ID] {
+			next, ok := m.Nodes[id]
+			if !ok || done[next] {
+				continue
+			}
+			cost := costs[current]
+			cost.hops++
+			if requiresMigration(currentState, m.OrderedNodes[next]) {
+				cost.migrations++
+			}
+			if existing, reached := costs[next]; !reached || cost.less(existing, strategy) ||
+				(!existing.less(cost, strategy) && current < prev[next]) {
+				costs[next] = cost
+				prev[next] = current
+			}
+		}
+	}
+
+	path := make([]string, 0, costs[end].hops)
+	for i := end; i != start; i = prev[i] {
+		path = append(path, m.OrderedNodes[i].ID)
+	}
+	slices.Reverse(path)
+	return path, nil
+}
+
+// PlannedPath returns the updates that remain to get from the version v to
+// the goal of the cluster: the explicit version, the newest version that
+// satisfies the constraint, or the head of the channel. Each step that runs a
+// migration is marked with the migration attribute, and each step that can't
+// dispatch to the version before it is marked incompatibleDispatch. If v is
+// already at the goal, or there's no path to it, no steps are returned.
+func (g *UpdateGraph) PlannedPath(engine string, v v1alpha1.SpiceDBVersion, version, constraint string, now time.Time) ([]v1alpha1.SpiceDBVersion, error) {
+	source, err := g.SourceForChannel(engine, v.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("no source found for channel %q, can't plan updates: %w", v.Channel, err)
+	}
+
+	head := source.LatestVersion("")
+	goal := version
+	if len(goal) == 0 && len(constraint) > 0 {
+		if goal, err = g.resolveConstraint(engine, v.Channel, constraint, v.Name, now); err != nil {
+			return nil, err
+		}
+	}
+	if len(goal) == 0 {
+		goal = head
+	}
+
+	path, err := source.Plan(v.Name, goal, g.PlanStrategy)
+	if err != nil || len(path) == 0 {
+		return nil, nil
+	}
+
+	steps := make([]v1alpha1.SpiceDBVersion, 0, len(path))
+	from := source.State(v.Name)
+	for _, id := range path {
+		step := v1alpha1.SpiceDBVersion{Name: id, Channel: v.Channel}
+		to := source.State(id)
+		if requiresMigration(from, to) {
+			step.Attributes = append(step.Attributes, v1alpha1.SpiceDBVersionAttributesMigration)
+		}
+		g.markIncompatibleDispatch(engine, from.ID, &step)
+		if id == head {
+			step.Attributes = append(step.Attributes, v1alpha1.SpiceDBVersionAttributesLatest)
+		}
+		steps = append(steps, step)
+		from = to
+	}
+	return steps, nil
+}
@@ -0,0 +1,205 @@
+package updates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// planGraph has two paths from v1.0.0 to the head: one with fewer hops that
+// runs two migrations, and one with more hops that only runs one.
+func planGraph() *UpdateGraph {
+	return &UpdateGraph{Channels: []Channel{{
+		Name:     "postgres",
+		Metadata: map[string]string{"datastore": "postgres"},
+		Edges: EdgeSet{
+			"v1.0.0": {"v1.1.0", "v1.2.0"},
+			"v1.1.0": {"v1.1.1"},
+			"v1.1.1": {"v1.3.0"},
+			"v1.2.0": {"v1.3.0"},
+			"v1.3.0": {},
+		},
+		Nodes: []State{
+			{ID: "v1.3.0", Migration: "c"},
+			{ID: "v1.2.0", Migration: "b"},
+			{ID: "v1.1.1", Migration: "a"},
+			{ID: "v1.1.0", Migration: "a"},
+			{ID: "v1.0.0", Migration: "a"},
+		},
+	}}}
+}
+
+func TestMemorySourcePlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		strategy PlanStrategy
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "defaults to fewest migrations",
+			from: "v1.0.0",
+			to:   "v1.3.0",
+			want: []string{"v1.1.0", "v1.1.1", "v1.3.0"},
+		},
+		{
+			name:     "fewest migrations",
+			from:     "v1.0.0",
+			to:       "v1.3.0",
+			strategy: PlanFewestMigrations,
+			want:     []string{"v1.1.0", "v1.1.1", "v1.3.0"},
+		},
+		{
+			name:     "fewest hops",
+			from:     "v1.0.0",
+			to:       "v1.3.0",
+			strategy: PlanFewestHops,
+			want:     []string{"v1.2.0", "v1.3.0"},
+		},
+		{
+			name: "to an intermediate version",
+			from: "v1.0.0",
+			to:   "v1.1.1",
+			want: []string{"v1.1.0", "v1.1.1"},
+		},
+		{
+			name: "already there",
+			from: "v1.3.0",
+			to:   "v1.3.0",
+		},
+		{
+			name:    "no path to older versions",
+			from:    "v1.3.0",
+			to:      "v1.0.0",
+			wantErr: "there is no path from v1.3.0 to v1.0.0",
+		},
+		{
+			name:    "unknown version",
+			from:    "v0.9.0",
+			to:      "v1.3.0",
+			wantErr: "v0.9.0 is not in the channel",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := planGraph().SourceForChannel("postgres", "postgres")
+			require.NoError(t, err)
+			path, err := source.Plan(tt.from, tt.to, tt.strategy)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, path)
+		})
+	}
+}
+
+func TestMemorySourcePlanPhases(t *testing.T) {
+	// a phase change is a migration even if the migration name is the same
+	graph := &UpdateGraph{Channels: []Channel{{
+		Name:     "postgres",
+		Metadata: map[string]string{"datastore": "postgres"},
+		Edges: EdgeSet{
+			"v1.0.0": {"v1.1.0", "v1.2.0"},
+			"v1.1.0": {"v1.2.0"},
+			"v1.2.0": {},
+		},
+		Nodes: []State{
+			{ID: "v1.2.0", Migration: "b"},
+			{ID: "v1.1.0", Migration: "a", Phase: "write-both"},
+			{ID: "v1.0.0", Migration: "a"},
+		},
+	}}}
+	source, err := graph.SourceForChannel("postgres", "postgres")
+	require.NoError(t, err)
+	path, err := source.Plan("v1.0.0", "v1.2.0", PlanFewestMigrations)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1.2.0"}, path)
+}
+
+func TestPlannedPath(t *testing.T) {
+	current := v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: "postgres"}
+	tests := []struct {
+		name       string
+		current    v1alpha1.SpiceDBVersion
+		version    string
+		constraint string
+		strategy   PlanStrategy
+		want       []v1alpha1.SpiceDBVersion
+	}{
+		{
+			name:    "to the head of the channel",
+			current: current,
+			want: []v1alpha1.SpiceDBVersion{
+				{Name: "v1.1.0", Channel: "postgres"},
+				{Name: "v1.1.1", Channel: "postgres"},
+				{Name: "v1.3.0", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration, v1alpha1.SpiceDBVersionAttributesLatest}},
+			},
+		},
+		{
+			name:     "fewest hops",
+			current:  current,
+			strategy: PlanFewestHops,
+			want: []v1alpha1.SpiceDBVersion{
+				{Name: "v1.2.0", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration}},
+				{Name: "v1.3.0", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration, v1alpha1.SpiceDBVersionAttributesLatest}},
+			},
+		},
+		{
+			name:    "to an explicit version",
+			current: current,
+			version: "v1.2.0",
+			want: []v1alpha1.SpiceDBVersion{
+				{Name: "v1.2.0", Channel: "postgres", Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration}},
+			},
+		},
+		{
+			name:       "to the newest version satisfying a constraint",
+			current:    current,
+			constraint: "~1.1",
+			want: []v1alpha1.SpiceDBVersion{
+				{Name: "v1.1.0", Channel: "postgres"},
+				{Name: "v1.1.1", Channel: "postgres"},
+			},
+		},
+		{
+			name:    "at the head",
+			current: v1alpha1.SpiceDBVersion{Name: "v1.3.0", Channel: "postgres"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := planGraph()
+			graph.PlanStrategy = tt.strategy
+			path, err := graph.PlannedPath("postgres", tt.current, tt.version, tt.constraint, time.Now())
+			require.NoError(t, err)
+			require.Equal(t, tt.want, path)
+		})
+	}
+}
+
+func TestComputeTargetFollowsPlan(t *testing.T) {
+	current := &v1alpha1.SpiceDBVersion{Name: "v1.0.0", Channel: "postgres"}
+
+	graph := planGraph()
+	_, target, state, err := graph.ComputeTarget("image", "", "", "", "postgres", "postgres", current, false, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, &v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres"}, target)
+	require.Equal(t, "v1.1.0", state.ID)
+
+	graph.PlanStrategy = PlanFewestHops
+	_, target, state, err = graph.ComputeTarget("image", "", "", "", "postgres", "postgres", current, false, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, &v1alpha1.SpiceDBVersion{
+		Name:       "v1.2.0",
+		Channel:    "postgres",
+		Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesMigration},
+	}, target)
+	require.Equal(t, "v1.2.0", state.ID)
+}
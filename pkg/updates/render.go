@@ -0,0 +1,228 @@
+package updates
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GraphFormat is an output format for rendering a channel.
+type GraphFormat string
+
+const (
+	GraphFormatDOT     GraphFormat = "dot"
+	GraphFormatMermaid GraphFormat = "mermaid"
+)
+
+// RenderOptions controls how a channel is rendered.
+type RenderOptions struct {
+	Format GraphFormat
+
+	// Highlight holds edges to call out, usually the edges of the matching
+	// channel in the Difference between the proposed and validated graphs.
+	Highlight EdgeSet
+
+	// Now decides which nodes are shown as deprecated or past end of life.
+	Now time.Time
+}
+
+// renderEdge is an edge or rollback between two nodes of a channel.
+type renderEdge struct {
+	from, to    int
+	migration   string
+	rollback    bool
+	highlighted bool
+}
+
+// AddedEdges returns the edges in the channel that aren't in the validated
+// graph.
+func (g *UpdateGraph) AddedEdges(engine, channel string, validated *UpdateGraph) EdgeSet {
+	c, ok := g.Difference(validated).channel(engine, channel)
+	if !ok {
+		return nil
+	}
+	return c.Edges
+}
+
+// RenderChannel renders the channel for the engine with the given name.
+func (g *UpdateGraph) RenderChannel(w io.Writer, engine, name string, opts RenderOptions) error {
+	c, ok := g.channel(engine, name)
+	if !ok {
+		return fmt.Errorf("no channel for %q found with name %q", engine, name)
+	}
+	return c.Render(w, opts)
+}
+
+// Render writes the channel as a DOT or Mermaid graph. Edges that run a
+// migration are drawn bold and labeled with the migration (and phase) they
+// run, rollbacks are dashed, and deprecated nodes are greyed out.
+func (c Channel) Render(w io.Writer, opts RenderOptions) error {
+	edges, err := c.renderEdges(opts.Highlight)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	switch opts.Format {
+	case GraphFormatDOT:
+		c.renderDOT(&sb, edges, opts.Now)
+	case GraphFormatMermaid:
+		c.renderMermaid(&sb, edges, opts.Now)
+	default:
+		return fmt.Errorf("unknown graph format %q", opts.Format)
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// renderEdges lists the edges and rollbacks of the channel in node order,
+// so that the output is stable between runs.
+func (c Channel) renderEdges(highlight EdgeSet) ([]renderEdge, error) {
+	index := make(map[string]int, len(c.Nodes))
+	for i, n := range c.Nodes {
+		index[n.ID] = i
+	}
+	lookup := func(from, to string) (int, int, error) {
+		f, ok := index[from]
+		if !ok {
+			return 0, 0, fmt.Errorf("channel %s has an edge from unknown node %s", c.Name, from)
+		}
+		t, ok := index[to]
+		if !ok {
+			return 0, 0, fmt.Errorf("channel %s has an edge to unknown node %s", c.Name, to)
+		}
+		return f, t, nil
+	}
+
+	var edges []renderEdge
+	for _, n := range c.Nodes {
+		var forward []renderEdge
+		for _, to := range c.Edges[n.ID] {
+			f, t, err := lookup(n.ID, to)
+			if err != nil {
+				return nil, err
+			}
+			e := renderEdge{from: f, to: t, highlighted: slices.Contains(highlight[n.ID], to)}
+			if requiresMigration(c.Nodes[f], c.Nodes[t]) {
+				e.migration = migrationLabel(c.Nodes[t])
+			}
+			forward = append(forward, e)
+		}
+		for _, r := range c.Rollbacks[n.ID] {
+			f, t, err := lookup(n.ID, r.To)
+			if err != nil {
+				return nil, err
+			}
+			forward = append(forward, renderEdge{from: f, to: t, rollback: true})
+		}
+		slices.SortStableFunc(forward, func(a, b renderEdge) int {
+			return a.to - b.to
+		})
+		edges = append(edges, forward...)
+	}
+	return edges, nil
+}
+
+// migrationLabel names the migration that runs when updating to s.
+func migrationLabel(s State) string {
+	if s.Phase == "" {
+		return s.Migration
+	}
+	return s.Migration + " (" + s.Phase + ")"
+}
+
+// nodeLines are the lines of a node's label.
+func nodeLines(s State, now time.Time) []string {
+	lines := []string{s.ID}
+	if s.Migration != "" {
+		lines = append(lines, "migration: "+s.Migration)
+	}
+	if s.Phase != "" {
+		lines = append(lines, "phase: "+s.Phase)
+	}
+	switch {
+	case s.IsEndOfLife(now):
+		lines = append(lines, "end of life")
+	case s.IsDeprecated(now):
+		lines = append(lines, "deprecated")
+	}
+	return lines
+}
+
+func (c Channel) graphName() string {
+	return c.Metadata[DatastoreMetadataKey] + "/" + c.Name
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func (c Channel) renderDOT(sb *strings.Builder, edges []renderEdge, now time.Time) {
+	fmt.Fprintf(sb, "digraph %s {\n", dotQuote(c.graphName()))
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	for _, n := range c.Nodes {
+		attrs := []string{"label=" + dotQuote(strings.Join(nodeLines(n, now), `\n`))}
+		if n.IsDeprecated(now) {
+			attrs = append(attrs, `style="dashed,filled"`, `fillcolor="#eeeeee"`, `fontcolor="#777777"`)
+		}
+		fmt.Fprintf(sb, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range edges {
+		var attrs []string
+		switch {
+		case e.rollback:
+			attrs = append(attrs, "style=dashed", `label="rollback"`)
+		case e.migration != "":
+			attrs = append(attrs, "style=bold", "label="+dotQuote(e.migration))
+		}
+		if e.highlighted {
+			attrs = append(attrs, "color=forestgreen", "penwidth=2")
+		}
+		fmt.Fprintf(sb, "  %s -> %s", dotQuote(c.Nodes[e.from].ID), dotQuote(c.Nodes[e.to].ID))
+		if len(attrs) > 0 {
+			fmt.Fprintf(sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+}
+
+func (c Channel) renderMermaid(sb *strings.Builder, edges []renderEdge, now time.Time) {
+	fmt.Fprintf(sb, "---\ntitle: %s\n---\n", c.graphName())
+	sb.WriteString("flowchart LR\n")
+
+	var deprecated []string
+	for i, n := range c.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		fmt.Fprintf(sb, "  %s[\"%s\"]\n", id, strings.Join(nodeLines(n, now), "<br/>"))
+		if n.IsDeprecated(now) {
+			deprecated = append(deprecated, id)
+		}
+	}
+
+	var highlighted []string
+	for i, e := range edges {
+		arrow := "-->"
+		switch {
+		case e.rollback:
+			arrow = "-. rollback .->"
+		case e.migration != "":
+			arrow = "==>|\"" + e.migration + "\"|"
+		}
+		fmt.Fprintf(sb, "  n%d %s n%d\n", e.from, arrow, e.to)
+		if e.highlighted {
+			highlighted = append(highlighted, fmt.Sprint(i))
+		}
+	}
+
+	if len(deprecated) > 0 {
+		sb.WriteString("  classDef deprecated stroke-dasharray: 5 5,fill:#eeeeee,color:#777777\n")
+		fmt.Fprintf(sb, "  class %s deprecated\n", strings.Join(deprecated, ","))
+	}
+	if len(highlighted) > 0 {
+		fmt.Fprintf(sb, "  linkStyle %s stroke:forestgreen,stroke-width:3px\n", strings.Join(highlighted, ","))
+	}
+}
@@ -0,0 +1,129 @@
+package updates
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func renderChannel() Channel {
+	return Channel{
+		Name:     "stable",
+		Metadata: map[string]string{"datastore": "postgres"},
+		Edges: EdgeSet{
+			"v1.0.0": {"v1.1.0", "v1.0.1"},
+			"v1.0.1": {"v1.1.0"},
+		},
+		Rollbacks: RollbackSet{"v1.1.0": {{To: "v1.0.1"}}},
+		Nodes: []State{
+			{ID: "v1.1.0", Tag: "v1.1.0", Migration: "add-index", Phase: "write-both"},
+			{ID: "v1.0.1", Tag: "v1.0.1", Migration: "init"},
+			{ID: "v1.0.0", Tag: "v1.0.0", Migration: "init", Deprecated: true},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    GraphFormat
+		highlight EdgeSet
+		expected  string
+	}{
+		{
+			name:   "dot",
+			format: GraphFormatDOT,
+			expected: `digraph "postgres/stable" {
+  rankdir=LR;
+  node [shape=box];
+  "v1.1.0" [label="v1.1.0\nmigration: add-index\nphase: write-both"];
+  "v1.0.1" [label="v1.0.1\nmigration: init"];
+  "v1.0.0" [label="v1.0.0\nmigration: init\ndeprecated", style="dashed,filled", fillcolor="#eeeeee", fontcolor="#777777"];
+  "v1.1.0" -> "v1.0.1" [style=dashed, label="rollback"];
+  "v1.0.1" -> "v1.1.0" [style=bold, label="add-index (write-both)"];
+  "v1.0.0" -> "v1.1.0" [style=bold, label="add-index (write-both)"];
+  "v1.0.0" -> "v1.0.1";
+}
+`,
+		},
+		{
+			name:      "dot with highlighted edges",
+			format:    GraphFormatDOT,
+			highlight: EdgeSet{"v1.0.0": {"v1.0.1"}},
+			expected: `digraph "postgres/stable" {
+  rankdir=LR;
+  node [shape=box];
+  "v1.1.0" [label="v1.1.0\nmigration: add-index\nphase: write-both"];
+  "v1.0.1" [label="v1.0.1\nmigration: init"];
+  "v1.0.0" [label="v1.0.0\nmigration: init\ndeprecated", style="dashed,filled", fillcolor="#eeeeee", fontcolor="#777777"];
+  "v1.1.0" -> "v1.0.1" [style=dashed, label="rollback"];
+  "v1.0.1" -> "v1.1.0" [style=bold, label="add-index (write-both)"];
+  "v1.0.0" -> "v1.1.0" [style=bold, label="add-index (write-both)"];
+  "v1.0.0" -> "v1.0.1" [color=forestgreen, penwidth=2];
+}
+`,
+		},
+		{
+			name:      "mermaid",
+			format:    GraphFormatMermaid,
+			highlight: EdgeSet{"v1.0.1": {"v1.1.0"}},
+			expected: `---
+title: postgres/stable
+---
+flowchart LR
+  n0["v1.1.0<br/>migration: add-index<br/>phase: write-both"]
+  n1["v1.0.1<br/>migration: init"]
+  n2["v1.0.0<br/>migration: init<br/>deprecated"]
+  n0 -. rollback .-> n1
+  n1 ==>|"add-index (write-both)"| n0
+  n2 ==>|"add-index (write-both)"| n0
+  n2 --> n1
+  classDef deprecated stroke-dasharray: 5 5,fill:#eeeeee,color:#777777
+  class n2 deprecated
+  linkStyle 1 stroke:forestgreen,stroke-width:3px
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, renderChannel().Render(&buf, RenderOptions{
+				Format:    tt.format,
+				Highlight: tt.highlight,
+				Now:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}))
+			require.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	require.EqualError(t, renderChannel().Render(&buf, RenderOptions{Format: "svg"}), `unknown graph format "svg"`)
+
+	c := renderChannel()
+	c.Edges["v1.1.0"] = []string{"v2.0.0"}
+	require.EqualError(t, c.Render(&buf, RenderOptions{Format: GraphFormatDOT}), "channel stable has an edge to unknown node v2.0.0")
+}
+
+func TestAddedEdges(t *testing.T) {
+	proposed := &UpdateGraph{Channels: []Channel{renderChannel()}}
+	validated := &UpdateGraph{Channels: []Channel{renderChannel()}}
+	validated.Channels[0].Edges = EdgeSet{"v1.0.0": {"v1.0.1"}}
+
+	added := proposed.AddedEdges("postgres", "stable", validated)
+	require.ElementsMatch(t, []string{"v1.1.0"}, added["v1.0.0"])
+	require.ElementsMatch(t, []string{"v1.1.0"}, added["v1.0.1"])
+
+	require.Empty(t, proposed.AddedEdges("postgres", "stable", proposed))
+}
+
+func TestRenderChannel(t *testing.T) {
+	g := &UpdateGraph{Channels: []Channel{renderChannel()}}
+	var buf bytes.Buffer
+	require.NoError(t, g.RenderChannel(&buf, "postgres", "stable", RenderOptions{Format: GraphFormatMermaid}))
+	require.Contains(t, buf.String(), "title: postgres/stable")
+	require.EqualError(t, g.RenderChannel(&buf, "mysql", "stable", RenderOptions{Format: GraphFormatMermaid}), `no channel for "mysql" found with name "stable"`)
+}
@@ -0,0 +1,83 @@
+package updates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+// Rollback is an edge from a newer version back to an older one.
+type Rollback struct {
+	// To is the id of the older node.
+	To string `json:"to"`
+}
+
+// RollbackSet maps a node id to the older node ids that it can roll back to
+type RollbackSet map[string][]Rollback
+
+// channel returns the channel with the given name for the engine
+func (g *UpdateGraph) channel(engine, name string) (Channel, bool) {
+	for _, c := range g.Channels {
+		if strings.EqualFold(c.Name, name) && strings.EqualFold(c.Metadata[DatastoreMetadataKey], engine) {
+			return c, true
+		}
+	}
+	return Channel{}, false
+}
+
+// rollback returns the rollback edge from one node to another, if the
+// channel has one.
+func (c Channel) rollback(from, to string) (Rollback, bool) {
+	for _, r := range c.Rollbacks[from] {
+		if r.To == to {
+			return r, true
+		}
+	}
+	return Rollback{}, false
+}
+
+// computeRollback checks that a cluster at `from` may be rolled back to
+// `to`, and returns the state to roll back to. SpiceDB has no
+// down-migrations, so only rollbacks between versions with the same
+// migration and phase are allowed.
+func (g *UpdateGraph) computeRollback(engine, channel string, source Source, from, to string) (State, []v1alpha1.SpiceDBVersionAttributes, error) {
+	c, ok := g.channel(engine, channel)
+	if !ok {
+		return State{}, nil, fmt.Errorf("no channel for %q found with name %q", engine, channel)
+	}
+	if _, ok := c.rollback(from, to); !ok {
+		return State{}, nil, fmt.Errorf("can't roll back from %s to %s: channel %q has no rollback edge between them", from, to, channel)
+	}
+
+	fromState, toState := source.State(from), source.State(to)
+	if len(toState.ID) == 0 {
+		return State{}, nil, fmt.Errorf("rollback from %s to %s references a version that isn't in channel %q", from, to, channel)
+	}
+	if requiresMigration(fromState, toState) {
+		return State{}, nil, fmt.Errorf("can't roll back from %s to %s: rolling back would have to reverse a migration", from, to)
+	}
+	return toState, []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesRollback}, nil
+}
+
+// availableRollbacks lists the versions that v can roll back to.
+func (g *UpdateGraph) availableRollbacks(engine string, source Source, v v1alpha1.SpiceDBVersion) []v1alpha1.SpiceDBVersion {
+	c, ok := g.channel(engine, v.Channel)
+	if !ok {
+		return nil
+	}
+	rollbacks := make([]v1alpha1.SpiceDBVersion, 0)
+	for _, r := range c.Rollbacks[v.Name] {
+		_, attributes, err := g.computeRollback(engine, v.Channel, source, v.Name, r.To)
+		if err != nil {
+			continue
+		}
+		rollbacks = append(rollbacks, v1alpha1.SpiceDBVersion{
+			Name:        r.To,
+			Channel:     v.Channel,
+			Attributes:  attributes,
+			Description: "rollback with no migrations",
+		})
+	}
+	return rollbacks
+}
@@ -0,0 +1,131 @@
+package updates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb-operator/pkg/apis/authzed/v1alpha1"
+)
+
+func rollbackGraph(rollbacks RollbackSet) *UpdateGraph {
+	return &UpdateGraph{Channels: []Channel{{
+		Name:     "postgres",
+		Metadata: map[string]string{"datastore": "postgres"},
+		Edges: EdgeSet{
+			"v1.0.0": {"v1.0.1"},
+			"v1.0.1": {"v1.1.0"},
+			"v1.1.0": {},
+		},
+		Nodes: []State{
+			{ID: "v1.1.0", Tag: "v1.1.0", Migration: "b"},
+			{ID: "v1.0.1", Tag: "v1.0.1", Migration: "a"},
+			{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+		},
+		Rollbacks: rollbacks,
+	}}}
+}
+
+func TestComputeTargetRollback(t *testing.T) {
+	tests := []struct {
+		name           string
+		rollbacks      RollbackSet
+		current        string
+		version        string
+		expectedTarget *v1alpha1.SpiceDBVersion
+		expectedState  State
+		expectedErr    string
+	}{
+		{
+			name:      "rollback without a migration",
+			rollbacks: RollbackSet{"v1.0.1": {{To: "v1.0.0"}}},
+			current:   "v1.0.1",
+			version:   "v1.0.0",
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:       "v1.0.0",
+				Channel:    "postgres",
+				Attributes: []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesRollback},
+			},
+			expectedState: State{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+		},
+		{
+			name:        "rollback across a migration",
+			rollbacks:   RollbackSet{"v1.1.0": {{To: "v1.0.1"}}},
+			current:     "v1.1.0",
+			version:     "v1.0.1",
+			expectedErr: "can't roll back from v1.1.0 to v1.0.1: rolling back would have to reverse a migration",
+		},
+		{
+			name:        "no rollback edge",
+			rollbacks:   RollbackSet{"v1.1.0": {{To: "v1.0.1"}}},
+			current:     "v1.1.0",
+			version:     "v1.0.0",
+			expectedErr: "can't roll back from v1.1.0 to v1.0.0: channel \"postgres\" has no rollback edge between them",
+		},
+		{
+			name:      "forward updates ignore rollbacks",
+			rollbacks: RollbackSet{"v1.0.1": {{To: "v1.0.0"}}},
+			current:   "v1.0.0",
+			version:   "v1.0.1",
+			expectedTarget: &v1alpha1.SpiceDBVersion{
+				Name:    "v1.0.1",
+				Channel: "postgres",
+			},
+			expectedState: State{ID: "v1.0.1", Tag: "v1.0.1", Migration: "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := &v1alpha1.SpiceDBVersion{Name: tt.current, Channel: "postgres"}
+			_, target, state, err := rollbackGraph(tt.rollbacks).ComputeTarget("image", "", tt.version, "", "postgres", "postgres", current, false, time.Now())
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.Nil(t, target)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTarget, target)
+			require.Equal(t, tt.expectedState, state)
+		})
+	}
+}
+
+func TestAvailableVersionsRollbacks(t *testing.T) {
+	graph := rollbackGraph(RollbackSet{
+		"v1.1.0": {{To: "v1.0.1"}},
+		"v1.0.1": {{To: "v1.0.0"}},
+	})
+	versions, err := graph.AvailableVersions("postgres", v1alpha1.SpiceDBVersion{Name: "v1.1.0", Channel: "postgres"}, "", time.Now())
+	require.NoError(t, err)
+	require.Empty(t, versions)
+
+	versions, err = graph.AvailableVersions("postgres", v1alpha1.SpiceDBVersion{Name: "v1.0.1", Channel: "postgres"}, "", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []v1alpha1.SpiceDBVersion{
+		{
+			Name:        "v1.1.0",
+			Channel:     "postgres",
+			Attributes:  []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesNext, v1alpha1.SpiceDBVersionAttributesMigration, v1alpha1.SpiceDBVersionAttributesLatest},
+			Description: "update will run a migration, head of channel",
+		},
+		{
+			Name:        "v1.0.0",
+			Channel:     "postgres",
+			Attributes:  []v1alpha1.SpiceDBVersionAttributes{v1alpha1.SpiceDBVersionAttributesRollback},
+			Description: "rollback with no migrations",
+		},
+	}, versions)
+}
+
+func TestChannelRollbacksRemoveNodes(t *testing.T) {
+	c := rollbackGraph(RollbackSet{
+		"v1.1.0": {{To: "v1.0.1"}, {To: "v1.0.0"}},
+		"v1.0.1": {{To: "v1.0.0"}},
+	}).Channels[0].Clone()
+	c = c.RemoveNodes([]State{{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"}})
+	require.Equal(t, RollbackSet{
+		"v1.1.0": {{To: "v1.0.1"}},
+		"v1.0.1": {},
+	}, c.Rollbacks)
+}
@@ -27,4 +27,9 @@ type Source interface {
 	// Subgraph returns a new Source that is a subgraph of the current source,
 	// but where `head` is set to the provided node.
 	Subgraph(head string) (Source, error)
+
+	// Plan returns the versions to update through, in order, to get from
+	// `from` to `to`. The path doesn't include `from` and ends with `to`.
+	// When there are several paths, the strategy decides which is used.
+	Plan(from, to string, strategy PlanStrategy) ([]string, error)
 }
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/authzed/spicedb-operator/pkg/config"
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+// ReleasesFile is the declarative input that the update graph is compiled
+// from.
+type ReleasesFile struct {
+	ImageName string              `json:"imageName"`
+	Channels  []ChannelDefinition `json:"channels"`
+}
+
+// ChannelDefinition lists the releases in one channel for a datastore.
+type ChannelDefinition struct {
+	Name      string `json:"name"`
+	Datastore string `json:"datastore"`
+	Default   bool   `json:"default,omitempty"`
+
+	// Releases are ordered from newest to oldest.
+	Releases []Release `json:"releases"`
+
+	// Rollbacks and IncompatibleDispatch are copied into the channel as-is.
+	Rollbacks            updates.RollbackSet `json:"rollbacks,omitempty"`
+	IncompatibleDispatch updates.EdgeSet     `json:"incompatibleDispatch,omitempty"`
+}
+
+// Release is a node in the channel plus the range of releases it can
+// update to.
+type Release struct {
+	updates.State
+
+	// UpdatesTo is a semver range (without the "v" prefix) of the newer
+	// releases that this release has an edge to.
+	UpdatesTo string `json:"updatesTo,omitempty"`
+}
+
+// Compile checks the releases file for consistency and turns it into an
+// operator config with an update graph.
+func (f ReleasesFile) Compile() (config.OperatorConfig, error) {
+	opconfig := config.OperatorConfig{ImageName: f.ImageName}
+	if len(f.ImageName) == 0 {
+		return opconfig, errors.New("imageName is required")
+	}
+
+	var errs []error
+	defaults := make(map[string]string)
+	for i, def := range f.Channels {
+		if slices.ContainsFunc(f.Channels[:i], func(other ChannelDefinition) bool {
+			return other.Name == def.Name && other.Datastore == def.Datastore
+		}) {
+			errs = append(errs, fmt.Errorf("channel %s/%s is defined more than once", def.Datastore, def.Name))
+			continue
+		}
+		if def.Default {
+			if other, ok := defaults[def.Datastore]; ok {
+				errs = append(errs, fmt.Errorf("channels %s and %s are both the default for %s", other, def.Name, def.Datastore))
+			}
+			defaults[def.Datastore] = def.Name
+		}
+
+		channel, err := def.compile()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("channel %s/%s: %w", def.Datastore, def.Name, err))
+			continue
+		}
+		opconfig.Channels = append(opconfig.Channels, channel)
+	}
+	return opconfig, errors.Join(errs...)
+}
+
+// compile turns the definition into a channel. Every release other than
+// the newest must have an edge to a newer release, which guarantees that
+// the newest release can be reached from all of them.
+func (def ChannelDefinition) compile() (updates.Channel, error) {
+	if len(def.Name) == 0 || len(def.Datastore) == 0 {
+		return updates.Channel{}, errors.New("name and datastore are required")
+	}
+	if len(def.Releases) == 0 {
+		return updates.Channel{}, errors.New("no releases")
+	}
+
+	nodes := make([]updates.State, 0, len(def.Releases))
+	versions := make([]semver.Version, 0, len(def.Releases))
+	for i, r := range def.Releases {
+		v, err := semver.Parse(strings.TrimPrefix(r.ID, "v"))
+		if err != nil {
+			return updates.Channel{}, fmt.Errorf("release %q is not a semantic version: %w", r.ID, err)
+		}
+		if i > 0 && !v.LT(versions[i-1]) {
+			return updates.Channel{}, fmt.Errorf("release %s is listed after %s, releases must be ordered newest first", r.ID, def.Releases[i-1].ID)
+		}
+		if len(r.Tag) == 0 {
+			r.Tag = r.ID
+		}
+		nodes = append(nodes, r.State)
+		versions = append(versions, v)
+	}
+	if nodes[0].Deprecated {
+		return updates.Channel{}, fmt.Errorf("the newest release %s is deprecated", nodes[0].ID)
+	}
+
+	patterns := make(map[string]string, len(def.Releases))
+	for i, r := range def.Releases {
+		if i == 0 {
+			if len(r.UpdatesTo) > 0 {
+				return updates.Channel{}, fmt.Errorf("the newest release %s can't have updatesTo", r.ID)
+			}
+			continue
+		}
+		if len(r.UpdatesTo) == 0 {
+			return updates.Channel{}, fmt.Errorf("release %s has no updatesTo", r.ID)
+		}
+		if _, err := semver.ParseRange(r.UpdatesTo); err != nil {
+			return updates.Channel{}, fmt.Errorf("release %s has an invalid updatesTo: %w", r.ID, err)
+		}
+		patterns[r.ID] = r.UpdatesTo
+	}
+
+	edges := edgesFromPatterns(patterns, nodes)
+	for i, r := range def.Releases[1:] {
+		if len(edges[r.ID]) == 0 {
+			return updates.Channel{}, fmt.Errorf("release %s updatesTo %q doesn't include any release", r.ID, r.UpdatesTo)
+		}
+		for _, to := range edges[r.ID] {
+			if !semver.MustParse(strings.TrimPrefix(to, "v")).GT(versions[i+1]) {
+				return updates.Channel{}, fmt.Errorf("release %s updatesTo %q includes %s, which isn't newer", r.ID, r.UpdatesTo, to)
+			}
+		}
+	}
+
+	for _, set := range []updates.EdgeSet{def.IncompatibleDispatch, rollbackEdges(def.Rollbacks)} {
+		for from, to := range set {
+			for _, id := range append([]string{from}, to...) {
+				if !slices.ContainsFunc(nodes, func(s updates.State) bool { return s.ID == id }) {
+					return updates.Channel{}, fmt.Errorf("%s is referenced by a rollback or dispatch incompatibility but isn't a release", id)
+				}
+			}
+		}
+	}
+
+	// there are no down-migrations, so rollbacks can't cross a migration
+	for from, to := range def.Rollbacks {
+		fromState := nodes[slices.IndexFunc(nodes, func(s updates.State) bool { return s.ID == from })]
+		for _, r := range to {
+			toState := nodes[slices.IndexFunc(nodes, func(s updates.State) bool { return s.ID == r.To })]
+			if fromState.Migration != toState.Migration || fromState.Phase != toState.Phase {
+				return updates.Channel{}, fmt.Errorf("rollback from %s to %s crosses a migration", from, r.To)
+			}
+		}
+	}
+
+	// the memory source checks the rest (lifecycle dates, etc)
+	if _, err := updates.NewMemorySource(nodes, edges); err != nil {
+		return updates.Channel{}, err
+	}
+
+	metadata := map[string]string{updates.DatastoreMetadataKey: def.Datastore}
+	if def.Default {
+		metadata["default"] = "true"
+	}
+	return updates.Channel{
+		Name:                 def.Name,
+		Metadata:             metadata,
+		Nodes:                nodes,
+		Edges:                edges,
+		Rollbacks:            def.Rollbacks,
+		IncompatibleDispatch: def.IncompatibleDispatch,
+	}, nil
+}
+
+func rollbackEdges(rollbacks updates.RollbackSet) updates.EdgeSet {
+	edges := make(updates.EdgeSet, len(rollbacks))
+	for from, to := range rollbacks {
+		for _, r := range to {
+			edges[from] = append(edges[from], r.To)
+		}
+	}
+	return edges
+}
+
+func edgesFromPatterns(patterns map[string]string, releases []updates.State) map[string][]string {
+	edges := make(map[string][]string)
+	for from, to := range patterns {
+		toRange := semver.MustParseRange(to)
+		for _, release := range releases {
+			if release.Deprecated {
+				continue
+			}
+			if !toRange(semver.MustParse(strings.TrimPrefix(release.ID, "v"))) {
+				continue
+			}
+			edges[from] = append(edges[from], release.ID)
+		}
+		sort.Slice(edges[from], func(i, j int) bool {
+			v1 := semver.MustParse(strings.TrimPrefix(edges[from][i], "v"))
+			v2 := semver.MustParse(strings.TrimPrefix(edges[from][j], "v"))
+			return v1.LT(v2)
+		})
+	}
+	return edges
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+
+	"github.com/authzed/spicedb-operator/pkg/updates"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name        string
+		releases    string
+		expected    []updates.Channel
+		expectedErr string
+	}{
+		{
+			name: "compiles edges oldest first and skips deprecated releases",
+			releases: `
+imageName: spicedb
+channels:
+  - name: stable
+    datastore: postgres
+    default: true
+    releases:
+      - {id: v1.2.0, migration: b}
+      - {id: v1.1.1, migration: a, deprecated: true, updatesTo: ">=1.2.0"}
+      - {id: v1.1.0, migration: a, updatesTo: ">=1.1.1"}
+      - {id: v1.1.0-phase1, tag: v1.1.0, migration: a, phase: one, updatesTo: "1.1.0"}
+      - {id: v1.0.0, migration: a, updatesTo: ">=1.1.0-phase1"}
+    rollbacks:
+      v1.1.1: [{to: v1.1.0}]
+`,
+			expected: []updates.Channel{{
+				Name:     "stable",
+				Metadata: map[string]string{"datastore": "postgres", "default": "true"},
+				Nodes: []updates.State{
+					{ID: "v1.2.0", Tag: "v1.2.0", Migration: "b"},
+					{ID: "v1.1.1", Tag: "v1.1.1", Migration: "a", Deprecated: true},
+					{ID: "v1.1.0", Tag: "v1.1.0", Migration: "a"},
+					{ID: "v1.1.0-phase1", Tag: "v1.1.0", Migration: "a", Phase: "one"},
+					{ID: "v1.0.0", Tag: "v1.0.0", Migration: "a"},
+				},
+				Edges: updates.EdgeSet{
+					"v1.1.1":        {"v1.2.0"},
+					"v1.1.0":        {"v1.2.0"},
+					"v1.1.0-phase1": {"v1.1.0"},
+					"v1.0.0":        {"v1.1.0-phase1", "v1.1.0", "v1.2.0"},
+				},
+				Rollbacks: updates.RollbackSet{"v1.1.1": {{To: "v1.1.0"}}},
+			}},
+		},
+		{
+			name: "releases out of order",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, releases: [{id: v1.0.0}, {id: v1.1.0, updatesTo: ">1.0.0"}]}
+`,
+			expectedErr: "channel memory/stable: release v1.1.0 is listed after v1.0.0, releases must be ordered newest first",
+		},
+		{
+			name: "updatesTo only includes deprecated releases",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, releases: [{id: v1.2.0}, {id: v1.1.0, deprecated: true, updatesTo: ">1.1.0"}, {id: v1.0.0, updatesTo: "1.1.0"}]}
+`,
+			expectedErr: `channel memory/stable: release v1.0.0 updatesTo "1.1.0" doesn't include any release`,
+		},
+		{
+			name: "missing updatesTo",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, releases: [{id: v1.1.0}, {id: v1.0.0}]}
+`,
+			expectedErr: "channel memory/stable: release v1.0.0 has no updatesTo",
+		},
+		{
+			name: "edge to an older release",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, releases: [{id: v1.2.0}, {id: v1.1.0, updatesTo: ">=1.0.0"}, {id: v1.0.0, updatesTo: ">1.0.0"}]}
+`,
+			expectedErr: `channel memory/stable: release v1.1.0 updatesTo ">=1.0.0" includes v1.0.0, which isn't newer`,
+		},
+		{
+			name: "deprecated head",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, releases: [{id: v1.1.0, deprecated: true}, {id: v1.0.0, updatesTo: ">1.0.0"}]}
+`,
+			expectedErr: "channel memory/stable: the newest release v1.1.0 is deprecated",
+		},
+		{
+			name: "unknown rollback",
+			releases: `
+imageName: spicedb
+channels:
+  - name: stable
+    datastore: memory
+    releases: [{id: v1.1.0}, {id: v1.0.0, updatesTo: ">1.0.0"}]
+    rollbacks: {v1.1.0: [{to: v0.9.0}]}
+`,
+			expectedErr: "channel memory/stable: v0.9.0 is referenced by a rollback or dispatch incompatibility but isn't a release",
+		},
+		{
+			name: "rollback across a migration",
+			releases: `
+imageName: spicedb
+channels:
+  - name: stable
+    datastore: memory
+    releases: [{id: v1.1.0, migration: b}, {id: v1.0.0, migration: a, updatesTo: ">1.0.0"}]
+    rollbacks: {v1.1.0: [{to: v1.0.0}]}
+`,
+			expectedErr: "channel memory/stable: rollback from v1.1.0 to v1.0.0 crosses a migration",
+		},
+		{
+			name: "invalid lifecycle date",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, releases: [{id: v1.1.0}, {id: v1.0.0, endOfLife: soon, updatesTo: ">1.0.0"}]}
+`,
+			expectedErr: `channel memory/stable: node v1.0.0 has an invalid endOfLife: parsing time "soon" as "2006-01-02": cannot parse "soon" as "2006"`,
+		},
+		{
+			name: "two defaults for a datastore",
+			releases: `
+imageName: spicedb
+channels:
+  - {name: stable, datastore: memory, default: true, releases: [{id: v1.1.0}, {id: v1.0.0, updatesTo: ">1.0.0"}]}
+  - {name: rapid, datastore: memory, default: true, releases: [{id: v1.1.0}, {id: v1.0.0, updatesTo: ">1.0.0"}]}
+`,
+			expectedErr: "channels stable and rapid are both the default for memory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var releases ReleasesFile
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tt.releases), &releases))
+			opconfig, err := releases.Compile()
+			if tt.expected == nil {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, opconfig.Channels)
+		})
+	}
+}
+
+// TestReleasesMatchGraph fails if releases.yaml was changed without
+// regenerating the update graph.
+func TestReleasesMatchGraph(t *testing.T) {
+	contents, err := os.ReadFile("releases.yaml")
+	require.NoError(t, err)
+	var releases ReleasesFile
+	require.NoError(t, yaml.UnmarshalStrict(contents, &releases))
+
+	opconfig, err := releases.Compile()
+	require.NoError(t, err)
+	compiled, err := yaml.Marshal(&opconfig)
+	require.NoError(t, err)
+
+	proposed, err := os.ReadFile("../../proposed-update-graph.yaml")
+	require.NoError(t, err)
+	require.Equal(t, string(proposed), string(compiled), "run `mage gen:graph` to regenerate the update graph")
+}